@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
+
+	"github.com/kanopy-platform/drone-bazelisk-ecr/pkg/plugin"
 )
 
 func main() {
-	// get new plugin object
-	p := newPlugin()
+	// read plugin settings
+	cfg, err := plugin.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// run bazelisk
-  err := p.run()
+	err = plugin.Run(context.Background(), cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"archive/tar"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// annotation naming the image in an OCI layout
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// export the pushed image as an OCI layout tarball and upload it under the archive target
+func (p *Config) archiveImage(svc ecriface.ECRAPI, uploader s3manageriface.UploaderAPI, digest string) error {
+	bucket, prefix, err := parseS3URL(p.ArchiveTarget)
+	if err != nil {
+		return err
+	}
+
+	ref, err := p.imageRef(digest)
+	if err != nil {
+		return err
+	}
+
+	auth, err := p.registryAuth(svc)
+	if err != nil {
+		return err
+	}
+
+	desc, err := remote.Get(ref, remote.WithAuth(auth))
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "archive")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	lp, err := layout.Write(filepath.Join(dir, "layout"), empty.Index)
+	if err != nil {
+		return err
+	}
+
+	annotations := layout.WithAnnotations(map[string]string{refNameAnnotation: p.imageTag()})
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		err = lp.AppendIndex(index, annotations)
+		if err != nil {
+			return err
+		}
+	} else {
+		image, err := desc.Image()
+		if err != nil {
+			return err
+		}
+		err = lp.AppendImage(image, annotations)
+		if err != nil {
+			return err
+		}
+	}
+
+	file := filepath.Join(dir, "image.tar")
+	err = tarDirectory(filepath.Join(dir, "layout"), file)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(prefix, p.Repository, strings.TrimPrefix(digest, "sha256:")+".tar")
+	err = uploadFile(uploader, bucket, key, file)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("archived %s@%s to s3://%s/%s", p.Repository, digest, bucket, key)
+	return nil
+}
+
+// write the files under dir to a tarball, relative to dir
+func tarDirectory(dir, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	err = filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || name == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+
+		err = tw.WriteHeader(header)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		src, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
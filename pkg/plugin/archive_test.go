@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestArchiveImage(t *testing.T) {
+	host := newTestRegistry(t)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(fmt.Sprintf("%s/my-service:v1", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = remote.Write(ref, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Config{Registry: host, Repository: "my-service", Tag: "v1", ArchiveTarget: "s3://archive/images"}
+	uploader := &mockUploader{objects: map[string]string{}}
+
+	err = p.archiveImage(&mockECRClient{}, uploader, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := fmt.Sprintf("archive/images/my-service/%s.tar", digest.Hex)
+	body, ok := uploader.objects[key]
+	if !ok {
+		t.Fatalf("%s was not uploaded: %v", key, uploader.objects)
+	}
+
+	// the tarball holds an OCI layout with the manifest blob
+	files := map[string]bool{}
+	tr := tar.NewReader(strings.NewReader(body))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = true
+	}
+
+	for _, file := range []string{"oci-layout", "index.json", "blobs/sha256/" + digest.Hex} {
+		if !files[file] {
+			t.Errorf("%s is missing from the archive: %v", file, files)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+)
+
+// bazel output directory symlink in the workspace root
+const bazelBin = "bazel-bin"
+
+// get an s3 uploader
+func (p *Config) s3Uploader() (*s3manager.Uploader, error) {
+	config, err := p.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3manager.NewUploader(session.New(config)), nil
+}
+
+// upload the bazel outputs matching the artifact patterns to S3
+func (p *Config) uploadArtifacts(svc s3manageriface.UploaderAPI, dir string) error {
+	bucket, prefix, err := parseS3URL(p.ArtifactsTarget)
+	if err != nil {
+		return err
+	}
+
+	files, err := matchArtifacts(dir, p.Artifacts)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err := uploadFile(svc, bucket, path.Join(prefix, file), filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+
+		log.Printf("uploaded %s to s3://%s/%s", file, bucket, path.Join(prefix, file))
+	}
+
+	return nil
+}
+
+func uploadFile(svc s3manageriface.UploaderAPI, bucket, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = svc.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	return err
+}
+
+// find the files under dir matching the patterns, relative to dir
+func matchArtifacts(dir string, patterns []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no artifacts matched pattern: %s", pattern)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			// only regular files are published
+			if info.IsDir() {
+				continue
+			}
+
+			rel, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+
+			if !seen[rel] {
+				seen[rel] = true
+				files = append(files, filepath.ToSlash(rel))
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// split an s3://bucket/prefix URL into the bucket and key prefix
+func parseS3URL(s3URL string) (string, string, error) {
+	u, err := url.Parse(s3URL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid s3 url: %s", s3URL)
+	}
+
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+)
+
+// records uploaded objects by key
+type mockUploader struct {
+	s3manageriface.UploaderAPI
+	objects map[string]string
+}
+
+func (m *mockUploader) Upload(input *s3manager.UploadInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)] = string(body)
+	return &s3manager.UploadOutput{}, nil
+}
+
+// create files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"charts/app.tgz":   "chart",
+		"charts/lib.tgz":   "lib",
+		"api/openapi.json": "spec",
+		"app/app":          "binary",
+	})
+
+	svc := &mockUploader{objects: map[string]string{}}
+	p := Config{
+		Artifacts:       []string{"charts/*.tgz", "api/openapi.json"},
+		ArtifactsTarget: "s3://bucket/builds/1",
+	}
+
+	err := p.uploadArtifacts(svc, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"bucket/builds/1/charts/app.tgz":   "chart",
+		"bucket/builds/1/charts/lib.tgz":   "lib",
+		"bucket/builds/1/api/openapi.json": "spec",
+	}
+
+	if !reflect.DeepEqual(want, svc.objects) {
+		t.Errorf("%v is not equal to %v", want, svc.objects)
+	}
+
+	// patterns must match at least one file
+	p.Artifacts = []string{"missing/*"}
+	err = p.uploadArtifacts(svc, dir)
+	if err == nil {
+		t.Errorf("unmatched pattern should have failed")
+	}
+}
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		url    string
+		bucket string
+		prefix string
+		fail   bool
+	}{
+		{url: "s3://bucket", bucket: "bucket"},
+		{url: "s3://bucket/prefix/path", bucket: "bucket", prefix: "prefix/path"},
+		{url: "https://bucket/prefix", fail: true},
+		{url: "bucket/prefix", fail: true},
+	}
+
+	for _, test := range tests {
+		bucket, prefix, err := parseS3URL(test.url)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.url, err)
+		}
+
+		if bucket != test.bucket || prefix != test.prefix {
+			t.Errorf("%s/%s is not equal to %s/%s", test.bucket, test.prefix, bucket, prefix)
+		}
+	}
+}
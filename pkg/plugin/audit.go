@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// record of a pushed image kept as audit evidence
+type auditRecord struct {
+	ID         string `json:"id" dynamodbav:"id"`
+	Time       string `json:"time" dynamodbav:"time"`
+	Repo       string `json:"repo" dynamodbav:"repo"`
+	Commit     string `json:"commit" dynamodbav:"commit"`
+	Build      string `json:"build" dynamodbav:"build"`
+	BuildLink  string `json:"build_link" dynamodbav:"build_link"`
+	Author     string `json:"author" dynamodbav:"author"`
+	Image      string `json:"image" dynamodbav:"image"`
+	Registry   string `json:"registry" dynamodbav:"registry"`
+	Repository string `json:"repository" dynamodbav:"repository"`
+	Tag        string `json:"tag" dynamodbav:"tag"`
+	Digest     string `json:"digest" dynamodbav:"digest"`
+	Identity   string `json:"identity" dynamodbav:"identity"`
+}
+
+// write an audit record of the pushed image
+func (p *Config) audit(getter buildGetter, digest string) error {
+	config, err := p.awsConfig()
+	if err != nil {
+		return err
+	}
+	sess := session.New(config)
+
+	record, err := p.newAuditRecord(getter, sts.New(sess), digest, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if p.AuditTable != "" {
+		err = p.putAuditItem(dynamodb.New(sess), record)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.AuditTarget != "" {
+		err = p.uploadAuditRecord(s3manager.NewUploader(sess), record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Config) newAuditRecord(getter buildGetter, svc stsiface.STSAPI, digest string, now time.Time) (auditRecord, error) {
+	// identity the image was pushed with
+	identity, err := svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return auditRecord{}, err
+	}
+
+	timestamp := now.UTC().Format(time.RFC3339Nano)
+
+	return auditRecord{
+		ID:         fmt.Sprintf("%s/%s@%s/%s", p.Registry, p.Repository, digest, timestamp),
+		Time:       timestamp,
+		Repo:       getter.Repo(),
+		Commit:     getter.ScmRevision(),
+		Build:      getter.BuildNumber(),
+		BuildLink:  getter.Uri(),
+		Author:     getter.Author(),
+		Image:      p.imageVars(digest)["IMAGE"],
+		Registry:   p.Registry,
+		Repository: p.Repository,
+		Tag:        p.imageTag(),
+		Digest:     digest,
+		Identity:   aws.StringValue(identity.Arn),
+	}, nil
+}
+
+// add the record to the audit table, never overwriting existing records
+func (p *Config) putAuditItem(svc dynamodbiface.DynamoDBAPI, record auditRecord) error {
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(p.AuditTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	return err
+}
+
+// upload the record as a JSON object with a unique key under the audit prefix
+func (p *Config) uploadAuditRecord(svc s3manageriface.UploaderAPI, record auditRecord) error {
+	bucket, prefix, err := parseS3URL(p.AuditTarget)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	digest := strings.TrimPrefix(record.Digest, "sha256:")
+	key := path.Join(prefix, p.Repository, fmt.Sprintf("%s-%s.json", record.Time, digest))
+
+	_, err = svc.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
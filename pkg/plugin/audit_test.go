@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+type mockSTSClient struct {
+	stsiface.STSAPI
+}
+
+func (m *mockSTSClient) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	if testFailure == "GetCallerIdentity" {
+		return nil, errors.New("GetCallerIdentity")
+	}
+
+	return &sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::0123456789:user/drone")}, nil
+}
+
+type mockDynamoDBClient struct {
+	dynamodbiface.DynamoDBAPI
+	input *dynamodb.PutItemInput
+}
+
+func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.input = input
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestAuditRecord(t *testing.T) {
+	p := Config{
+		Registry:    "registry",
+		Repository:  "repository",
+		Tag:         "v1",
+		AuditTable:  "audit",
+		AuditTarget: "s3://bucket/audit",
+	}
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testFailure = ""
+	record, err := p.newAuditRecord(newBuildMock(), &mockSTSClient{}, "sha256:abc", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := auditRecord{
+		ID:         "registry/repository@sha256:abc/2023-01-02T03:04:05Z",
+		Time:       "2023-01-02T03:04:05Z",
+		Repo:       "test",
+		Commit:     "test",
+		Build:      "test",
+		BuildLink:  "test",
+		Author:     "test",
+		Image:      "registry/repository@sha256:abc",
+		Registry:   "registry",
+		Repository: "repository",
+		Tag:        "v1",
+		Digest:     "sha256:abc",
+		Identity:   "arn:aws:iam::0123456789:user/drone",
+	}
+	if record != want {
+		t.Errorf("%+v is not equal to %+v", want, record)
+	}
+
+	// records are never overwritten
+	db := &mockDynamoDBClient{}
+	err = p.putAuditItem(db, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.StringValue(db.input.ConditionExpression) != "attribute_not_exists(id)" {
+		t.Errorf("unexpected condition: %s", aws.StringValue(db.input.ConditionExpression))
+	}
+
+	if aws.StringValue(db.input.Item["digest"].S) != "sha256:abc" {
+		t.Errorf("unexpected item: %v", db.input.Item)
+	}
+
+	uploader := &mockUploader{objects: map[string]string{}}
+	err = p.uploadAuditRecord(uploader, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, ok := uploader.objects["bucket/audit/repository/2023-01-02T03:04:05Z-abc.json"]
+	if !ok {
+		t.Fatalf("audit record not uploaded: %v", uploader.objects)
+	}
+
+	var got auditRecord
+	err = json.Unmarshal([]byte(body), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("%+v is not equal to %+v", want, got)
+	}
+
+	// identity lookup failures fail the audit
+	testFailure = "GetCallerIdentity"
+	_, err = p.newAuditRecord(newBuildMock(), &mockSTSClient{}, "sha256:abc", now)
+	if err == nil {
+		t.Errorf("identity failure should have failed")
+	}
+	testFailure = ""
+}
@@ -0,0 +1,244 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// build event protocol data reported in the summary
+type buildEvents struct {
+	ExitCode     string        `json:"exit_code,omitempty"`
+	Cache        *cacheStats   `json:"cache,omitempty"`
+	RetriedTests []retriedTest `json:"retried_tests,omitempty"`
+	// seconds bazel spent after the build finished, waiting for remote cache and BES uploads
+	UploadWait float64 `json:"upload_wait,omitempty"`
+	// seconds spent in the analysis and execution phases of the build
+	AnalysisTime  float64 `json:"analysis_time,omitempty"`
+	ExecutionTime float64 `json:"execution_time,omitempty"`
+
+	finishTime time.Time
+}
+
+// action cache statistics from the build metrics event
+type cacheStats struct {
+	ActionsCreated  int64            `json:"actions_created"`
+	ActionsExecuted int64            `json:"actions_executed"`
+	Runners         map[string]int64 `json:"runners,omitempty"`
+	// percentage of the cacheable executed actions served by the remote and disk caches
+	RemoteHitRate float64 `json:"remote_hit_rate"`
+	DiskHitRate   float64 `json:"disk_hit_rate"`
+	// bytes received by the machine while bazel ran, including the traffic of other processes,
+	// only reported when bazel collects the system network usage
+	SystemNetworkReceivedBytes int64 `json:"system_network_received_bytes,omitempty"`
+}
+
+// runner names counting cache hits and actions that are never cached
+const (
+	remoteCacheHit = "remote cache hit"
+	diskCacheHit   = "disk cache hit"
+	internalRunner = "internal"
+)
+
+// compute the hit rates from the runner counts, leaving out internal actions such as
+// symlinks and file writes which are never served by a cache
+func (c *cacheStats) setHitRates() {
+	total := c.Runners["total"]
+	if total == 0 {
+		total = c.ActionsExecuted
+	}
+	total -= c.Runners[internalRunner]
+	if total <= 0 {
+		return
+	}
+
+	c.RemoteHitRate = math.Round(float64(c.Runners[remoteCacheHit])*1000/float64(total)) / 10
+	c.DiskHitRate = math.Round(float64(c.Runners[diskCacheHit])*1000/float64(total)) / 10
+}
+
+// test that needed more than one attempt
+type retriedTest struct {
+	Label    string `json:"label"`
+	Status   string `json:"status"`
+	Attempts int64  `json:"attempts"`
+}
+
+// int64 values are encoded as strings in proto3 JSON
+type jsonInt int64
+
+func (i *jsonInt) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*i = jsonInt(n)
+	return nil
+}
+
+// subset of a build event used by the plugin
+type buildEvent struct {
+	ID struct {
+		TestSummary *struct {
+			Label string `json:"label"`
+		} `json:"testSummary"`
+	} `json:"id"`
+	Finished *struct {
+		ExitCode struct {
+			Name string `json:"name"`
+		} `json:"exitCode"`
+		FinishTimeMillis jsonInt `json:"finishTimeMillis"`
+	} `json:"finished"`
+	BuildMetrics *struct {
+		ActionSummary struct {
+			ActionsCreated  jsonInt `json:"actionsCreated"`
+			ActionsExecuted jsonInt `json:"actionsExecuted"`
+			RunnerCount     []struct {
+				Name  string  `json:"name"`
+				Count jsonInt `json:"count"`
+			} `json:"runnerCount"`
+		} `json:"actionSummary"`
+		NetworkMetrics struct {
+			SystemNetworkStats *struct {
+				BytesRecv jsonInt `json:"bytesRecv"`
+			} `json:"systemNetworkStats"`
+		} `json:"networkMetrics"`
+		TimingMetrics struct {
+			AnalysisPhaseTimeInMs  jsonInt `json:"analysisPhaseTimeInMs"`
+			ExecutionPhaseTimeInMs jsonInt `json:"executionPhaseTimeInMs"`
+		} `json:"timingMetrics"`
+	} `json:"buildMetrics"`
+	TestSummary *struct {
+		OverallStatus string  `json:"overallStatus"`
+		AttemptCount  jsonInt `json:"attemptCount"`
+	} `json:"testSummary"`
+}
+
+// parse the newline delimited JSON build event file written by bazel
+func parseBuildEvents(path string) (*buildEvents, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := &buildEvents{}
+
+	scanner := bufio.NewScanner(f)
+	// events can be larger than the default token size
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event buildEvent
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			return events, err
+		}
+
+		if event.Finished != nil {
+			events.ExitCode = event.Finished.ExitCode.Name
+			if event.Finished.FinishTimeMillis > 0 {
+				events.finishTime = time.UnixMilli(int64(event.Finished.FinishTimeMillis))
+			}
+		}
+
+		if event.BuildMetrics != nil {
+			summary := event.BuildMetrics.ActionSummary
+			events.Cache = &cacheStats{
+				ActionsCreated:  int64(summary.ActionsCreated),
+				ActionsExecuted: int64(summary.ActionsExecuted),
+				Runners:         map[string]int64{},
+			}
+			for _, runner := range summary.RunnerCount {
+				events.Cache.Runners[runner.Name] = int64(runner.Count)
+			}
+			events.Cache.setHitRates()
+			if stats := event.BuildMetrics.NetworkMetrics.SystemNetworkStats; stats != nil {
+				events.Cache.SystemNetworkReceivedBytes = int64(stats.BytesRecv)
+			}
+
+			timing := event.BuildMetrics.TimingMetrics
+			events.AnalysisTime = (time.Duration(timing.AnalysisPhaseTimeInMs) * time.Millisecond).Seconds()
+			events.ExecutionTime = (time.Duration(timing.ExecutionPhaseTimeInMs) * time.Millisecond).Seconds()
+		}
+
+		// flaky_test_attempts retries failed tests
+		if event.TestSummary != nil && event.ID.TestSummary != nil && event.TestSummary.AttemptCount > 1 {
+			events.RetriedTests = append(events.RetriedTests, retriedTest{
+				Label:    event.ID.TestSummary.Label,
+				Status:   event.TestSummary.OverallStatus,
+				Attempts: int64(event.TestSummary.AttemptCount),
+			})
+		}
+	}
+
+	return events, scanner.Err()
+}
+
+// record how long bazel took to exit after the build finished
+func (e *buildEvents) setUploadWait(exited time.Time) {
+	if e.finishTime.IsZero() || exited.Before(e.finishTime) {
+		return
+	}
+
+	e.UploadWait = exited.Sub(e.finishTime).Seconds()
+}
+
+// first bazel major version with --experimental_collect_system_network_usage
+const networkUsageMinVersion = 6
+
+// whether bazel collects the network usage of the machine, by default when the
+// bazel version run by bazelisk supports it
+func (p *Config) collectNetworkUsage() bool {
+	if p.CollectNetworkUsage != nil {
+		return *p.CollectNetworkUsage
+	}
+
+	return bazelVersionAtLeast(bazeliskVersion(), networkUsageMinVersion)
+}
+
+// bazel version run by bazelisk, empty when the latest release is run
+func bazeliskVersion() string {
+	if version := os.Getenv("USE_BAZEL_VERSION"); version != "" {
+		return version
+	}
+
+	data, err := os.ReadFile(".bazelversion")
+	if err != nil {
+		return ""
+	}
+	version, _, _ := strings.Cut(string(data), "\n")
+
+	return strings.TrimSpace(version)
+}
+
+// whether a bazelisk version is at least the major version, the releases picked by
+// bazelisk such as latest and rolling always are while unknown versions are not
+func bazelVersionAtLeast(version string, major int) bool {
+	// forks are prefixed with their GitHub organization
+	if i := strings.LastIndex(version, "/"); i >= 0 {
+		version = version[i+1:]
+	}
+
+	switch {
+	case version == "", version == "rolling", version == "last_green", version == "last_rc",
+		strings.HasPrefix(version, "latest"):
+		return true
+	}
+
+	first, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(first)
+	if err != nil {
+		return false
+	}
+
+	return n >= major
+}
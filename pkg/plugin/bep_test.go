@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestParseBuildEvents(t *testing.T) {
+	got, err := parseBuildEvents(filepath.Join("testdata", "build_events.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &buildEvents{
+		ExitCode: "SUCCESS",
+		Cache: &cacheStats{
+			ActionsCreated:  120,
+			ActionsExecuted: 42,
+			Runners: map[string]int64{
+				"total":            42,
+				"remote cache hit": 26,
+				"disk cache hit":   4,
+				"linux-sandbox":    10,
+				"internal":         2,
+			},
+			RemoteHitRate:              65,
+			DiskHitRate:                10,
+			SystemNetworkReceivedBytes: 73400320,
+		},
+		RetriedTests: []retriedTest{
+			{Label: "//app:flaky_test", Status: "FLAKY", Attempts: 2},
+		},
+		AnalysisTime:  12.5,
+		ExecutionTime: 48,
+		finishTime:    time.UnixMilli(1681234567890),
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%+v is not equal to %+v", want, got)
+	}
+
+	got.setUploadWait(time.UnixMilli(1681234570390))
+	if got.UploadWait != 2.5 {
+		t.Errorf("2.5 is not equal to %v", got.UploadWait)
+	}
+
+	_, err = parseBuildEvents(filepath.Join("testdata", "missing.json"))
+	if err == nil {
+		t.Errorf("missing build events should have failed")
+	}
+}
+
+func TestBazelVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "", want: true},
+		{version: "latest", want: true},
+		{version: "latest-1", want: true},
+		{version: "rolling", want: true},
+		{version: "6.0.0", want: true},
+		{version: "7.x", want: true},
+		{version: "my-org/6.4.0", want: true},
+		{version: "5.4.1", want: false},
+		{version: "2.0.0", want: false},
+		{version: "unknown", want: false},
+	}
+
+	for _, test := range tests {
+		if got := bazelVersionAtLeast(test.version, networkUsageMinVersion); got != test.want {
+			t.Errorf("%q: got %v, want %v", test.version, got, test.want)
+		}
+	}
+}
+
+func TestCollectNetworkUsage(t *testing.T) {
+	tests := []struct {
+		plugin  Config
+		version string
+		want    bool
+	}{
+		{version: "6.2.1", want: true},
+		{version: "2.0.0", want: false},
+		{plugin: Config{CollectNetworkUsage: aws.Bool(true)}, version: "2.0.0", want: true},
+		{plugin: Config{CollectNetworkUsage: aws.Bool(false)}, version: "7.0.0", want: false},
+	}
+
+	for _, test := range tests {
+		t.Setenv("USE_BAZEL_VERSION", test.version)
+		if got := test.plugin.collectNetworkUsage(); got != test.want {
+			t.Errorf("%q: got %v, want %v", test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// JSON payload posted to time_budget_webhook
+type budgetPayload struct {
+	Registry   string  `json:"registry"`
+	Repository string  `json:"repository"`
+	Target     string  `json:"target"`
+	Budget     float64 `json:"budget"`
+	Duration   float64 `json:"duration"`
+	BuildLink  string  `json:"build_link,omitempty"`
+	Commit     string  `json:"commit,omitempty"`
+}
+
+// warn when a successful bazel run took longer than time_budget, so that slow creep
+// of build times is noticed, without failing the step
+func (p *Config) checkTimeBudget(getter buildGetter, elapsed time.Duration, res *result) {
+	if p.TimeBudget == 0 || elapsed <= p.TimeBudget {
+		return
+	}
+
+	res.overBudget = elapsed - p.TimeBudget
+
+	banner := strings.Repeat("!", 72)
+	log.Print(banner)
+	log.Printf("warning: bazel took %s, %s over the time budget of %s", elapsed.Round(time.Second), res.overBudget.Round(time.Second), p.TimeBudget)
+	log.Print(banner)
+
+	if p.TimeBudgetWebhook == "" {
+		return
+	}
+
+	err := sendWebhook(p.TimeBudgetWebhook, p.WebhookSecret, budgetPayload{
+		Registry:   p.Registry,
+		Repository: p.Repository,
+		Target:     p.Target,
+		Budget:     p.TimeBudget.Seconds(),
+		Duration:   elapsed.Seconds(),
+		BuildLink:  getter.Uri(),
+		Commit:     getter.ScmRevision(),
+	})
+	if err != nil {
+		log.Printf("could not send the time budget webhook: %s", err)
+	}
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckTimeBudget(t *testing.T) {
+	var got []budgetPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload budgetPayload
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, payload)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		budget  time.Duration
+		elapsed time.Duration
+		over    time.Duration
+	}{
+		// no budget
+		{elapsed: time.Hour},
+		{budget: 10 * time.Minute, elapsed: 9 * time.Minute},
+		{budget: 10 * time.Minute, elapsed: 12 * time.Minute, over: 2 * time.Minute},
+	}
+
+	for _, test := range tests {
+		got = nil
+		p := Config{Repository: "repository", Target: "//:push", TimeBudget: test.budget, TimeBudgetWebhook: server.URL}
+		res := newResult()
+
+		p.checkTimeBudget(newBuildMock(), test.elapsed, res)
+
+		if res.overBudget != test.over {
+			t.Errorf("expected %s over budget, got %s", test.over, res.overBudget)
+		}
+
+		if test.over == 0 {
+			if len(got) != 0 {
+				t.Errorf("unexpected webhook: %+v", got)
+			}
+			continue
+		}
+
+		if len(got) != 1 || got[0].Budget != test.budget.Seconds() || got[0].Duration != test.elapsed.Seconds() || got[0].Target != "//:push" {
+			t.Errorf("unexpected webhook: %+v", got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package plugin
+
+import "strings"
+
+// flags selecting how external dependencies are resolved with bzlmod
+func (p *Config) bzlmodArgs() []string {
+	var args []string
+
+	if p.EnableBzlmod != nil {
+		args = append(args, boolFlag("enable_bzlmod", *p.EnableBzlmod))
+	}
+
+	if p.LockfileMode != "" {
+		args = append(args, joinFlag("--lockfile_mode", p.LockfileMode))
+	}
+
+	for _, registry := range p.BazelRegistries {
+		args = append(args, joinFlag("--registry", strings.TrimSpace(registry)))
+	}
+
+	// module=path pairs overriding the module with a local directory
+	for _, override := range p.OverrideModules {
+		args = append(args, joinFlag("--override_module", strings.TrimSpace(override)))
+	}
+
+	return args
+}
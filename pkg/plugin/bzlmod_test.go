@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestBzlmodArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{},
+		},
+		{
+			plugin: Config{EnableBzlmod: aws.Bool(true), LockfileMode: "error"},
+			want:   []string{"--enable_bzlmod", "--lockfile_mode=error"},
+		},
+		{
+			plugin: Config{EnableBzlmod: aws.Bool(false)},
+			want:   []string{"--noenable_bzlmod"},
+		},
+		{
+			plugin: Config{
+				BazelRegistries: []string{"https://bcr.example.com", " https://bcr.bazel.build"},
+				OverrideModules: []string{"rules_oci=third_party/rules_oci"},
+			},
+			want: []string{
+				"--registry=https://bcr.example.com",
+				"--registry=https://bcr.bazel.build",
+				"--override_module=rules_oci=third_party/rules_oci",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.bzlmodArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"gopkg.in/yaml.v3"
+)
+
+// media types of helm charts stored as OCI artifacts
+const (
+	helmConfigMediaType  types.MediaType = "application/vnd.cncf.helm.config.v1+json"
+	helmContentMediaType types.MediaType = "application/vnd.cncf.helm.chart.content.v1.tar.gz"
+)
+
+// push the packaged chart or chart directory at chart_path as an OCI artifact,
+// to chart_repository or a repository named after the chart
+func (p *Config) pushChart(svc ecriface.ECRAPI) error {
+	chart, err := loadChart(p.ChartPath)
+	if err != nil {
+		return err
+	}
+
+	cfg := *p
+	cfg.Repository = p.ChartRepository
+	if cfg.Repository == "" {
+		cfg.Repository = chart.name
+	}
+	cfg.Repository = p.prefixRepository(cfg.Repository)
+
+	if p.CreateRepository {
+		err = cfg.createRepository(svc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// helm expects the tag to be the chart version
+	dst, err := cfg.imageRef(chart.tag())
+	if err != nil {
+		return err
+	}
+
+	auth, err := cfg.registryAuth(svc)
+	if err != nil {
+		return err
+	}
+
+	digest, err := chart.Digest()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.writeImage(svc, dst, chart, auth)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("pushed chart %s %s to %s@%s", chart.name, chart.version, dst.Context(), digest)
+	return nil
+}
+
+// helm chart package
+type helmChart struct {
+	v1.Image
+
+	name    string
+	version string
+}
+
+// tag of the chart version, OCI tags can not contain "+"
+func (c *helmChart) tag() string {
+	return strings.ReplaceAll(c.version, "+", "_")
+}
+
+// load a chart from a packaged .tgz or package a chart directory
+func loadChart(chartPath string) (*helmChart, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var content, metadata []byte
+	if info.IsDir() {
+		metadata, err = os.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		content, err = os.ReadFile(chartPath)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata, err = chartMetadata(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not read chart %s: %w", chartPath, err)
+		}
+	}
+
+	var values map[string]interface{}
+	err = yaml.Unmarshal(metadata, &values)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Chart.yaml of %s: %w", chartPath, err)
+	}
+
+	name, _ := values["name"].(string)
+	version, _ := values["version"].(string)
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("chart %s must have a name and version", chartPath)
+	}
+
+	if info.IsDir() {
+		content, err = packageChart(chartPath, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := newChartImage(config, content, map[string]string{
+		"org.opencontainers.image.title":   name,
+		"org.opencontainers.image.version": version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &helmChart{Image: image, name: name, version: version}, nil
+}
+
+// read Chart.yaml from the top-level directory of a packaged chart
+func chartMetadata(content []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("Chart.yaml not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		dir, file := path.Split(header.Name)
+		if file == "Chart.yaml" && strings.Count(dir, "/") == 1 {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// package a chart directory the way helm package does, under a directory named after the chart
+func packageChart(dir, name string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(&tar.Header{
+			Name:     path.Join(name, filepath.ToSlash(rel)),
+			Mode:     0644,
+			Size:     int64(len(body)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// OCI artifact holding the chart metadata as its config and the package as its only layer
+type chartImage struct {
+	config   []byte
+	content  v1.Layer
+	manifest []byte
+}
+
+// chartImage constructor
+func newChartImage(config, content []byte, annotations map[string]string) (v1.Image, error) {
+	c := &chartImage{config: config, content: static.NewLayer(content, helmContentMediaType)}
+
+	configDigest, _, err := v1.SHA256(bytes.NewReader(config))
+	if err != nil {
+		return nil, err
+	}
+
+	contentDigest, err := c.content.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	c.manifest, err = json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config: v1.Descriptor{
+			MediaType: helmConfigMediaType,
+			Size:      int64(len(config)),
+			Digest:    configDigest,
+		},
+		Layers: []v1.Descriptor{{
+			MediaType: helmContentMediaType,
+			Size:      int64(len(content)),
+			Digest:    contentDigest,
+		}},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return partial.CompressedToImage(c)
+}
+
+// RawConfigFile implements partial.CompressedImageCore
+func (c *chartImage) RawConfigFile() ([]byte, error) {
+	return c.config, nil
+}
+
+// MediaType implements partial.CompressedImageCore
+func (c *chartImage) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+// RawManifest implements partial.CompressedImageCore
+func (c *chartImage) RawManifest() ([]byte, error) {
+	return c.manifest, nil
+}
+
+// LayerByDigest implements partial.CompressedImageCore
+func (c *chartImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	digest, err := c.content.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	if h == digest {
+		return c.content, nil
+	}
+
+	return nil, fmt.Errorf("layer not found: %s", h)
+}
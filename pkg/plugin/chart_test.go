@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPushChart(t *testing.T) {
+	host := newTestRegistry(t)
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"my-chart/Chart.yaml":         "apiVersion: v2\nname: my-chart\nversion: 1.2.0+build.3\n",
+		"my-chart/values.yaml":        "replicas: 1\n",
+		"my-chart/templates/app.yaml": "kind: Deployment\n",
+		"broken/Chart.yaml":           "apiVersion: v2\n",
+	})
+
+	chart, err := loadChart(filepath.Join(dir, "my-chart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a packaged chart loads the same artifact as its directory
+	layers, err := chart.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layers[0].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	pkg := filepath.Join(dir, "my-chart-1.2.0.tgz")
+	f, err := os.Create(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.ReadFrom(rc)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		p    Config
+		ref  string
+		fail bool
+	}{
+		{
+			p:   Config{Registry: host, ChartPath: filepath.Join(dir, "my-chart")},
+			ref: fmt.Sprintf("%s/my-chart:1.2.0_build.3", host),
+		},
+		{
+			p:   Config{Registry: host, ChartPath: pkg, ChartRepository: "charts", RepositoryPrefix: "team/"},
+			ref: fmt.Sprintf("%s/team/charts:1.2.0_build.3", host),
+		},
+		{
+			p:    Config{Registry: host, ChartPath: filepath.Join(dir, "broken")},
+			fail: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.p.pushChart(&mockECRClient{})
+		if (err != nil) != test.fail {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if test.fail {
+			continue
+		}
+
+		ref, err := name.ParseReference(test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		image, err := remote.Image(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifest, err := image.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if manifest.Config.MediaType != helmConfigMediaType || manifest.Layers[0].MediaType != helmContentMediaType {
+			t.Errorf("unexpected media types: %s, %s", manifest.Config.MediaType, manifest.Layers[0].MediaType)
+		}
+
+		want, err := chart.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := image.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("%s is not equal to %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CI metadata about the current build
+type buildGetter interface {
+	PipelineName() string
+	JobName() string
+	Uri() string
+	ScmRemote() string
+	ScmBranch() string
+	ScmRevision() string
+	Event() string
+	Repo() string
+	BuildNumber() string
+	Author() string
+}
+
+// supported CI providers
+const (
+	providerDrone      = "drone"
+	providerGitHub     = "github"
+	providerGitLab     = "gitlab"
+	providerWoodpecker = "woodpecker"
+)
+
+// CI provider constructors by name
+var providers = map[string]func() *buildEnv{
+	providerDrone:      newDroneEnv,
+	providerGitHub:     newGitHubEnv,
+	providerGitLab:     newGitLabEnv,
+	providerWoodpecker: newWoodpeckerEnv,
+}
+
+// build metadata read from the environment of a CI provider
+type buildEnv struct {
+	pipelineName func() string
+	jobName      func() string
+	uri          func() string
+	scmRemote    func() string
+	scmBranch    func() string
+	scmRevision  func() string
+	event        func() string
+	repo         func() string
+	buildNumber  func() string
+	author       func() string
+}
+
+// get the build metadata of the named provider, detecting it from the environment when unset
+func newBuildEnv(provider string) (*buildEnv, error) {
+	if provider == "" {
+		provider = detectProvider()
+	}
+
+	newEnv, ok := providers[strings.ToLower(provider)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported CI provider: %s", provider)
+	}
+
+	return newEnv(), nil
+}
+
+// detect the CI provider from the variables it sets, defaulting to drone
+func detectProvider() string {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return providerGitHub
+	case os.Getenv("GITLAB_CI") == "true":
+		return providerGitLab
+	case os.Getenv("CI") == "woodpecker":
+		return providerWoodpecker
+	default:
+		return providerDrone
+	}
+}
+
+func newDroneEnv() *buildEnv {
+	return &buildEnv{
+		pipelineName: getenv("DRONE_STAGE_NAME"),
+		jobName:      getenv("DRONE_STEP_NAME"),
+		uri:          getenv("DRONE_BUILD_LINK"),
+		scmRemote:    getenv("DRONE_REPO_LINK"),
+		scmBranch:    getenv("DRONE_COMMIT_BRANCH"),
+		scmRevision:  getenv("DRONE_COMMIT"),
+		event:        getenv("DRONE_BUILD_EVENT"),
+		repo:         getenv("DRONE_REPO"),
+		buildNumber:  getenv("DRONE_BUILD_NUMBER"),
+		author:       getenv("DRONE_COMMIT_AUTHOR"),
+	}
+}
+
+func newGitHubEnv() *buildEnv {
+	repoLink := func() string {
+		return fmt.Sprintf("%s/%s", os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"))
+	}
+
+	return &buildEnv{
+		pipelineName: getenv("GITHUB_WORKFLOW"),
+		jobName:      getenv("GITHUB_JOB"),
+		uri: func() string {
+			return fmt.Sprintf("%s/actions/runs/%s", repoLink(), os.Getenv("GITHUB_RUN_ID"))
+		},
+		scmRemote: repoLink,
+		// pull requests are built from the head branch
+		scmBranch:   firstenv("GITHUB_HEAD_REF", "GITHUB_REF_NAME"),
+		scmRevision: getenv("GITHUB_SHA"),
+		event:       getenv("GITHUB_EVENT_NAME"),
+		repo:        getenv("GITHUB_REPOSITORY"),
+		buildNumber: getenv("GITHUB_RUN_NUMBER"),
+		author:      getenv("GITHUB_ACTOR"),
+	}
+}
+
+func newGitLabEnv() *buildEnv {
+	return &buildEnv{
+		pipelineName: getenv("CI_PIPELINE_NAME"),
+		jobName:      getenv("CI_JOB_NAME"),
+		uri:          getenv("CI_JOB_URL"),
+		scmRemote:    getenv("CI_PROJECT_URL"),
+		scmBranch:    firstenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "CI_COMMIT_REF_NAME"),
+		scmRevision:  getenv("CI_COMMIT_SHA"),
+		event: func() string {
+			// merge requests are treated like pull requests
+			if os.Getenv("CI_PIPELINE_SOURCE") == "merge_request_event" {
+				return "pull_request"
+			}
+			return os.Getenv("CI_PIPELINE_SOURCE")
+		},
+		repo:        getenv("CI_PROJECT_PATH"),
+		buildNumber: getenv("CI_PIPELINE_IID"),
+		author:      getenv("GITLAB_USER_LOGIN"),
+	}
+}
+
+// woodpecker renamed several variables, the newer names are preferred
+func newWoodpeckerEnv() *buildEnv {
+	return &buildEnv{
+		pipelineName: firstenv("CI_WORKFLOW_NAME"),
+		jobName:      firstenv("CI_STEP_NAME"),
+		uri:          firstenv("CI_PIPELINE_URL", "CI_BUILD_LINK"),
+		scmRemote:    firstenv("CI_REPO_URL", "CI_REPO_LINK"),
+		scmBranch:    firstenv("CI_COMMIT_SOURCE_BRANCH", "CI_COMMIT_BRANCH"),
+		scmRevision:  firstenv("CI_COMMIT_SHA"),
+		event:        firstenv("CI_PIPELINE_EVENT", "CI_BUILD_EVENT"),
+		repo:         firstenv("CI_REPO"),
+		buildNumber:  firstenv("CI_PIPELINE_NUMBER", "CI_BUILD_NUMBER"),
+		author:       firstenv("CI_COMMIT_AUTHOR"),
+	}
+}
+
+func (s *buildEnv) PipelineName() string {
+	return s.pipelineName()
+}
+
+func (s *buildEnv) JobName() string {
+	return s.jobName()
+}
+
+func (s *buildEnv) Uri() string {
+	return s.uri()
+}
+
+func (s *buildEnv) ScmRemote() string {
+	return s.scmRemote()
+}
+
+func (s *buildEnv) ScmBranch() string {
+	return s.scmBranch()
+}
+
+func (s *buildEnv) ScmRevision() string {
+	return s.scmRevision()
+}
+
+func (s *buildEnv) Event() string {
+	return s.event()
+}
+
+func (s *buildEnv) Repo() string {
+	return s.repo()
+}
+
+func (s *buildEnv) BuildNumber() string {
+	return s.buildNumber()
+}
+
+func (s *buildEnv) Author() string {
+	return s.author()
+}
+
+// read an env var lazily
+func getenv(key string) func() string {
+	return func() string {
+		return os.Getenv(key)
+	}
+}
+
+// read the first env var that is set
+func firstenv(keys ...string) func() string {
+	return func() string {
+		for _, key := range keys {
+			if val := os.Getenv(key); val != "" {
+				return val
+			}
+		}
+		return ""
+	}
+}
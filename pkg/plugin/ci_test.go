@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+// collect all metadata of a build env
+func buildEnvValues(env buildGetter) []string {
+	return []string{
+		env.PipelineName(),
+		env.JobName(),
+		env.Uri(),
+		env.ScmRemote(),
+		env.ScmBranch(),
+		env.ScmRevision(),
+		env.Event(),
+		env.Repo(),
+		env.BuildNumber(),
+		env.Author(),
+	}
+}
+
+func TestBuildEnv(t *testing.T) {
+	tests := []struct {
+		provider string
+		env      map[string]string
+		want     []string
+	}{
+		{
+			provider: providerDrone,
+			env: map[string]string{
+				"DRONE_STAGE_NAME":    "default",
+				"DRONE_STEP_NAME":     "publish",
+				"DRONE_BUILD_LINK":    "https://drone.example.com/org/repo/1",
+				"DRONE_REPO_LINK":     "https://github.com/org/repo",
+				"DRONE_COMMIT_BRANCH": "main",
+				"DRONE_COMMIT":        "abc",
+				"DRONE_BUILD_EVENT":   "push",
+				"DRONE_REPO":          "org/repo",
+				"DRONE_BUILD_NUMBER":  "1",
+				"DRONE_COMMIT_AUTHOR": "octocat",
+			},
+			want: []string{"default", "publish", "https://drone.example.com/org/repo/1", "https://github.com/org/repo", "main", "abc", "push", "org/repo", "1", "octocat"},
+		},
+		// detected from the environment
+		{
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_WORKFLOW":   "build",
+				"GITHUB_JOB":        "publish",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "org/repo",
+				"GITHUB_RUN_ID":     "42",
+				"GITHUB_HEAD_REF":   "feature",
+				"GITHUB_REF_NAME":   "1/merge",
+				"GITHUB_SHA":        "abc",
+				"GITHUB_EVENT_NAME": "pull_request",
+				"GITHUB_RUN_NUMBER": "7",
+				"GITHUB_ACTOR":      "octocat",
+			},
+			want: []string{"build", "publish", "https://github.com/org/repo/actions/runs/42", "https://github.com/org/repo", "feature", "abc", "pull_request", "org/repo", "7", "octocat"},
+		},
+		{
+			env: map[string]string{
+				"GITLAB_CI":          "true",
+				"CI_PIPELINE_NAME":   "build",
+				"CI_JOB_NAME":        "publish",
+				"CI_JOB_URL":         "https://gitlab.com/org/repo/-/jobs/42",
+				"CI_PROJECT_URL":     "https://gitlab.com/org/repo",
+				"CI_COMMIT_REF_NAME": "main",
+				"CI_COMMIT_SHA":      "abc",
+				"CI_PIPELINE_SOURCE": "merge_request_event",
+				"CI_PROJECT_PATH":    "org/repo",
+				"CI_PIPELINE_IID":    "7",
+				"GITLAB_USER_LOGIN":  "octocat",
+			},
+			want: []string{"build", "publish", "https://gitlab.com/org/repo/-/jobs/42", "https://gitlab.com/org/repo", "main", "abc", "pull_request", "org/repo", "7", "octocat"},
+		},
+		{
+			env: map[string]string{
+				"CI":                 "woodpecker",
+				"CI_WORKFLOW_NAME":   "build",
+				"CI_STEP_NAME":       "publish",
+				"CI_PIPELINE_URL":    "https://ci.example.com/repos/1/pipeline/7",
+				"CI_REPO_URL":        "https://github.com/org/repo",
+				"CI_COMMIT_BRANCH":   "main",
+				"CI_COMMIT_SHA":      "abc",
+				"CI_PIPELINE_EVENT":  "push",
+				"CI_REPO":            "org/repo",
+				"CI_PIPELINE_NUMBER": "7",
+				"CI_COMMIT_AUTHOR":   "octocat",
+			},
+			want: []string{"build", "publish", "https://ci.example.com/repos/1/pipeline/7", "https://github.com/org/repo", "main", "abc", "push", "org/repo", "7", "octocat"},
+		},
+		// older woodpecker versions
+		{
+			provider: providerWoodpecker,
+			env: map[string]string{
+				"CI_BUILD_LINK":   "https://ci.example.com/org/repo/7",
+				"CI_REPO_LINK":    "https://github.com/org/repo",
+				"CI_BUILD_EVENT":  "pull_request",
+				"CI_BUILD_NUMBER": "7",
+			},
+			want: []string{"", "", "https://ci.example.com/org/repo/7", "https://github.com/org/repo", "", "", "pull_request", "", "7", ""},
+		},
+	}
+
+	for _, test := range tests {
+		setEnvMap(test.env)
+
+		env, err := newBuildEnv(test.provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := buildEnvValues(env)
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+
+		unsetEnvMap(test.env)
+	}
+
+	_, err := newBuildEnv("jenkins")
+	if err == nil {
+		t.Errorf("unsupported provider should have failed")
+	}
+}
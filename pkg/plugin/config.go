@@ -0,0 +1,244 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repository config file holding default settings
+const defaultConfigFile = ".drone-bazel-ecr.yml"
+
+// path of the repository config file, which can be overridden with PLUGIN_CONFIG_FILE
+func configFilePath() string {
+	if path := os.Getenv("PLUGIN_CONFIG_FILE"); path != "" {
+		return path
+	}
+
+	return defaultConfigFile
+}
+
+// set plugin env vars from the config file unless they are already set
+func applyConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	settings, err := parseSettings(path, data)
+	if err != nil {
+		return err
+	}
+
+	return applySettings(settings, false)
+}
+
+// set plugin env vars from the mounted settings file, overriding env settings
+func applySettingsFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	settings, err := parseSettings(path, data)
+	if err != nil {
+		return err
+	}
+
+	return applySettings(settings, true)
+}
+
+// parse YAML or JSON settings
+func parseSettings(path string, data []byte) (map[string]interface{}, error) {
+	var settings map[string]interface{}
+
+	// JSON documents are valid YAML
+	err := yaml.Unmarshal(data, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse settings file %s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+// set plugin env vars from settings keyed by their drone setting name
+func applySettings(settings map[string]interface{}, override bool) error {
+	for key, val := range settings {
+		env := "PLUGIN_" + strings.ToUpper(key)
+
+		// only replace settings that are already set when overriding
+		if _, ok := os.LookupEnv(env); ok && !override {
+			continue
+		}
+
+		value, err := settingValue(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for setting %s: %w", key, err)
+		}
+
+		os.Setenv(env, value)
+	}
+
+	return nil
+}
+
+// format a setting value the same way drone passes it to plugins
+func settingValue(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		// lists of objects such as the matrix are passed as JSON
+		for _, item := range val {
+			if _, ok := item.(map[string]interface{}); ok {
+				data, err := json.Marshal(val)
+				return string(data), err
+			}
+		}
+
+		var items []string
+		for _, item := range val {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		var items []string
+		for key, item := range val {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, fmt.Sprintf("%s:%s", key, s))
+		}
+		// maps are unordered
+		sort.Strings(items)
+		return strings.Join(items, ","), nil
+	default:
+		return fmt.Sprint(val), nil
+	}
+}
+
+// renamed setting that is still accepted under its old name
+type settingAlias struct {
+	old     string
+	new     string
+	removal string
+}
+
+// settings accepted under their old names until the release they are removed in
+var settingAliases = []settingAlias{
+	// spelled out like query_expression
+	{old: "cquery_starlark_expr", new: "cquery_starlark_expression", removal: "v2"},
+}
+
+// settings holding credentials, only read by the plugin itself
+var secretSettings = []string{"access_key", "secret_key", "netrc", "git_token", "ssh_key", "tls_client_key", "source_password", "webhook_secret", "service_token"}
+
+// remove the secret settings, and their old names, from the environment once loaded
+func unsetSecretSettings(secrets []string, aliases []settingAlias) {
+	for _, secret := range secrets {
+		os.Unsetenv("PLUGIN_" + strings.ToUpper(secret))
+
+		for _, alias := range aliases {
+			if alias.new == secret {
+				os.Unsetenv("PLUGIN_" + strings.ToUpper(alias.old))
+			}
+		}
+	}
+}
+
+// set renamed plugin env vars from their old names, warning about the deprecation
+func applyAliases(aliases []settingAlias) {
+	for _, alias := range aliases {
+		oldEnv := "PLUGIN_" + strings.ToUpper(alias.old)
+		val, ok := os.LookupEnv(oldEnv)
+		if !ok {
+			continue
+		}
+
+		log.Printf("setting %s is deprecated and will be removed in %s, use %s instead", alias.old, alias.removal, alias.new)
+
+		// the new name takes precedence when both are set
+		newEnv := "PLUGIN_" + strings.ToUpper(alias.new)
+		if _, ok := os.LookupEnv(newEnv); ok {
+			continue
+		}
+
+		os.Setenv(newEnv, val)
+	}
+}
+
+// references to drone and CI env vars in setting values, optionally with a
+// substring (:offset or :offset:length) or a default value (:-default)
+var settingRefPattern = regexp.MustCompile(`\$\{((?:DRONE|CI)_[A-Za-z0-9_]+)(?::-([^}]*)|:([0-9]+)(?::([0-9]+))?)?\}`)
+
+// expand ${DRONE_*} and ${CI_*} references in all plugin env vars
+func interpolateSettings() {
+	for _, kv := range os.Environ() {
+		key, val, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, "PLUGIN_") || !strings.Contains(val, "${") {
+			continue
+		}
+
+		os.Setenv(key, interpolate(val))
+	}
+}
+
+// replace drone and CI env var references, leaving other references untouched
+func interpolate(val string) string {
+	return settingRefPattern.ReplaceAllStringFunc(val, func(ref string) string {
+		match := settingRefPattern.FindStringSubmatch(ref)
+		val := os.Getenv(match[1])
+
+		switch {
+		case strings.HasPrefix(ref, "${"+match[1]+":-"):
+			if val == "" {
+				return match[2]
+			}
+		case match[3] != "":
+			return substring(val, match[3], match[4])
+		}
+
+		return val
+	})
+}
+
+// substring of val like ${VAR:offset:length} in shell, clamped to the length of val
+func substring(val, offset, length string) string {
+	start, _ := strconv.Atoi(offset)
+	if start > len(val) {
+		return ""
+	}
+
+	val = val[start:]
+	if length == "" {
+		return val
+	}
+
+	n, _ := strconv.Atoi(length)
+	if n < len(val) {
+		val = val[:n]
+	}
+
+	return val
+}
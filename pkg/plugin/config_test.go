@@ -0,0 +1,218 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		defaultConfigFile: `
+bazelrc: .bazelrc.ci
+repository: from-file
+create_repository: true
+command_args:
+  - --config=ci
+  - --config=remote
+`,
+		"invalid.yml": "bazelrc: [",
+	})
+
+	env := map[string]string{
+		"PLUGIN_REPOSITORY": "from-env",
+	}
+	setEnvMap(env)
+	defer unsetEnvMap(map[string]string{
+		"PLUGIN_REPOSITORY":        "",
+		"PLUGIN_BAZELRC":           "",
+		"PLUGIN_CREATE_REPOSITORY": "",
+		"PLUGIN_COMMAND_ARGS":      "",
+	})
+
+	err := applyConfigFile(filepath.Join(dir, defaultConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"PLUGIN_REPOSITORY":        "from-env",
+		"PLUGIN_BAZELRC":           ".bazelrc.ci",
+		"PLUGIN_CREATE_REPOSITORY": "true",
+		"PLUGIN_COMMAND_ARGS":      "--config=ci,--config=remote",
+	}
+
+	for key, val := range want {
+		if got := os.Getenv(key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, val, got)
+		}
+	}
+
+	// missing config files are ignored
+	err = applyConfigFile(filepath.Join(dir, "missing.yml"))
+	if err != nil {
+		t.Errorf("missing config file should be ignored: %v", err)
+	}
+
+	err = applyConfigFile(filepath.Join(dir, "invalid.yml"))
+	if err == nil {
+		t.Errorf("invalid config file should have failed")
+	}
+}
+
+func TestSettingValue(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want string
+	}{
+		{val: "value", want: "value"},
+		{val: 3, want: "3"},
+		{val: false, want: "false"},
+		{val: nil, want: ""},
+		{val: []interface{}{"a", "b"}, want: "a,b"},
+		{val: map[string]interface{}{"b": 2, "a": "1"}, want: "a:1,b:2"},
+		{val: []interface{}{map[string]interface{}{"target": "//:push"}}, want: `[{"target":"//:push"}]`},
+	}
+
+	for _, test := range tests {
+		got, err := settingValue(test.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestApplySettingsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"settings.json": `{"repository": "from-file", "command_args": ["--config=team"]}`,
+	})
+
+	setEnvMap(map[string]string{"PLUGIN_REPOSITORY": "from-env"})
+	defer unsetEnvMap(map[string]string{
+		"PLUGIN_REPOSITORY":   "",
+		"PLUGIN_COMMAND_ARGS": "",
+	})
+
+	err := applySettingsFile(filepath.Join(dir, "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// settings file values override env settings
+	want := map[string]string{
+		"PLUGIN_REPOSITORY":   "from-file",
+		"PLUGIN_COMMAND_ARGS": "--config=team",
+	}
+
+	for key, val := range want {
+		if got := os.Getenv(key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, val, got)
+		}
+	}
+
+	// the settings file is optional
+	err = applySettingsFile("")
+	if err != nil {
+		t.Errorf("unset settings file should be ignored: %v", err)
+	}
+
+	// but must exist when configured
+	err = applySettingsFile(filepath.Join(dir, "missing.json"))
+	if err == nil {
+		t.Errorf("missing settings file should have failed")
+	}
+}
+
+func TestInterpolateSettings(t *testing.T) {
+	env := map[string]string{
+		"DRONE_REPO_NAME":     "service",
+		"CI_COMMIT_SHA":       "abc123",
+		"PLUGIN_REPOSITORY":   "team/${DRONE_REPO_NAME}",
+		"PLUGIN_TAG":          "${CI_COMMIT_SHA}-${DRONE_MISSING}",
+		"PLUGIN_COMMAND_ARGS": "--define=home=${HOME}",
+		"PLUGIN_EMBED_LABEL":  "${CI_COMMIT_SHA:0:3}-${CI_COMMIT_SHA:4}-${CI_COMMIT_SHA:1:99}",
+		"PLUGIN_TAG_SUFFIX":   "${DRONE_MISSING:-dev}-${DRONE_REPO_NAME:-dev}",
+	}
+	setEnvMap(env)
+	defer unsetEnvMap(env)
+
+	interpolateSettings()
+
+	want := map[string]string{
+		"PLUGIN_REPOSITORY": "team/service",
+		"PLUGIN_TAG":        "abc123-",
+		// only drone and CI variables are expanded
+		"PLUGIN_COMMAND_ARGS": "--define=home=${HOME}",
+		"PLUGIN_EMBED_LABEL":  "abc-23-bc123",
+		"PLUGIN_TAG_SUFFIX":   "dev-service",
+	}
+
+	for key, val := range want {
+		if got := os.Getenv(key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, val, got)
+		}
+	}
+}
+
+func TestApplyAliases(t *testing.T) {
+	aliases := []settingAlias{
+		{old: "image_target", new: "target", removal: "v2"},
+		{old: "args", new: "command_args", removal: "v2"},
+		{old: "unused", new: "tag", removal: "v2"},
+	}
+
+	env := map[string]string{
+		"PLUGIN_IMAGE_TARGET": "//:push",
+		"PLUGIN_ARGS":         "--config=old",
+		"PLUGIN_COMMAND_ARGS": "--config=new",
+	}
+	setEnvMap(env)
+	defer unsetEnvMap(env)
+	defer os.Unsetenv("PLUGIN_TARGET")
+
+	applyAliases(aliases)
+
+	want := map[string]string{
+		"PLUGIN_TARGET": "//:push",
+		// the new name takes precedence
+		"PLUGIN_COMMAND_ARGS": "--config=new",
+	}
+
+	for key, val := range want {
+		if got := os.Getenv(key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, val, got)
+		}
+	}
+
+	if _, ok := os.LookupEnv("PLUGIN_TAG"); ok {
+		t.Errorf("PLUGIN_TAG should not be set")
+	}
+}
+
+func TestUnsetSecretSettings(t *testing.T) {
+	env := map[string]string{
+		"PLUGIN_GIT_TOKEN": "token",
+		"PLUGIN_OLD_TOKEN": "token",
+		"PLUGIN_TARGET":    "//:push",
+	}
+	setEnvMap(env)
+	defer unsetEnvMap(env)
+
+	unsetSecretSettings([]string{"git_token"}, []settingAlias{{old: "old_token", new: "git_token", removal: "v2"}})
+
+	for _, key := range []string{"PLUGIN_GIT_TOKEN", "PLUGIN_OLD_TOKEN"} {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Errorf("%s should not be set", key)
+		}
+	}
+
+	if os.Getenv("PLUGIN_TARGET") != "//:push" {
+		t.Errorf("PLUGIN_TARGET should be kept")
+	}
+}
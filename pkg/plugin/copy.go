@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// copies the source image to the target repository without modifying its manifest
+func (p *Config) copyImage(svc ecriface.ECRAPI) error {
+	src, err := name.ParseReference(p.Source)
+	if err != nil {
+		return err
+	}
+
+	// the tag of the source unless the tag was set
+	tag := p.Tag
+	if tag == "" {
+		tag = src.Identifier()
+	}
+
+	dst, err := p.imageRef(tag)
+	if err != nil {
+		return err
+	}
+
+	auth, err := p.registryAuth(svc)
+	if err != nil {
+		return err
+	}
+
+	desc, err := remote.Get(src, remote.WithAuth(p.sourceAuth(src, auth)))
+	if err != nil {
+		return err
+	}
+
+	// indexes are written as-is so multi-arch digests are preserved
+	var image artifact
+	if desc.MediaType.IsIndex() {
+		image, err = desc.ImageIndex()
+	} else {
+		image, err = desc.Image()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = p.writeImage(svc, dst, image, auth)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("copied %s to %s@%s", src, dst.Context(), desc.Digest)
+	return nil
+}
+
+// default the tag of copied images to the tag of the source, so that the post-push
+// steps and outputs refer to the copied image instead of latest
+func (p *Config) applySourceTag() error {
+	if p.Mode != modeCopy || p.Tag != "" {
+		return nil
+	}
+
+	src, err := name.ParseReference(p.Source)
+	if err != nil {
+		return err
+	}
+
+	// the tagging, verification and outputs after the push need a tag
+	tag, ok := src.(name.Tag)
+	if !ok {
+		return fmt.Errorf("copying the digest %s requires a tag", p.Source)
+	}
+	p.Tag = tag.TagStr()
+
+	return nil
+}
+
+// credentials used to pull the source image
+func (p *Config) sourceAuth(src name.Reference, auth authn.Authenticator) authn.Authenticator {
+	// images already in the target registry reuse the ECR credentials
+	if src.Context().RegistryStr() == p.Registry {
+		return auth
+	}
+
+	if p.SourceUsername != "" && p.SourcePassword != "" {
+		return authn.FromConfig(authn.AuthConfig{Username: p.SourceUsername, Password: p.SourcePassword})
+	}
+
+	return authn.Anonymous
+}
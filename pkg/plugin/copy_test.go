@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// start an in-memory registry and return its host
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Host
+}
+
+func TestCopyImage(t *testing.T) {
+	host := newTestRegistry(t)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := name.ParseReference(fmt.Sprintf("%s/upstream/image:1.0", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = remote.Write(src, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		p   Config
+		ref string
+	}{
+		// default to the source tag
+		{
+			p:   Config{Registry: host, Repository: "vendored/image", Source: src.String()},
+			ref: fmt.Sprintf("%s/vendored/image:1.0", host),
+		},
+		// override the tag
+		{
+			p:   Config{Registry: host, Repository: "vendored/image", Source: src.String(), Tag: "stable"},
+			ref: fmt.Sprintf("%s/vendored/image:stable", host),
+		},
+	}
+
+	for _, test := range tests {
+		testFailure = ""
+
+		err := test.p.copyImage(&mockECRClient{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, err := name.ParseReference(test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		desc, err := remote.Head(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if desc.Digest != want {
+			t.Errorf("%v is not equal to %v", want, desc.Digest)
+		}
+	}
+}
+
+func TestCopyImageFailure(t *testing.T) {
+	host := newTestRegistry(t)
+
+	tests := []struct {
+		p       Config
+		failure string
+	}{
+		{
+			p: Config{Registry: host, Source: "upstream/image:1.0"},
+		},
+		{
+			p:       Config{Registry: host, Repository: "vendored/image", Source: "upstream/image:1.0"},
+			failure: "GetAuthorizationToken",
+		},
+		{
+			p: Config{Registry: host, Repository: "vendored/image", Source: fmt.Sprintf("%s/missing/image:1.0", host)},
+		},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+
+		err := test.p.copyImage(&mockECRClient{})
+		if err == nil {
+			t.Errorf("%+v: copy should have failed", test.p)
+		}
+	}
+
+	testFailure = ""
+}
+
+func TestApplySourceTag(t *testing.T) {
+	digest := "docker.io/library/nginx@sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		p   Config
+		tag string
+		err bool
+	}{
+		{p: Config{Mode: modeCopy, Source: "docker.io/library/nginx:1.25"}, tag: "1.25"},
+		{p: Config{Mode: modeCopy, Source: "docker.io/library/nginx:1.25", Tag: "stable"}, tag: "stable"},
+		// digests can not be tags
+		{p: Config{Mode: modeCopy, Source: digest}, err: true},
+		{p: Config{Mode: modeCopy, Source: digest, Tag: "1.25"}, tag: "1.25"},
+		{p: Config{Source: "docker.io/library/nginx:1.25"}},
+	}
+
+	for _, test := range tests {
+		err := test.p.applySourceTag()
+		if (err != nil) != test.err {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if test.p.Tag != test.tag {
+			t.Errorf("got tag %q, want %q", test.p.Tag, test.tag)
+		}
+	}
+}
+
+func TestRunCopyPullRequest(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "pull_request")
+
+	// the source is unreachable, so copying it would fail the step
+	cfg := Config{
+		Mode:       modeCopy,
+		Source:     "localhost:1/missing:1",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+	}
+
+	err := Run(context.Background(), cfg, WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Errorf("pull requests should not copy: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package plugin
+
+// flags of the cquery command, capturing its structured output in a file
+func (p *Config) cqueryArgs() []string {
+	var args []string
+
+	if p.CqueryOutput != "" {
+		args = append(args, joinFlag("--output", p.CqueryOutput))
+	}
+	if p.CqueryStarlarkExpr != "" {
+		args = append(args, joinFlag("--starlark:expr", p.CqueryStarlarkExpr))
+	}
+	if p.CqueryFile != "" {
+		args = append(args, joinFlag("--output_file", p.CqueryFile))
+	}
+
+	return args
+}
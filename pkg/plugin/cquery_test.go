@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCqueryArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "//app:image", Command: "cquery"},
+			want:   []string{"cquery", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//app:image"},
+		},
+		{
+			plugin: Config{Target: "//app:image", Command: "cquery", CqueryOutput: "jsonproto", CqueryFile: "cquery.json"},
+			want:   []string{"cquery", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--output=jsonproto", "--output_file=cquery.json", "//app:image"},
+		},
+		{
+			plugin: Config{Target: "//app:image", Command: "cquery", CqueryOutput: "starlark", CqueryStarlarkExpr: "target.label"},
+			want:   []string{"cquery", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--output=starlark", "--starlark:expr=target.label", "//app:image"},
+		},
+		// cquery settings only apply to cquery
+		{
+			plugin: Config{Target: "//app:image", Command: "build", CqueryOutput: "jsonproto"},
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//app:image"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.getArgs(newBuildMock())
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// category of the critical path events in the JSON trace profile
+const criticalPathCategory = "critical path component"
+
+// action on the critical path of the build
+type criticalAction struct {
+	Description string
+	Duration    time.Duration
+}
+
+// subset of the JSON trace profile written by bazel with --profile
+type traceProfile struct {
+	TraceEvents []struct {
+		Category string `json:"cat"`
+		Name     string `json:"name"`
+		// microseconds
+		Duration float64 `json:"dur"`
+	} `json:"traceEvents"`
+}
+
+// read the critical path components from the trace profile, in the order of the path
+func parseCriticalPath(path string) ([]criticalAction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var profile traceProfile
+	err = json.NewDecoder(f).Decode(&profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []criticalAction
+	for _, event := range profile.TraceEvents {
+		if event.Category == criticalPathCategory {
+			actions = append(actions, criticalAction{
+				Description: event.Name,
+				Duration:    time.Duration(event.Duration) * time.Microsecond,
+			})
+		}
+	}
+
+	return actions, nil
+}
+
+// print the total critical path and its n slowest actions
+func printCriticalPath(w io.Writer, actions []criticalAction, n int) {
+	var total time.Duration
+	for _, action := range actions {
+		total += action.Duration
+	}
+
+	slowest := make([]criticalAction, len(actions))
+	copy(slowest, actions)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+
+	fmt.Fprintf(w, "critical path of %d actions took %.1fs, slowest actions:\n", len(actions), total.Seconds())
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, action := range slowest {
+		fmt.Fprintf(tw, "  %.1fs\t%.0f%%\t%s\n", action.Duration.Seconds(), 100*action.Duration.Seconds()/total.Seconds(), action.Description)
+	}
+	tw.Flush()
+}
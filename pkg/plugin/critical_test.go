@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCriticalPath(t *testing.T) {
+	got, err := parseCriticalPath(filepath.Join("testdata", "profile.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []criticalAction{
+		{Description: "action 'Compiling app/main.go'", Duration: 4500 * time.Millisecond},
+		{Description: "action 'GoLink app/app_/app'", Duration: 9 * time.Second},
+		{Description: "action 'Action app/image/blobsum'", Duration: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%+v is not equal to %+v", want, got)
+	}
+
+	var buf bytes.Buffer
+	printCriticalPath(&buf, got, 2)
+
+	wantOutput := `critical path of 3 actions took 15.0s, slowest actions:
+  9.0s  60%  action 'GoLink app/app_/app'
+  4.5s  30%  action 'Compiling app/main.go'
+`
+	if buf.String() != wantOutput {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), wantOutput)
+	}
+
+	_, err = parseCriticalPath(filepath.Join("testdata", "missing.json"))
+	if err == nil {
+		t.Errorf("missing profile should have failed")
+	}
+}
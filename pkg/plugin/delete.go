@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// maximum number of image IDs accepted by BatchDeleteImage
+const batchDeleteSize = 100
+
+// delete the tags matching the delete patterns, keeping the most recently pushed images
+func (p *Config) deleteImages(svc ecriface.ECRAPI) error {
+	images, err := p.listImages(svc)
+	if err != nil {
+		return err
+	}
+
+	tags, err := p.deleteTags(images)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		log.Printf("no tags in %s match the delete patterns", p.Repository)
+		return nil
+	}
+
+	for _, tag := range tags {
+		if p.DryRun {
+			log.Printf("would delete %s:%s", p.Repository, tag)
+		} else {
+			log.Printf("deleting %s:%s", p.Repository, tag)
+		}
+	}
+	if p.DryRun {
+		return nil
+	}
+
+	for start := 0; start < len(tags); start += batchDeleteSize {
+		end := start + batchDeleteSize
+		if end > len(tags) {
+			end = len(tags)
+		}
+
+		var ids []*ecr.ImageIdentifier
+		for _, tag := range tags[start:end] {
+			ids = append(ids, &ecr.ImageIdentifier{ImageTag: aws.String(tag)})
+		}
+
+		result, err := svc.BatchDeleteImage(&ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(p.Repository),
+			ImageIds:       ids,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(result.Failures) > 0 {
+			failure := result.Failures[0]
+			return fmt.Errorf("could not delete %s:%s: %s", p.Repository, aws.StringValue(failure.ImageId.ImageTag), aws.StringValue(failure.FailureReason))
+		}
+	}
+
+	return nil
+}
+
+// all images in the repository
+func (p *Config) listImages(svc ecriface.ECRAPI) ([]*ecr.ImageDetail, error) {
+	var images []*ecr.ImageDetail
+
+	input := &ecr.DescribeImagesInput{RepositoryName: aws.String(p.Repository)}
+	err := svc.DescribeImagesPages(input, func(page *ecr.DescribeImagesOutput, last bool) bool {
+		images = append(images, page.ImageDetails...)
+		return true
+	})
+
+	return images, err
+}
+
+// tags matching the delete patterns, except those of the keep_latest most recently pushed images
+func (p *Config) deleteTags(images []*ecr.ImageDetail) ([]string, error) {
+	match, err := p.deleteMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*ecr.ImageDetail
+	for _, image := range images {
+		for _, tag := range image.ImageTags {
+			if match(aws.StringValue(tag)) {
+				matched = append(matched, image)
+				break
+			}
+		}
+	}
+
+	// newest first
+	sort.SliceStable(matched, func(i, j int) bool {
+		return aws.TimeValue(matched[i].ImagePushedAt).After(aws.TimeValue(matched[j].ImagePushedAt))
+	})
+
+	var tags []string
+	for i, image := range matched {
+		if i < p.KeepLatest {
+			continue
+		}
+
+		for _, tag := range image.ImageTags {
+			if match(aws.StringValue(tag)) {
+				tags = append(tags, aws.StringValue(tag))
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// match tags against the delete_tags globs or the delete_tag_regex
+func (p *Config) deleteMatcher() (func(string) bool, error) {
+	if p.DeleteTagRegex != "" {
+		re, err := regexp.Compile(p.DeleteTagRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delete tag regex: %w", err)
+		}
+
+		return re.MatchString, nil
+	}
+
+	for _, pattern := range p.DeleteTags {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid delete tag pattern: %s", pattern)
+		}
+	}
+
+	return func(tag string) bool {
+		return matchAny(p.DeleteTags, tag)
+	}, nil
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// serves a fixed list of images and records deleted tags
+type mockImagesClient struct {
+	mockECRClient
+
+	images  []*ecr.ImageDetail
+	deleted []string
+}
+
+// image pushed the given number of days ago
+func testImage(digest string, days int, tags ...string) *ecr.ImageDetail {
+	return &ecr.ImageDetail{
+		ImageDigest:      aws.String(digest),
+		ImageTags:        aws.StringSlice(tags),
+		ImagePushedAt:    aws.Time(time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -days)),
+		ImageSizeInBytes: aws.Int64(int64(1024 * days)),
+	}
+}
+
+func (m *mockImagesClient) DescribeImagesPages(input *ecr.DescribeImagesInput, fn func(*ecr.DescribeImagesOutput, bool) bool) error {
+	// serve one image per page
+	for i, image := range m.images {
+		if !fn(&ecr.DescribeImagesOutput{ImageDetails: []*ecr.ImageDetail{image}}, i == len(m.images)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockImagesClient) BatchDeleteImage(input *ecr.BatchDeleteImageInput) (*ecr.BatchDeleteImageOutput, error) {
+	for _, id := range input.ImageIds {
+		m.deleted = append(m.deleted, aws.StringValue(id.ImageTag))
+	}
+
+	return &ecr.BatchDeleteImageOutput{ImageIds: input.ImageIds}, nil
+}
+
+func TestDeleteImages(t *testing.T) {
+	images := []*ecr.ImageDetail{
+		testImage("sha256:a", 1, "main", "v2"),
+		testImage("sha256:b", 3, "feature-a"),
+		testImage("sha256:c", 2, "feature-b", "v1"),
+		testImage("sha256:d", 5, "feature-c"),
+	}
+
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{DeleteTags: []string{"feature-*"}},
+			want:   []string{"feature-b", "feature-a", "feature-c"},
+		},
+		{
+			plugin: Config{DeleteTags: []string{"feature-*"}, KeepLatest: 2},
+			want:   []string{"feature-c"},
+		},
+		{
+			plugin: Config{DeleteTagRegex: "^v[0-9]+$"},
+			want:   []string{"v2", "v1"},
+		},
+		{
+			plugin: Config{DeleteTags: []string{"release-*"}},
+		},
+		{
+			plugin: Config{DeleteTags: []string{"feature-*"}, DryRun: true},
+		},
+	}
+
+	for _, test := range tests {
+		svc := &mockImagesClient{images: images}
+		test.plugin.Repository = "test"
+
+		err := test.plugin.deleteImages(svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(test.want, svc.deleted) {
+			t.Errorf("%v is not equal to %v", test.want, svc.deleted)
+		}
+	}
+
+	p := Config{Repository: "test", DeleteTagRegex: "("}
+	err := p.deleteImages(&mockImagesClient{images: images})
+	if err == nil {
+		t.Errorf("invalid regex should have failed")
+	}
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// write the ECR credentials of the registry to docker_config_output in the docker config
+// format, so that later steps can pull the pushed image with DOCKER_CONFIG set to its directory
+func (p *Config) writeDockerConfig(svc ecriface.ECRAPI) error {
+	result, err := svc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{
+		RegistryIds: aws.StringSlice([]string{p.registryID()}),
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.AuthorizationData) == 0 {
+		return fmt.Errorf("no authorization token returned for %s", p.Registry)
+	}
+	data := result.AuthorizationData[0]
+
+	// keep the other entries of an existing config
+	config := map[string]interface{}{}
+	body, err := os.ReadFile(p.DockerConfigOutput)
+	if err == nil {
+		err = json.Unmarshal(body, &config)
+		if err != nil {
+			return fmt.Errorf("could not parse docker config %s: %w", p.DockerConfigOutput, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	auths, ok := config["auths"].(map[string]interface{})
+	if !ok {
+		auths = map[string]interface{}{}
+	}
+	// the token is the base64 encoded user:password pair expected by docker
+	auths[p.Registry] = map[string]string{"auth": aws.StringValue(data.AuthorizationToken)}
+	config["auths"] = auths
+
+	body, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.DockerConfigOutput), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(p.DockerConfigOutput, body, 0600)
+	if err != nil {
+		return err
+	}
+
+	if data.ExpiresAt != nil {
+		log.Printf("wrote credentials for %s to %s, valid until %s", p.Registry, p.DockerConfigOutput, data.ExpiresAt.UTC().Format("2006-01-02 15:04:05 MST"))
+	} else {
+		log.Printf("wrote credentials for %s to %s", p.Registry, p.DockerConfigOutput)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteDockerConfig(t *testing.T) {
+	testFailure = ""
+	path := filepath.Join(t.TempDir(), ".docker", "config.json")
+
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", DockerConfigOutput: path}
+	err := p.writeDockerConfig(&mockECRClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries of other registries are kept
+	err = os.WriteFile(path, []byte(`{"auths": {"ghcr.io": {"auth": "Z2hjcg=="}}, "credsStore": "desktop"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.writeDockerConfig(&mockECRClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"auths": map[string]interface{}{
+			"ghcr.io": map[string]interface{}{"auth": "Z2hjcg=="},
+			"0123456789.dkr.ecr.us-east-1.amazonaws.com": map[string]interface{}{"auth": "QVdTOnBhc3N3b3Jk"},
+		},
+		"credsStore": "desktop",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%v is not equal to %v", want, got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions: %s", info.Mode())
+	}
+
+	testFailure = "GetAuthorizationToken"
+	defer func() { testFailure = "" }()
+	if p.writeDockerConfig(&mockECRClient{}) == nil {
+		t.Errorf("failed token request should have failed")
+	}
+}
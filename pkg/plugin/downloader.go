@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// hosts rewritten to downloader_mirror when downloader_hosts is not set
+var defaultDownloaderHosts = []string{"github.com", "storage.googleapis.com"}
+
+// downloader config rewriting the URLs of the hosts to the mirror, keeping the host in the path,
+// e.g. github.com/org/repo/archive/v1.tar.gz to artifactory.example.com/remote/github.com/org/repo/archive/v1.tar.gz
+func (p *Config) downloaderConfig() string {
+	if p.DownloaderConfig != "" {
+		return p.DownloaderConfig
+	}
+
+	hosts := p.DownloaderHosts
+	if len(hosts) == 0 {
+		hosts = defaultDownloaderHosts
+	}
+
+	// rewrites match the URLs without the scheme, https is used for the rewritten URLs
+	mirror := strings.TrimSuffix(strings.TrimPrefix(p.DownloaderMirror, "https://"), "/")
+
+	var b strings.Builder
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		fmt.Fprintf(&b, "rewrite ^(%s)/(.*) %s/$1/$2\n", regexp.QuoteMeta(host), mirror)
+	}
+
+	return b.String()
+}
+
+// write the downloader config for bazel to read, returning the file to remove once bazel has run
+func (p *Config) writeDownloaderConfig() (string, error) {
+	f, err := os.CreateTemp(p.tempDir, "downloader-*.cfg")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(p.downloaderConfig())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	p.downloaderConfigFile = f.Name()
+
+	return f.Name(), nil
+}
+
+// flag passing the downloader config to bazel
+func (p *Config) downloaderArgs() []string {
+	if p.downloaderConfigFile == "" {
+		return nil
+	}
+
+	return []string{joinFlag("--experimental_downloader_config", p.downloaderConfigFile)}
+}
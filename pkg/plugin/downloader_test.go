@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDownloaderConfig(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		config string
+	}{
+		{
+			plugin: Config{DownloaderMirror: "https://artifactory.example.com/remote/"},
+			config: "rewrite ^(github\\.com)/(.*) artifactory.example.com/remote/$1/$2\n" +
+				"rewrite ^(storage\\.googleapis\\.com)/(.*) artifactory.example.com/remote/$1/$2\n",
+		},
+		{
+			plugin: Config{DownloaderMirror: "mirror.internal", DownloaderHosts: []string{"dl.google.com"}},
+			config: "rewrite ^(dl\\.google\\.com)/(.*) mirror.internal/$1/$2\n",
+		},
+		{
+			plugin: Config{DownloaderConfig: "block *\n"},
+			config: "block *\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.plugin.downloaderConfig(); got != test.config {
+			t.Errorf("got %q, want %q", got, test.config)
+		}
+	}
+}
+
+func TestWriteDownloaderConfig(t *testing.T) {
+	p := Config{DownloaderConfig: "block *\n"}
+	if p.downloaderArgs() != nil {
+		t.Errorf("unexpected args %v", p.downloaderArgs())
+	}
+
+	path, err := p.writeDownloaderConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	data, _ := os.ReadFile(path)
+	if string(data) != p.DownloaderConfig {
+		t.Errorf("got %q, want %q", data, p.DownloaderConfig)
+	}
+
+	want := []string{"--experimental_downloader_config=" + path}
+	if !reflect.DeepEqual(p.downloaderArgs(), want) {
+		t.Errorf("got %v, want %v", p.downloaderArgs(), want)
+	}
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// desired repository settings, using the field names of the ECR API
+type repositorySpec struct {
+	ImageTagMutability         string                          `json:"imageTagMutability"`
+	EncryptionConfiguration    *ecr.EncryptionConfiguration    `json:"encryptionConfiguration"`
+	ImageScanningConfiguration *ecr.ImageScanningConfiguration `json:"imageScanningConfiguration"`
+	LifecyclePolicy            json.RawMessage                 `json:"lifecyclePolicy"`
+}
+
+// compare the settings of the repository with repository_spec, failing or warning on drift
+func (p *Config) checkRepositoryDrift(svc ecriface.ECRAPI) error {
+	data, err := readJSONSetting(p.RepositorySpec)
+	if err != nil {
+		return err
+	}
+
+	var spec repositorySpec
+	err = json.Unmarshal([]byte(data), &spec)
+	if err != nil {
+		return fmt.Errorf("could not parse repository spec: %w", err)
+	}
+
+	result, err := svc.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RepositoryNames: aws.StringSlice([]string{p.Repository}),
+		RegistryId:      aws.String(p.registryID()),
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Repositories) == 0 {
+		return fmt.Errorf("repository not found: %s", p.Repository)
+	}
+	repo := result.Repositories[0]
+
+	var drift []string
+	if spec.ImageTagMutability != "" && spec.ImageTagMutability != aws.StringValue(repo.ImageTagMutability) {
+		drift = append(drift, fmt.Sprintf("imageTagMutability is %s instead of %s", aws.StringValue(repo.ImageTagMutability), spec.ImageTagMutability))
+	}
+
+	if want := spec.EncryptionConfiguration; want != nil {
+		got := repo.EncryptionConfiguration
+		if got == nil {
+			got = &ecr.EncryptionConfiguration{EncryptionType: aws.String(ecr.EncryptionTypeAes256)}
+		}
+		if aws.StringValue(want.EncryptionType) != aws.StringValue(got.EncryptionType) ||
+			(want.KmsKey != nil && aws.StringValue(want.KmsKey) != aws.StringValue(got.KmsKey)) {
+			drift = append(drift, fmt.Sprintf("encryption is %s %s instead of %s %s",
+				aws.StringValue(got.EncryptionType), aws.StringValue(got.KmsKey), aws.StringValue(want.EncryptionType), aws.StringValue(want.KmsKey)))
+		}
+	}
+
+	if want := spec.ImageScanningConfiguration; want != nil {
+		var got bool
+		if repo.ImageScanningConfiguration != nil {
+			got = aws.BoolValue(repo.ImageScanningConfiguration.ScanOnPush)
+		}
+		if got != aws.BoolValue(want.ScanOnPush) {
+			drift = append(drift, fmt.Sprintf("scanOnPush is %t instead of %t", got, aws.BoolValue(want.ScanOnPush)))
+		}
+	}
+
+	if len(spec.LifecyclePolicy) > 0 {
+		changed, err := p.lifecyclePolicyDrifted(svc, spec.LifecyclePolicy)
+		if err != nil {
+			return err
+		}
+		if changed {
+			drift = append(drift, "lifecyclePolicy differs")
+		}
+	}
+
+	if len(drift) == 0 {
+		log.Printf("repository %s matches the repository spec", p.Repository)
+		return nil
+	}
+
+	msg := fmt.Sprintf("repository %s drifted from the repository spec: %s", p.Repository, strings.Join(drift, ", "))
+	if p.RepositorySpecWarn {
+		log.Printf("warning: %s", msg)
+		return nil
+	}
+
+	return errors.New(msg)
+}
+
+// whether the lifecycle policy of the repository differs from the desired policy, ignoring formatting
+func (p *Config) lifecyclePolicyDrifted(svc ecriface.ECRAPI, desired json.RawMessage) (bool, error) {
+	want, err := normalizeJSON(string(desired))
+	if err != nil {
+		return false, fmt.Errorf("could not parse lifecycle policy: %w", err)
+	}
+
+	current, err := p.currentLifecyclePolicy(svc)
+	if err != nil {
+		return false, err
+	}
+
+	if current == "" {
+		return true, nil
+	}
+
+	got, err := normalizeJSON(current)
+	if err != nil {
+		return false, err
+	}
+
+	return want != got, nil
+}
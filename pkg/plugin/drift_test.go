@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// describes a repository with the given settings and lifecycle policy
+type mockDriftClient struct {
+	mockECRClient
+
+	repository *ecr.Repository
+	policy     string
+}
+
+func (m *mockDriftClient) DescribeRepositories(input *ecr.DescribeRepositoriesInput) (*ecr.DescribeRepositoriesOutput, error) {
+	return &ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{m.repository}}, nil
+}
+
+func (m *mockDriftClient) GetLifecyclePolicy(input *ecr.GetLifecyclePolicyInput) (*ecr.GetLifecyclePolicyOutput, error) {
+	if m.policy == "" {
+		return nil, awserr.New(ecr.ErrCodeLifecyclePolicyNotFoundException, "", errors.New("LifecyclePolicyNotFound"))
+	}
+
+	return &ecr.GetLifecyclePolicyOutput{LifecyclePolicyText: aws.String(m.policy)}, nil
+}
+
+func TestCheckRepositoryDrift(t *testing.T) {
+	repository := &ecr.Repository{
+		ImageTagMutability:         aws.String(ecr.ImageTagMutabilityImmutable),
+		EncryptionConfiguration:    &ecr.EncryptionConfiguration{EncryptionType: aws.String(ecr.EncryptionTypeKms), KmsKey: aws.String("key-1")},
+		ImageScanningConfiguration: &ecr.ImageScanningConfiguration{ScanOnPush: aws.Bool(true)},
+	}
+
+	tests := []struct {
+		spec   string
+		policy string
+		warn   bool
+		drift  string
+	}{
+		{
+			spec:   `{"imageTagMutability": "IMMUTABLE", "encryptionConfiguration": {"encryptionType": "KMS"}, "imageScanningConfiguration": {"scanOnPush": true}, "lifecyclePolicy": ` + testLifecyclePolicy + `}`,
+			policy: testLifecyclePolicy,
+		},
+		{
+			spec:  `{"imageTagMutability": "MUTABLE", "imageScanningConfiguration": {"scanOnPush": false}}`,
+			drift: "imageTagMutability is IMMUTABLE instead of MUTABLE, scanOnPush is true instead of false",
+		},
+		{
+			spec:  `{"encryptionConfiguration": {"encryptionType": "KMS", "kmsKey": "key-2"}}`,
+			drift: "encryption is KMS key-1 instead of KMS key-2",
+		},
+		// a missing lifecycle policy is drift
+		{
+			spec:  `{"lifecyclePolicy": ` + testLifecyclePolicy + `}`,
+			drift: "lifecyclePolicy differs",
+		},
+		{
+			spec: `{"imageTagMutability": "MUTABLE"}`,
+			warn: true,
+		},
+	}
+
+	for _, test := range tests {
+		p := Config{Repository: "test", RepositorySpec: test.spec, RepositorySpecWarn: test.warn}
+
+		err := p.checkRepositoryDrift(&mockDriftClient{repository: repository, policy: test.policy})
+		if test.drift == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.HasSuffix(err.Error(), test.drift) {
+			t.Errorf("%v does not end with %q", err, test.drift)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// query expression selecting the rules of target_kind matching the target pattern
+func (p *Config) expandQuery() string {
+	return fmt.Sprintf("kind(%q, %s)", p.TargetKind, p.Target)
+}
+
+// expand the target pattern into a matrix entry for each target of target_kind, so that
+// new push targets are pushed without changing the pipeline
+func (p *Config) expandTargets(ctx context.Context, runner Runner) error {
+	r, ok := runner.(outputRunner)
+	if !ok {
+		return fmt.Errorf("target_kind requires a runner returning the output of commands")
+	}
+
+	args := append(p.queryCommandArgs(), p.expandQuery(), "--output=label")
+
+	var stdout bytes.Buffer
+	err := r.RunOutput(ctx, "bazel", args, append(os.Environ(), p.environ()...), &stdout, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("could not expand %s: %w", p.Target, err)
+	}
+
+	targets := strings.Fields(stdout.String())
+	if len(targets) == 0 {
+		return fmt.Errorf("no %s targets match %s", p.TargetKind, p.Target)
+	}
+	log.Printf("expanded %s into %d %s targets", p.Target, len(targets), p.TargetKind)
+
+	p.Matrix = p.expandedMatrix(targets)
+
+	return nil
+}
+
+// matrix entries of the expanded targets, pushed to a repository named after their package,
+// and after their name as well when the package has several targets
+func (p *Config) expandedMatrix(targets []string) matrix {
+	targetsPerPackage := map[string]int{}
+	for _, target := range targets {
+		targetsPerPackage[targetPackage(target)]++
+	}
+
+	entries := make(matrix, len(targets))
+	for i, target := range targets {
+		pkg := targetPackage(target)
+		repository := p.prefixRepository(defaultRepository(pkg))
+		if repository == "" {
+			repository = p.Repository
+		}
+
+		if targetsPerPackage[pkg] > 1 {
+			repository = defaultRepository(repository + "/" + targetName(target))
+		}
+
+		entries[i] = matrixEntry{Target: target, Repository: repository}
+	}
+
+	return entries
+}
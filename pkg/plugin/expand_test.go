@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// prints the labels of queried targets
+type labelRunner struct {
+	recordingRunner
+	labels []string
+}
+
+func (r *labelRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	if len(args) > 0 && args[0] == "query" {
+		fmt.Fprintln(stdout, strings.Join(r.labels, "\n"))
+	}
+	return r.Run(ctx, name, args, env)
+}
+
+func TestExpandedMatrix(t *testing.T) {
+	p := Config{Repository: "monorepo", RepositoryPrefix: "team/"}
+
+	got := p.expandedMatrix([]string{"//services/api:push", "//:push", "//services/web:push", "//services/web:push_debug"})
+	want := matrix{
+		{Target: "//services/api:push", Repository: "team/services/api"},
+		{Target: "//:push", Repository: "monorepo"},
+		// targets of the same package are told apart by their name
+		{Target: "//services/web:push", Repository: "team/services/web/push"},
+		{Target: "//services/web:push_debug", Repository: "team/services/web/push_debug"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRunExpandTargets(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:     "//services/...",
+		TargetKind: "oci_push",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+	}
+	runner := &labelRunner{labels: []string{"//services/api:push", "//services/web:push"}}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(runner.calls[0].args, " "); got != `query kind("oci_push", //services/...) --output=label` {
+		t.Errorf("unexpected query %s", got)
+	}
+
+	var targets []string
+	for _, call := range runner.calls[1:] {
+		targets = append(targets, call.args[len(call.args)-1])
+	}
+	want := []string{"//services/api:push", "//services/web:push"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("got targets %v, want %v", targets, want)
+	}
+
+	// patterns matching nothing fail the step
+	runner = &labelRunner{}
+	err = Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err == nil || !strings.Contains(err.Error(), "no oci_push targets") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// arguments of bazel fetch, or bazel sync when fetch_sync is set, warming the external
+// repositories and the repository cache for later builds
+func (p *Config) fetchArgs() []string {
+	args := p.startupArgs()
+
+	if p.FetchSync {
+		args = append(args, "sync")
+	} else {
+		args = append(args, "fetch")
+	}
+
+	args = append(args, p.bzlmodArgs()...)
+	args = append(args, p.downloaderArgs()...)
+
+	for _, dir := range p.Distdir {
+		args = append(args, joinFlag("--distdir", strings.TrimSpace(dir)))
+	}
+	if p.RepositoryCache != "" {
+		args = append(args, joinFlag("--repository_cache", p.RepositoryCache))
+	}
+
+	// sync fetches every external repository
+	if p.FetchSync {
+		return args
+	}
+
+	targets := p.FetchTargets
+	if len(targets) == 0 {
+		targets = []string{p.Target}
+	}
+	for _, target := range targets {
+		args = append(args, strings.TrimSpace(target))
+	}
+
+	return args
+}
+
+// fetch the external dependencies of the targets
+func (p *Config) fetch(ctx context.Context, runner Runner) error {
+	return runner.Run(ctx, "bazel", p.fetchArgs(), append(os.Environ(), p.environ()...))
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFetchArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "//:push"},
+			want:   []string{"fetch", "//:push"},
+		},
+		{
+			plugin: Config{Target: "//:push", FetchTargets: []string{"//app/...", " //lib/..."}, Bazelrc: ".bazelrc.ci", RepositoryCache: "/cache/repos", Distdir: []string{"/cache/distdir"}},
+			want:   []string{"--bazelrc=.bazelrc.ci", "fetch", "--distdir=/cache/distdir", "--repository_cache=/cache/repos", "//app/...", "//lib/..."},
+		},
+		{
+			plugin: Config{FetchSync: true, RepositoryCache: "/cache/repos"},
+			want:   []string{"sync", "--repository_cache=/cache/repos"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.fetchArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestRunFetch(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Mode:            modeFetch,
+		Target:          "//:push",
+		Registry:        "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:      "repository",
+		RepositoryCache: "/cache/repos",
+	}
+	runner := &recordingRunner{}
+
+	// no ECR client is needed to fetch
+	err := Run(context.Background(), cfg, WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.calls) != 1 {
+		t.Fatalf("expected a single command, got %+v", runner.calls)
+	}
+
+	want := []string{"fetch", "--repository_cache=/cache/repos", "//:push"}
+	if runner.calls[0].name != "bazel" || !reflect.DeepEqual(want, runner.calls[0].args) {
+		t.Errorf("unexpected command: %s %v", runner.calls[0].name, runner.calls[0].args)
+	}
+
+	cfg = Config{Mode: modeFetch, Registry: cfg.Registry, Repository: "repository"}
+	if cfg.validate() == nil {
+		t.Errorf("fetch without targets should have failed")
+	}
+}
@@ -0,0 +1,284 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// untagged image found by the gc mode
+type orphan struct {
+	repository string
+	detail     *ecr.ImageDetail
+}
+
+// untagged images younger than a day, such as the platform images of a multi-arch
+// image being pushed, are kept unless gc_min_age is set
+const defaultGCMinAge = 1
+
+// report and, with gc_confirm, delete untagged images older than gc_min_age days
+func (p *Config) collectGarbage(svc ecriface.ECRAPI, w io.Writer, now time.Time) error {
+	cutoff := now.AddDate(0, 0, -p.gcMinAge())
+
+	var orphans []orphan
+	for _, repository := range p.gcRepositories() {
+		found, err := p.findOrphans(svc, repository, cutoff)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, found...)
+	}
+
+	err := printOrphans(w, orphans, p.GCConfirm)
+	if err != nil {
+		return err
+	}
+
+	if !p.GCConfirm {
+		return nil
+	}
+
+	// delete in batches per repository
+	byRepository := map[string][]*ecr.ImageIdentifier{}
+	for _, o := range orphans {
+		byRepository[o.repository] = append(byRepository[o.repository], &ecr.ImageIdentifier{ImageDigest: o.detail.ImageDigest})
+	}
+
+	for _, repository := range p.gcRepositories() {
+		ids := byRepository[repository]
+		for start := 0; start < len(ids); start += batchDeleteSize {
+			end := start + batchDeleteSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+
+			result, err := svc.BatchDeleteImage(&ecr.BatchDeleteImageInput{
+				RepositoryName: aws.String(repository),
+				ImageIds:       ids[start:end],
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(result.Failures) > 0 {
+				failure := result.Failures[0]
+				return fmt.Errorf("could not delete %s@%s: %s", repository, aws.StringValue(failure.ImageId.ImageDigest), aws.StringValue(failure.FailureReason))
+			}
+		}
+	}
+
+	return nil
+}
+
+// minimum age in days of the images collected by the gc mode
+func (p *Config) gcMinAge() int {
+	if p.GCMinAge == nil {
+		return defaultGCMinAge
+	}
+
+	return *p.GCMinAge
+}
+
+// repositories collected by the gc mode, the target repository by default
+func (p *Config) gcRepositories() []string {
+	if len(p.GCRepositories) == 0 {
+		return []string{p.Repository}
+	}
+
+	var repositories []string
+	for _, repository := range p.GCRepositories {
+		repositories = append(repositories, strings.TrimSpace(repository))
+	}
+
+	return repositories
+}
+
+// untagged images pushed before the cutoff that are not part of a tagged image index
+// nor referrers, such as signatures, of an image that is kept
+func (p *Config) findOrphans(svc ecriface.ECRAPI, repository string, cutoff time.Time) ([]orphan, error) {
+	var untagged, indexes []*ecr.ImageDetail
+	existing := map[string]bool{}
+
+	input := &ecr.DescribeImagesInput{RepositoryName: aws.String(repository)}
+	err := svc.DescribeImagesPages(input, func(page *ecr.DescribeImagesOutput, last bool) bool {
+		for _, detail := range page.ImageDetails {
+			existing[aws.StringValue(detail.ImageDigest)] = true
+			if len(detail.ImageTags) == 0 {
+				untagged = append(untagged, detail)
+			} else if types.MediaType(aws.StringValue(detail.ImageManifestMediaType)).IsIndex() {
+				indexes = append(indexes, detail)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the platform images of multi-arch images are untagged
+	referenced, err := indexManifests(svc, repository, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates, referrers []*ecr.ImageDetail
+	for _, detail := range untagged {
+		if referenced[aws.StringValue(detail.ImageDigest)] || !aws.TimeValue(detail.ImagePushedAt).Before(cutoff) {
+			continue
+		}
+		candidates = append(candidates, detail)
+		if types.MediaType(aws.StringValue(detail.ImageManifestMediaType)) == types.OCIManifestSchema1 {
+			referrers = append(referrers, detail)
+		}
+	}
+
+	subjects, err := manifestSubjects(svc, repository, referrers)
+	if err != nil {
+		return nil, err
+	}
+
+	collected := map[string]bool{}
+	for _, detail := range candidates {
+		collected[aws.StringValue(detail.ImageDigest)] = true
+	}
+
+	// keep the referrers of kept images, until no more referrers are kept
+	for changed := true; changed; {
+		changed = false
+		for digest, subject := range subjects {
+			if collected[digest] && existing[subject] && !collected[subject] {
+				delete(collected, digest)
+				changed = true
+			}
+		}
+	}
+
+	var orphans []orphan
+	for _, detail := range candidates {
+		if collected[aws.StringValue(detail.ImageDigest)] {
+			orphans = append(orphans, orphan{repository: repository, detail: detail})
+		}
+	}
+
+	return orphans, nil
+}
+
+// subject digests of the manifests that refer to another image
+func manifestSubjects(svc ecriface.ECRAPI, repository string, details []*ecr.ImageDetail) (map[string]string, error) {
+	subjects := map[string]string{}
+
+	for start := 0; start < len(details); start += batchDeleteSize {
+		end := start + batchDeleteSize
+		if end > len(details) {
+			end = len(details)
+		}
+
+		var ids []*ecr.ImageIdentifier
+		for _, detail := range details[start:end] {
+			ids = append(ids, &ecr.ImageIdentifier{ImageDigest: detail.ImageDigest})
+		}
+
+		result, err := svc.BatchGetImage(&ecr.BatchGetImageInput{
+			RepositoryName:     aws.String(repository),
+			ImageIds:           ids,
+			AcceptedMediaTypes: aws.StringSlice([]string{string(types.OCIManifestSchema1)}),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range result.Images {
+			var manifest struct {
+				Subject *struct {
+					Digest string `json:"digest"`
+				} `json:"subject"`
+			}
+			err := json.Unmarshal([]byte(aws.StringValue(image.ImageManifest)), &manifest)
+			if err != nil {
+				return nil, err
+			}
+
+			if manifest.Subject != nil {
+				subjects[aws.StringValue(image.ImageId.ImageDigest)] = manifest.Subject.Digest
+			}
+		}
+	}
+
+	return subjects, nil
+}
+
+// digests of the manifests referenced by image indexes
+func indexManifests(svc ecriface.ECRAPI, repository string, indexes []*ecr.ImageDetail) (map[string]bool, error) {
+	referenced := map[string]bool{}
+
+	for start := 0; start < len(indexes); start += batchDeleteSize {
+		end := start + batchDeleteSize
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+
+		var ids []*ecr.ImageIdentifier
+		for _, index := range indexes[start:end] {
+			ids = append(ids, &ecr.ImageIdentifier{ImageDigest: index.ImageDigest})
+		}
+
+		result, err := svc.BatchGetImage(&ecr.BatchGetImageInput{
+			RepositoryName:     aws.String(repository),
+			ImageIds:           ids,
+			AcceptedMediaTypes: aws.StringSlice([]string{string(types.OCIImageIndex), string(types.DockerManifestList)}),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range result.Images {
+			var index struct {
+				Manifests []struct {
+					Digest string `json:"digest"`
+				} `json:"manifests"`
+			}
+			err := json.Unmarshal([]byte(aws.StringValue(image.ImageManifest)), &index)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, manifest := range index.Manifests {
+				referenced[manifest.Digest] = true
+			}
+		}
+	}
+
+	return referenced, nil
+}
+
+// print the untagged images as a table with their total size
+func printOrphans(w io.Writer, orphans []orphan, confirmed bool) error {
+	var total int64
+	for _, o := range orphans {
+		total += aws.Int64Value(o.detail.ImageSizeInBytes)
+	}
+
+	action := "would delete"
+	if confirmed {
+		action = "deleting"
+	}
+	fmt.Fprintf(w, "%s %d untagged images (%d bytes)\n", action, len(orphans), total)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tDIGEST\tPUSHED AT\tSIZE")
+
+	for _, o := range orphans {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", o.repository, aws.StringValue(o.detail.ImageDigest),
+			aws.TimeValue(o.detail.ImagePushedAt).Format(time.RFC3339), aws.Int64Value(o.detail.ImageSizeInBytes))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// serves images per repository and the manifests of image indexes
+type mockGCClient struct {
+	mockECRClient
+
+	images    map[string][]*ecr.ImageDetail
+	manifests map[string]string
+	deleted   []string
+}
+
+func (m *mockGCClient) DescribeImagesPages(input *ecr.DescribeImagesInput, fn func(*ecr.DescribeImagesOutput, bool) bool) error {
+	fn(&ecr.DescribeImagesOutput{ImageDetails: m.images[aws.StringValue(input.RepositoryName)]}, true)
+	return nil
+}
+
+func (m *mockGCClient) BatchGetImage(input *ecr.BatchGetImageInput) (*ecr.BatchGetImageOutput, error) {
+	var images []*ecr.Image
+	for _, id := range input.ImageIds {
+		images = append(images, &ecr.Image{ImageId: id, ImageManifest: aws.String(m.manifests[aws.StringValue(id.ImageDigest)])})
+	}
+
+	return &ecr.BatchGetImageOutput{Images: images}, nil
+}
+
+func (m *mockGCClient) BatchDeleteImage(input *ecr.BatchDeleteImageInput) (*ecr.BatchDeleteImageOutput, error) {
+	for _, id := range input.ImageIds {
+		m.deleted = append(m.deleted, aws.StringValue(input.RepositoryName)+"@"+aws.StringValue(id.ImageDigest))
+	}
+
+	return &ecr.BatchDeleteImageOutput{ImageIds: input.ImageIds}, nil
+}
+
+func TestCollectGarbage(t *testing.T) {
+	now := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	index := testImage("sha256:index", 10, "v1")
+	index.ImageManifestMediaType = aws.String(string(types.OCIImageIndex))
+
+	signature := func(digest string) *ecr.ImageDetail {
+		image := testImage(digest, 10)
+		image.ImageManifestMediaType = aws.String(string(types.OCIManifestSchema1))
+		return image
+	}
+
+	// pushed an hour ago, such as the platform image of an index still being pushed
+	recent := testImage("sha256:a0", 0)
+	recent.ImagePushedAt = aws.Time(now.Add(-time.Hour))
+
+	newClient := func() *mockGCClient {
+		return &mockGCClient{
+			images: map[string][]*ecr.ImageDetail{
+				"a": {testImage("sha256:a1", 10), testImage("sha256:a2", 2), testImage("sha256:a3", 20, "main"), recent},
+				"b": {index, testImage("sha256:amd64", 10), testImage("sha256:b1", 30)},
+				"c": {testImage("sha256:c1", 10, "v1"), testImage("sha256:c2", 10), signature("sha256:sig1"), signature("sha256:sig2"), signature("sha256:sig3")},
+			},
+			manifests: map[string]string{
+				"sha256:index": `{"manifests": [{"digest": "sha256:amd64"}]}`,
+				"sha256:sig1":  `{"subject": {"digest": "sha256:c1"}}`,
+				"sha256:sig2":  `{"subject": {"digest": "sha256:c2"}}`,
+				"sha256:sig3":  `{"subject": {"digest": "sha256:gone"}}`,
+			},
+		}
+	}
+
+	tests := []struct {
+		plugin Config
+		report string
+		want   []string
+	}{
+		{
+			plugin: Config{Repository: "a", GCMinAge: aws.Int(7)},
+			report: "would delete 1 untagged images (10240 bytes)",
+		},
+		{
+			plugin: Config{Repository: "a", GCRepositories: []string{"a", " b"}, GCMinAge: aws.Int(7), GCConfirm: true},
+			report: "deleting 2 untagged images (40960 bytes)",
+			want:   []string{"a@sha256:a1", "b@sha256:b1"},
+		},
+		{
+			plugin: Config{Repository: "a", GCConfirm: true},
+			report: "deleting 2 untagged images (12288 bytes)",
+			want:   []string{"a@sha256:a1", "a@sha256:a2"},
+		},
+		{
+			plugin: Config{Repository: "a", GCMinAge: aws.Int(0), GCAllowZeroAge: true, GCConfirm: true},
+			report: "deleting 3 untagged images (12288 bytes)",
+			want:   []string{"a@sha256:a1", "a@sha256:a2", "a@sha256:a0"},
+		},
+		{
+			plugin: Config{Repository: "c", GCMinAge: aws.Int(7), GCConfirm: true},
+			report: "deleting 3 untagged images (30720 bytes)",
+			want:   []string{"c@sha256:c2", "c@sha256:sig2", "c@sha256:sig3"},
+		},
+	}
+
+	for _, test := range tests {
+		svc := newClient()
+		var buf bytes.Buffer
+
+		err := test.plugin.collectGarbage(svc, &buf, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(buf.String(), test.report) {
+			t.Errorf("%q does not start with %q", buf.String(), test.report)
+		}
+
+		if !reflect.DeepEqual(test.want, svc.deleted) {
+			t.Errorf("%v is not equal to %v", test.want, svc.deleted)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+)
+
+// username sent with git_token when git_username is not set, accepted by GitHub for tokens
+const defaultGitUsername = "x-access-token"
+
+// askpass script answering the git prompts from the environment, so the token is not written to disk
+const askpassScript = `#!/bin/sh
+case "$1" in
+Username*) echo "$GIT_ASKPASS_USERNAME" ;;
+*) echo "$GIT_ASKPASS_PASSWORD" ;;
+esac
+`
+
+// configure the git credentials of private git_repository rules, returning the
+// generated askpass script to remove once the build is done
+func (p *Config) setupGitCredentials() (string, error) {
+	// fail instead of waiting for a prompt nobody answers
+	p.gitEnv = []string{"GIT_TERMINAL_PROMPT=0"}
+
+	if p.GitAskpass != "" {
+		p.gitEnv = append(p.gitEnv, "GIT_ASKPASS="+p.GitAskpass)
+		return "", nil
+	}
+
+	f, err := os.CreateTemp(p.tempDir, "askpass-*.sh")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(askpassScript)
+	if err == nil {
+		err = f.Chmod(0700)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("could not write the askpass script: %w", err)
+	}
+
+	username := p.GitUsername
+	if username == "" {
+		username = defaultGitUsername
+	}
+
+	p.gitEnv = append(p.gitEnv,
+		"GIT_ASKPASS="+f.Name(),
+		"GIT_ASKPASS_USERNAME="+username,
+		"GIT_ASKPASS_PASSWORD="+p.GitToken,
+	)
+
+	return f.Name(), nil
+}
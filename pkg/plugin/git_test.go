@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetupGitCredentials(t *testing.T) {
+	p := Config{GitToken: "token"}
+
+	script, err := p.setupGitCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(script)
+
+	tests := []struct {
+		prompt string
+		answer string
+	}{
+		{prompt: "Username for 'https://github.com': ", answer: defaultGitUsername},
+		{prompt: "Password for 'https://x-access-token@github.com': ", answer: "token"},
+	}
+
+	for _, test := range tests {
+		cmd := exec.Command(script, test.prompt)
+		cmd.Env = p.environ()
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(out)); got != test.answer {
+			t.Errorf("%q: got %q, want %q", test.prompt, got, test.answer)
+		}
+	}
+
+	// a provided askpass is used as is
+	p = Config{GitAskpass: "/usr/local/bin/askpass"}
+	script, err = p.setupGitCredentials()
+	if err != nil || script != "" {
+		t.Fatalf("unexpected script %q: %v", script, err)
+	}
+
+	want := []string{"GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=/usr/local/bin/askpass"}
+	if !reflect.DeepEqual(p.environ(), want) {
+		t.Errorf("got %v, want %v", p.environ(), want)
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kustomize images transformer entry
+type kustomizeImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// write a kustomization patch pinning the image to the pushed digest
+func (p *Config) writeKustomizeImages(digest string) error {
+	// match the image name used in the manifests, defaults to the repository name
+	imageName := p.KustomizeImage
+	if imageName == "" {
+		imageName = p.Repository
+	}
+
+	patch := map[string][]kustomizeImage{
+		"images": {{
+			Name:    imageName,
+			NewName: fmt.Sprintf("%s/%s", p.Registry, p.Repository),
+			NewTag:  p.imageTag(),
+			Digest:  digest,
+		}},
+	}
+
+	return writeYAML(p.KustomizeOutput, patch)
+}
+
+// write a helm values snippet with the image nested under the configured key path
+func (p *Config) writeHelmValues(digest string) error {
+	key := p.HelmValuesKey
+	if key == "" {
+		key = "image"
+	}
+
+	var values interface{} = map[string]string{
+		"repository": fmt.Sprintf("%s/%s", p.Registry, p.Repository),
+		"tag":        p.imageTag(),
+		"digest":     digest,
+	}
+
+	// wrap the values from the innermost key outwards
+	keys := strings.Split(key, ".")
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i] == "" {
+			return fmt.Errorf("invalid helm values key: %s", key)
+		}
+		values = map[string]interface{}{keys[i]: values}
+	}
+
+	return writeYAML(p.HelmValuesOutput, values)
+}
+
+func writeYAML(path string, v interface{}) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, out, 0644)
+}
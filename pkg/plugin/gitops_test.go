@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKustomizeImages(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		p    Config
+		want string
+	}{
+		{
+			p: Config{Registry: "registry", Repository: "repository", Tag: "v1"},
+			want: `images:
+    - name: repository
+      newName: registry/repository
+      newTag: v1
+      digest: sha256:abc
+`,
+		},
+		{
+			p: Config{Registry: "registry", Repository: "repository", KustomizeImage: "app"},
+			want: `images:
+    - name: app
+      newName: registry/repository
+      newTag: latest
+      digest: sha256:abc
+`,
+		},
+	}
+
+	for _, test := range tests {
+		test.p.KustomizeOutput = filepath.Join(dir, "kustomization.yaml")
+
+		err := test.p.writeKustomizeImages("sha256:abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(test.p.KustomizeOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != test.want {
+			t.Errorf("%q is not equal to %q", test.want, string(got))
+		}
+	}
+}
+
+func TestWriteHelmValues(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		key  string
+		want string
+		fail bool
+	}{
+		{
+			want: `image:
+    digest: sha256:abc
+    repository: registry/repository
+    tag: v1
+`,
+		},
+		{
+			key: "api.image",
+			want: `api:
+    image:
+        digest: sha256:abc
+        repository: registry/repository
+        tag: v1
+`,
+		},
+		{
+			key:  "api..image",
+			fail: true,
+		},
+	}
+
+	for _, test := range tests {
+		p := Config{
+			Registry:         "registry",
+			Repository:       "repository",
+			Tag:              "v1",
+			HelmValuesKey:    test.key,
+			HelmValuesOutput: filepath.Join(dir, "values.yaml"),
+		}
+
+		err := p.writeHelmValues("sha256:abc")
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.key, err)
+		}
+		if test.fail {
+			continue
+		}
+
+		got, err := os.ReadFile(p.HelmValuesOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != test.want {
+			t.Errorf("%q is not equal to %q", test.want, string(got))
+		}
+	}
+}
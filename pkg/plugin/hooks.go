@@ -0,0 +1,24 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// run hook commands with the environment passed to bazel
+func (p *Config) runHooks(ctx context.Context, runner Runner, cmds []string) error {
+	env := append(os.Environ(), p.environ()...)
+
+	for _, cmd := range cmds {
+		log.Printf("+ %s", cmd)
+
+		err := runner.Run(ctx, "sh", []string{"-c", cmd}, env)
+		if err != nil {
+			return fmt.Errorf("hook command %q failed: %w", cmd, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunHookCommands(t *testing.T) {
+	tests := []struct {
+		cmds       []string
+		failTarget string
+		calls      [][]string
+		err        string
+	}{
+		{},
+		{
+			cmds:  []string{"echo one", "echo two | tee out"},
+			calls: [][]string{{"-c", "echo one"}, {"-c", "echo two | tee out"}},
+		},
+		{
+			cmds:       []string{"echo one", "false", "echo three"},
+			failTarget: "false",
+			calls:      [][]string{{"-c", "echo one"}, {"-c", "false"}},
+			err:        `hook command "false" failed: exit status 1`,
+		},
+	}
+
+	for _, test := range tests {
+		p := &Config{Repository: "a"}
+		r := &recordingRunner{err: errors.New("exit status 1"), failTarget: test.failTarget}
+		if test.failTarget == "" {
+			r.err = nil
+		}
+
+		err := p.runHooks(context.Background(), r, test.cmds)
+		if test.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.cmds, err)
+		}
+		if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("%v: got error %v, want %s", test.cmds, err, test.err)
+		}
+
+		var calls [][]string
+		for _, call := range r.calls {
+			if call.name != "sh" {
+				t.Errorf("%v: got %s, want sh", test.cmds, call.name)
+			}
+			if v, _ := lookupEnv(call.env, "DRONE_ECR_REPOSITORY"); v != "a" {
+				t.Errorf("%v: got DRONE_ECR_REPOSITORY %q, want a", test.cmds, v)
+			}
+			calls = append(calls, call.args)
+		}
+		if !reflect.DeepEqual(calls, test.calls) {
+			t.Errorf("got %v, want %v", calls, test.calls)
+		}
+	}
+}
+
+func TestRunHookCommandsShell(t *testing.T) {
+	p := &Config{Repository: "a"}
+
+	err := p.runHooks(context.Background(), execRunner{}, []string{`test "$DRONE_ECR_REPOSITORY" = a && true | cat`})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = p.runHooks(context.Background(), execRunner{}, []string{"exit 3"})
+	if err == nil || !strings.Contains(err.Error(), `hook command "exit 3" failed`) {
+		t.Errorf("got error %v, want the failing hook", err)
+	}
+}
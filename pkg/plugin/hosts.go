@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// hosts file of the container, read by bazel and the hook commands
+var hostsFile = "/etc/hosts"
+
+// host name resolved to an address without DNS
+type hostEntry struct {
+	host string
+	ip   string
+}
+
+// parse host=ip pairs
+func parseExtraHosts(pairs []string) ([]hostEntry, error) {
+	var entries []hostEntry
+	for _, pair := range pairs {
+		host, ip, ok := strings.Cut(pair, "=")
+		if !ok || host == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("extra host must be a host=ip pair: %s", pair)
+		}
+
+		entries = append(entries, hostEntry{host: host, ip: ip})
+	}
+
+	return entries, nil
+}
+
+// add the extra hosts to the hosts file, so that mirrors only resolvable through
+// split-horizon DNS can be reached from runners without it. a hosts file given to
+// the JVM with -Djdk.net.hosts.file would replace DNS instead of adding to it, and
+// would not be seen by the hook commands, so the plugin has to run as root
+func (p *Config) writeExtraHosts() error {
+	entries, err := parseExtraHosts(p.ExtraHosts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(hostsFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if os.IsPermission(err) {
+		return fmt.Errorf("extra_hosts requires running as root to write %s, not as uid %d: %w", hostsFile, os.Geteuid(), err)
+	}
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", hostsFile, err)
+	}
+	defer f.Close()
+
+	for _, entry := range entries {
+		_, err = fmt.Fprintf(f, "%s\t%s\n", entry.ip, entry.host)
+		if err != nil {
+			return err
+		}
+		log.Printf("resolving %s to %s", entry.host, entry.ip)
+	}
+
+	return f.Close()
+}
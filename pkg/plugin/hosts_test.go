@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExtraHosts(t *testing.T) {
+	tests := []struct {
+		pairs   []string
+		entries []hostEntry
+		err     bool
+	}{
+		{},
+		{
+			pairs:   []string{"mirror.internal=10.0.0.5", "v6.internal=fd00::5"},
+			entries: []hostEntry{{host: "mirror.internal", ip: "10.0.0.5"}, {host: "v6.internal", ip: "fd00::5"}},
+		},
+		{pairs: []string{"mirror.internal"}, err: true},
+		{pairs: []string{"mirror.internal=mirror"}, err: true},
+		{pairs: []string{"=10.0.0.5"}, err: true},
+	}
+
+	for _, test := range tests {
+		entries, err := parseExtraHosts(test.pairs)
+		if (err != nil) != test.err {
+			t.Errorf("%v: unexpected error: %v", test.pairs, err)
+		}
+		if !reflect.DeepEqual(entries, test.entries) {
+			t.Errorf("got %v, want %v", entries, test.entries)
+		}
+	}
+}
+
+func TestWriteExtraHosts(t *testing.T) {
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	defer func() { hostsFile = "/etc/hosts" }()
+
+	err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Config{ExtraHosts: []string{"mirror.internal=10.0.0.5"}}
+	err = p.writeExtraHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "127.0.0.1\tlocalhost\n10.0.0.5\tmirror.internal\n"
+	if string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestWriteExtraHostsReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write read-only files")
+	}
+
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	defer func() { hostsFile = "/etc/hosts" }()
+
+	err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Config{ExtraHosts: []string{"mirror.internal=10.0.0.5"}}
+	err = p.writeExtraHosts()
+	if err == nil || !strings.Contains(err.Error(), "extra_hosts requires running as root") {
+		t.Errorf("got error %v, want the root requirement", err)
+	}
+}
@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/inspector2"
+	"github.com/aws/aws-sdk-go/service/inspector2/inspector2iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// components of a CycloneDX SBOM with their licenses
+type cycloneDX struct {
+	Components []struct {
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		Licenses []struct {
+			License struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"license"`
+			Expression string `json:"expression"`
+		} `json:"licenses"`
+	} `json:"components"`
+}
+
+// fail when a package of the pushed image has a license matching deny_licenses,
+// using the SBOM exported by Amazon Inspector for registries with enhanced scanning
+func (p *Config) checkLicenses(inspector inspector2iface.Inspector2API, s3svc s3iface.S3API) error {
+	reportID, err := p.exportSBOM(inspector)
+	if err != nil {
+		return err
+	}
+
+	bucket, prefix, err := parseS3URL(p.SbomExportTarget)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)}
+	err = s3svc.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			if strings.Contains(key, reportID) && strings.HasSuffix(key, ".json") {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no SBOM found for %s:%s in s3://%s/%s", p.Repository, p.imageTag(), bucket, prefix)
+	}
+
+	var denied []string
+	for _, key := range keys {
+		result, err := s3svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return err
+		}
+
+		var sbom cycloneDX
+		err = json.NewDecoder(result.Body).Decode(&sbom)
+		result.Body.Close()
+		if err != nil {
+			return fmt.Errorf("could not parse SBOM s3://%s/%s: %w", bucket, key, err)
+		}
+
+		denied = append(denied, p.deniedLicenses(sbom)...)
+	}
+
+	if len(denied) > 0 {
+		return fmt.Errorf("%d packages of %s:%s have denied licenses: %s", len(denied), p.Repository, p.imageTag(), strings.Join(denied, ", "))
+	}
+
+	log.Printf("no denied licenses found in %s:%s", p.Repository, p.imageTag())
+	return nil
+}
+
+// export the CycloneDX SBOM of the pushed image and wait for the export, for up to scan_timeout
+func (p *Config) exportSBOM(svc inspector2iface.Inspector2API) (string, error) {
+	bucket, prefix, err := parseS3URL(p.SbomExportTarget)
+	if err != nil {
+		return "", err
+	}
+
+	equals := func(value string) []*inspector2.ResourceStringFilter {
+		return []*inspector2.ResourceStringFilter{{Comparison: aws.String(inspector2.ResourceStringComparisonEquals), Value: aws.String(value)}}
+	}
+
+	export, err := svc.CreateSbomExport(&inspector2.CreateSbomExportInput{
+		ReportFormat: aws.String(inspector2.SbomReportFormatCyclonedx14),
+		ResourceFilterCriteria: &inspector2.ResourceFilterCriteria{
+			EcrRepositoryName: equals(p.Repository),
+			EcrImageTags:      equals(p.imageTag()),
+		},
+		S3Destination: &inspector2.Destination{
+			BucketName: aws.String(bucket),
+			KeyPrefix:  aws.String(prefix),
+			KmsKeyArn:  aws.String(p.SbomExportKmsKey),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	reportID := aws.StringValue(export.ReportId)
+
+	timeout := p.ScanTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		result, err := svc.GetSbomExport(&inspector2.GetSbomExportInput{ReportId: aws.String(reportID)})
+		if err != nil {
+			return "", err
+		}
+
+		switch aws.StringValue(result.Status) {
+		case inspector2.ExternalReportStatusSucceeded:
+			return reportID, nil
+		case inspector2.ExternalReportStatusFailed, inspector2.ExternalReportStatusCancelled:
+			return "", fmt.Errorf("SBOM export %s failed: %s", reportID, aws.StringValue(result.ErrorMessage))
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("SBOM export %s did not complete after %s", reportID, timeout)
+		}
+
+		time.Sleep(imagePollInterval)
+	}
+}
+
+// packages with a license matching deny_licenses, every license of an SPDX expression is checked
+func (p *Config) deniedLicenses(sbom cycloneDX) []string {
+	var denied []string
+
+	for _, component := range sbom.Components {
+		var licenses []string
+		for _, license := range component.Licenses {
+			licenses = append(licenses, license.License.ID, license.License.Name)
+			for _, field := range strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(license.Expression)) {
+				if field != "AND" && field != "OR" && field != "WITH" {
+					licenses = append(licenses, field)
+				}
+			}
+		}
+
+		for _, license := range licenses {
+			if license != "" && matchAny(p.DenyLicenses, license) {
+				denied = append(denied, fmt.Sprintf("%s %s (%s)", component.Name, component.Version, license))
+				break
+			}
+		}
+	}
+
+	return denied
+}
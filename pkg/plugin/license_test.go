@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/inspector2"
+	"github.com/aws/aws-sdk-go/service/inspector2/inspector2iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// completes SBOM exports after the given number of polls
+type mockInspectorClient struct {
+	inspector2iface.Inspector2API
+
+	polls  int
+	status string
+	input  *inspector2.CreateSbomExportInput
+}
+
+func (m *mockInspectorClient) CreateSbomExport(input *inspector2.CreateSbomExportInput) (*inspector2.CreateSbomExportOutput, error) {
+	m.input = input
+	return &inspector2.CreateSbomExportOutput{ReportId: aws.String("report-1")}, nil
+}
+
+func (m *mockInspectorClient) GetSbomExport(input *inspector2.GetSbomExportInput) (*inspector2.GetSbomExportOutput, error) {
+	if m.polls > 0 {
+		m.polls--
+		return &inspector2.GetSbomExportOutput{Status: aws.String(inspector2.ExternalReportStatusInProgress)}, nil
+	}
+
+	return &inspector2.GetSbomExportOutput{Status: aws.String(m.status), ErrorMessage: aws.String("export failed")}, nil
+}
+
+// serves objects from a map of keys to content
+type mockS3Client struct {
+	s3iface.S3API
+
+	objects map[string]string
+}
+
+func (m *mockS3Client) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	var contents []*s3.Object
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			contents = append(contents, &s3.Object{Key: aws.String(key)})
+		}
+	}
+
+	fn(&s3.ListObjectsV2Output{Contents: contents}, true)
+	return nil
+}
+
+func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(m.objects[aws.StringValue(input.Key)]))}, nil
+}
+
+const testSBOM = `{"components": [
+	{"name": "openssl", "version": "3.0.1", "licenses": [{"license": {"id": "Apache-2.0"}}]},
+	{"name": "ghostscript", "version": "9.5", "licenses": [{"license": {"id": "AGPL-3.0-only"}}]},
+	{"name": "mysql-connector", "version": "8.0", "licenses": [{"expression": "(MIT OR GPL-2.0-only WITH Universal-FOSS-exception-1.0)"}]}
+]}`
+
+func TestCheckLicenses(t *testing.T) {
+	imagePollInterval = time.Millisecond
+	defer func() { imagePollInterval = 2 * time.Second }()
+
+	objects := map[string]string{
+		"sboms/SBOM_Export/report-1/test.json": testSBOM,
+		"sboms/SBOM_Export/report-0/old.json":  `{"components": [{"name": "old", "licenses": [{"license": {"id": "AGPL-3.0-only"}}]}]}`,
+	}
+
+	tests := []struct {
+		deny   []string
+		status string
+		denied string
+		err    bool
+	}{
+		{deny: []string{"MPL-*"}, status: inspector2.ExternalReportStatusSucceeded},
+		{deny: []string{"AGPL-*"}, status: inspector2.ExternalReportStatusSucceeded, denied: "1 packages of test:v1 have denied licenses: ghostscript 9.5 (AGPL-3.0-only)", err: true},
+		{deny: []string{"AGPL-*", "GPL-*"}, status: inspector2.ExternalReportStatusSucceeded, denied: "mysql-connector 8.0 (GPL-2.0-only)", err: true},
+		{deny: []string{"AGPL-*"}, status: inspector2.ExternalReportStatusFailed, denied: "export failed", err: true},
+	}
+
+	for _, test := range tests {
+		p := Config{Repository: "test", Tag: "v1", DenyLicenses: test.deny, SbomExportTarget: "s3://bucket/sboms", SbomExportKmsKey: "key"}
+		inspector := &mockInspectorClient{polls: 2, status: test.status}
+
+		err := p.checkLicenses(inspector, &mockS3Client{objects: objects})
+		if (err != nil) != test.err {
+			t.Fatalf("%v: unexpected error: %v", test.deny, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), test.denied) {
+			t.Errorf("%q does not contain %q", err, test.denied)
+		}
+
+		if aws.StringValue(inspector.input.ResourceFilterCriteria.EcrImageTags[0].Value) != "v1" {
+			t.Errorf("SBOM export is not filtered by the image tag")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// current lifecycle policy of the repository, empty when it has none
+func (p *Config) currentLifecyclePolicy(svc ecriface.ECRAPI) (string, error) {
+	result, err := svc.GetLifecyclePolicy(&ecr.GetLifecyclePolicyInput{
+		RepositoryName: aws.String(p.Repository),
+		RegistryId:     aws.String(p.registryID()),
+	})
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		if ok && aerr.Code() == ecr.ErrCodeLifecyclePolicyNotFoundException {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return aws.StringValue(result.LifecyclePolicyText), nil
+}
+
+// apply lifecycle_policy to the repository when it differs from the current policy, logging the difference
+func (p *Config) reconcileLifecyclePolicy(svc ecriface.ECRAPI) error {
+	policy, err := p.lifecyclePolicy()
+	if err != nil {
+		return err
+	}
+
+	want, err := normalizeJSON(policy)
+	if err != nil {
+		return fmt.Errorf("could not parse lifecycle policy: %w", err)
+	}
+
+	current, err := p.currentLifecyclePolicy(svc)
+	if err != nil {
+		return err
+	}
+
+	var got string
+	if current != "" {
+		got, err = normalizeJSON(current)
+		if err != nil {
+			return err
+		}
+	}
+
+	if got == want {
+		log.Printf("lifecycle policy of %s is up to date", p.Repository)
+		return nil
+	}
+
+	log.Printf("updating the lifecycle policy of %s:\n%s", p.Repository, strings.Join(diffLines(splitLines(got), splitLines(want)), "\n"))
+
+	_, err = svc.PutLifecyclePolicy(&ecr.PutLifecyclePolicyInput{
+		RepositoryName:      aws.String(p.Repository),
+		RegistryId:          aws.String(p.registryID()),
+		LifecyclePolicyText: aws.String(policy),
+	})
+	return err
+}
+
+// indented JSON with sorted keys, so that formatting differences are ignored
+func normalizeJSON(data string) (string, error) {
+	var value interface{}
+	err := json.Unmarshal([]byte(data), &value)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// line diff of a and b, prefixing removed lines with - and added lines with +
+func diffLines(a, b []string) []string {
+	// longest common subsequence of the lines following each position
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+
+	return diff
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// records the applied lifecycle policy
+type mockReconcileClient struct {
+	mockDriftClient
+
+	applied *string
+}
+
+func (m *mockReconcileClient) PutLifecyclePolicy(input *ecr.PutLifecyclePolicyInput) (*ecr.PutLifecyclePolicyOutput, error) {
+	m.applied = input.LifecyclePolicyText
+	return &ecr.PutLifecyclePolicyOutput{}, nil
+}
+
+func TestReconcileLifecyclePolicy(t *testing.T) {
+	policy := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 14}, "action": {"type": "expire"}}]}`
+
+	tests := []struct {
+		current string
+		apply   bool
+	}{
+		// no policy yet
+		{current: "", apply: true},
+		// formatting differences are ignored
+		{current: `{"rules":[{"action":{"type":"expire"},"rulePriority":1,"selection":{"countNumber":14,"countType":"sinceImagePushed","countUnit":"days","tagStatus":"untagged"}}]}`},
+		{current: `{"rules":[{"action":{"type":"expire"},"rulePriority":1,"selection":{"countNumber":30,"countType":"sinceImagePushed","countUnit":"days","tagStatus":"untagged"}}]}`, apply: true},
+	}
+
+	for _, test := range tests {
+		p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", LifecyclePolicy: policy, ReconcileLifecycle: true}
+		svc := &mockReconcileClient{mockDriftClient: mockDriftClient{policy: test.current}}
+
+		err := p.reconcileLifecyclePolicy(svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.apply != (svc.applied != nil) {
+			t.Errorf("%s: expected the policy to be applied: %t", test.current, test.apply)
+		}
+		if svc.applied != nil && aws.StringValue(svc.applied) != policy {
+			t.Errorf("unexpected policy applied: %s", aws.StringValue(svc.applied))
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	want := []string{"  a", "- b", "+ x", "  c", "+ d"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%q is not equal to %q", want, got)
+	}
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// image in the repository listed by the list mode
+type listedImage struct {
+	Tags     []string  `json:"tags"`
+	Digest   string    `json:"digest"`
+	PushedAt time.Time `json:"pushed_at"`
+	Size     int64     `json:"size"`
+}
+
+// print the images in the repository, newest first, and write them to the list output
+func (p *Config) listRepository(svc ecriface.ECRAPI, w io.Writer) error {
+	details, err := p.listImages(svc)
+	if err != nil {
+		return err
+	}
+
+	images := listedImages(details)
+
+	err = printImages(w, images)
+	if err != nil {
+		return err
+	}
+
+	if p.ListOutput == "" {
+		return nil
+	}
+
+	out, err := json.MarshalIndent(images, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.ListOutput), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.ListOutput, out, 0644)
+}
+
+// convert image details sorted by push time, newest first
+func listedImages(details []*ecr.ImageDetail) []listedImage {
+	images := []listedImage{}
+	for _, detail := range details {
+		images = append(images, listedImage{
+			Tags:     aws.StringValueSlice(detail.ImageTags),
+			Digest:   aws.StringValue(detail.ImageDigest),
+			PushedAt: aws.TimeValue(detail.ImagePushedAt),
+			Size:     aws.Int64Value(detail.ImageSizeInBytes),
+		})
+	}
+
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].PushedAt.After(images[j].PushedAt)
+	})
+
+	return images
+}
+
+// print images as a table
+func printImages(w io.Writer, images []listedImage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAGS\tDIGEST\tPUSHED AT\tSIZE")
+
+	for _, image := range images {
+		tags := strings.Join(image.Tags, ",")
+		if tags == "" {
+			tags = "<untagged>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", tags, image.Digest, image.PushedAt.Format(time.RFC3339), image.Size)
+	}
+
+	return tw.Flush()
+}
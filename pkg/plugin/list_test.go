@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func TestListRepository(t *testing.T) {
+	svc := &mockImagesClient{
+		images: []*ecr.ImageDetail{
+			testImage("sha256:b", 3, "v1"),
+			testImage("sha256:a", 1, "main", "v2"),
+			testImage("sha256:c", 5),
+		},
+	}
+
+	output := filepath.Join(t.TempDir(), "out", "images.json")
+	p := Config{Repository: "test", ListOutput: output}
+
+	var buf bytes.Buffer
+	err := p.listRepository(svc, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"TAGS        DIGEST    PUSHED AT             SIZE",
+		"main,v2     sha256:a  2023-01-30T00:00:00Z  1024",
+		"v1          sha256:b  2023-01-28T00:00:00Z  3072",
+		"<untagged>  sha256:c  2023-01-26T00:00:00Z  5120",
+	}
+	if !reflect.DeepEqual(want, lines) {
+		t.Errorf("%q is not equal to %q", want, lines)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []listedImage
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got[0].Digest != "sha256:a" || !reflect.DeepEqual(got[0].Tags, []string{"main", "v2"}) || got[2].Tags == nil {
+		t.Errorf("unexpected list output: %+v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// runner that can send the output of a command to the given writers
+type outputRunner interface {
+	RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error
+}
+
+func (execRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// prefixes of the bazel output lines shown in the console when the output goes to bazel_log
+var consolePrefixes = []string{
+	"ERROR:",
+	"WARNING:",
+	"FAIL:",
+	"FAILED:",
+	"INFO: Build completed",
+	"INFO: Streaming build results to",
+	"Executed ",
+}
+
+// ANSI escape sequences of colored and curses bazel output
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// writer passing complete lines of bazel output to the console when they are errors, warnings or results
+type consoleFilter struct {
+	mu      sync.Mutex
+	console io.Writer
+	buf     bytes.Buffer
+}
+
+func (f *consoleFilter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.buf.Write(p)
+	for {
+		line, err := f.buf.ReadBytes('\n')
+		if err != nil {
+			// keep the partial line until it is complete
+			rest := append([]byte(nil), line...)
+			f.buf.Reset()
+			f.buf.Write(rest)
+			return len(p), nil
+		}
+
+		if err := f.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// write the last line of the output when it does not end with a newline
+func (f *consoleFilter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.buf.Len() == 0 {
+		return nil
+	}
+
+	line := append(f.buf.Bytes(), '\n')
+	f.buf.Reset()
+
+	return f.writeLine(line)
+}
+
+// write the line to the console when it is shown, matching it without escape sequences
+func (f *consoleFilter) writeLine(line []byte) error {
+	plain := strings.TrimLeft(ansiPattern.ReplaceAllString(string(line), ""), "\r")
+	if !showInConsole(plain) {
+		return nil
+	}
+
+	_, err := f.console.Write(line)
+	return err
+}
+
+// whether a line of bazel output is shown in the console
+func showInConsole(line string) bool {
+	// test results are listed as "//pkg:test  FAILED in 1.2s"
+	if strings.HasPrefix(line, "//") && (strings.Contains(line, " FAILED") || strings.Contains(line, " NO STATUS") || strings.Contains(line, " TIMEOUT")) {
+		return true
+	}
+
+	for _, prefix := range consolePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// run bazel with its complete output written to bazel_log and only errors, warnings and results in the console
+func (p *Config) runLogged(ctx context.Context, runner Runner, args []string, env []string) error {
+	r, ok := runner.(outputRunner)
+	if !ok {
+		return fmt.Errorf("bazel_log is not supported by the runner, which cannot route the bazel output")
+	}
+
+	f, err := os.Create(p.BazelLog)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	console := &consoleFilter{console: os.Stderr}
+	w := io.MultiWriter(f, console)
+	if p.outputTail != nil {
+		w = io.MultiWriter(w, p.outputTail)
+	}
+
+	err = r.RunOutput(ctx, "bazel", args, env, w, w)
+
+	flushErr := console.Flush()
+	if err == nil {
+		err = flushErr
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConsoleFilter(t *testing.T) {
+	var console bytes.Buffer
+	f := &consoleFilter{console: &console}
+
+	output := []string{
+		"INFO: Analyzed target //app:push (120 packages loaded).\n",
+		"WARNING: download from https://example.com failed\n",
+		"[1,024 / 2,048] Compiling app/main.go; 3s remote\n",
+		"ERROR: /src/app/BUILD:3:10: Compiling app/main.go failed\n",
+		"//app:test                                                  FAILED in 2.1s\n",
+		"//app:other_test                                            PASSED in 0.4s\n",
+		"INFO: Build completed, 1 test FAILED, 2 total actions\n",
+		"Executed 2 out of 2 tests: 1 test passes and 1 fails locally.\n",
+		// colored output
+		"\x1b[32mINFO: \x1b[0mAnalyzed target //app:push\n",
+		"\x1b[31m\x1b[1mERROR: \x1b[0mbuild did not complete\n",
+		// the last line has no newline
+		"FAILED: Build did NOT complete successfully",
+	}
+
+	// lines can be split across writes
+	for _, line := range output {
+		half := len(line) / 2
+		fmt.Fprint(f, line[:half])
+		fmt.Fprint(f, line[half:])
+	}
+
+	err := f.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := output[1] + output[3] + output[4] + output[6] + output[7] + output[9] + output[10] + "\n"
+	if console.String() != want {
+		t.Errorf("%q is not equal to %q", want, console.String())
+	}
+}
+
+// writes output to the writers of RunOutput
+type outputRecordingRunner struct {
+	recordingRunner
+}
+
+func (r *outputRecordingRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stdout, "INFO: Analyzed target //app:push")
+	fmt.Fprintln(stderr, "ERROR: build failed")
+	return r.Run(ctx, name, args, env)
+}
+
+func TestRunLogged(t *testing.T) {
+	p := Config{BazelLog: filepath.Join(t.TempDir(), "bazel.log")}
+	runner := &outputRecordingRunner{}
+
+	err := p.runLogged(context.Background(), runner, []string{"run", "//app:push"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(p.BazelLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "INFO: Analyzed target //app:push\nERROR: build failed\n"
+	if string(data) != want {
+		t.Errorf("%q is not equal to %q", want, string(data))
+	}
+
+	if len(runner.calls) != 1 {
+		t.Errorf("%d commands run, expected 1", len(runner.calls))
+	}
+
+	// runners without output routing cannot write the log
+	plain := &recordingRunner{}
+	err = p.runLogged(context.Background(), plain, []string{"run", "//app:push"}, nil)
+	if err == nil || len(plain.calls) != 0 {
+		t.Errorf("runner without output routing should not run: %v", err)
+	}
+}
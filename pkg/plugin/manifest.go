@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// variables describing the pushed image, referenced as ${NAME}
+func (p *Config) imageVars(digest string) map[string]string {
+	repository := fmt.Sprintf("%s/%s", p.Registry, p.Repository)
+
+	return map[string]string{
+		"IMAGE":      fmt.Sprintf("%s@%s", repository, digest),
+		"DIGEST":     digest,
+		"TAG":        p.imageTag(),
+		"REGISTRY":   p.Registry,
+		"REPOSITORY": p.Repository,
+	}
+}
+
+// substitute the image variables in the manifest template and write it to the output path
+func (p *Config) renderManifest(digest string) error {
+	template, err := os.ReadFile(p.ManifestTemplate)
+	if err != nil {
+		return err
+	}
+
+	// only the image variables are replaced so other placeholders are left untouched
+	var pairs []string
+	for key, val := range p.imageVars(digest) {
+		pairs = append(pairs, fmt.Sprintf("${%s}", key), val)
+	}
+	manifest := strings.NewReplacer(pairs...).Replace(string(template))
+
+	err = os.MkdirAll(filepath.Dir(p.ManifestOutput), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.ManifestOutput, []byte(manifest), 0644)
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deploy.yaml": "image: ${IMAGE}\ntag: ${TAG}\nother: ${OTHER}\n",
+	})
+
+	p := Config{
+		Registry:         "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:       "repository",
+		Tag:              "v1",
+		ManifestTemplate: filepath.Join(dir, "deploy.yaml"),
+		ManifestOutput:   filepath.Join(dir, "out", "deploy.yaml"),
+	}
+
+	err := p.renderManifest("sha256:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(p.ManifestOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "image: 0123456789.dkr.ecr.us-east-1.amazonaws.com/repository@sha256:abc\ntag: v1\nother: ${OTHER}\n"
+	if string(got) != want {
+		t.Errorf("%q is not equal to %q", want, string(got))
+	}
+
+	// missing templates fail
+	p.ManifestTemplate = filepath.Join(dir, "missing.yaml")
+	err = p.renderManifest("sha256:abc")
+	if err == nil {
+		t.Errorf("missing template should have failed")
+	}
+}
@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// target built and pushed as one entry of the matrix
+type matrixEntry struct {
+	Name          string   `json:"name"`
+	Target        string   `json:"target"`
+	Repository    string   `json:"repository"`
+	Tag           string   `json:"tag"`
+	BesKeywords   []string `json:"bes_keywords"`
+	BuildMetadata []string `json:"build_metadata"`
+}
+
+// name of the entry used in logs and phase names
+func (e matrixEntry) name() string {
+	if e.Name != "" {
+		return e.Name
+	}
+
+	return e.Target
+}
+
+// characters of entry names not used in output paths
+var entrySuffixPattern = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+
+// suffix of the output paths of the entry, e.g. api-push for //api:push
+func (e matrixEntry) suffix() string {
+	return strings.Trim(entrySuffixPattern.ReplaceAllString(e.name(), "-"), "-.")
+}
+
+// path with the suffix inserted before the extension, e.g. image-api.env
+func suffixPath(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + suffix + ext
+}
+
+// matrix of targets passed as a JSON list
+type matrix []matrixEntry
+
+// Decode implements envconfig.Decoder
+func (m *matrix) Decode(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	err := json.Unmarshal([]byte(value), m)
+	if err != nil {
+		return fmt.Errorf("could not parse matrix: %w", err)
+	}
+
+	return nil
+}
+
+// check that every matrix entry has a target
+func (m matrix) validate() error {
+	for i, entry := range m {
+		if entry.Target == "" {
+			return fmt.Errorf("must specify a target for matrix entry %d", i)
+		}
+	}
+
+	return nil
+}
+
+// config of a single matrix entry, inheriting the other settings
+func (p *Config) matrixConfig(entry matrixEntry) *Config {
+	cfg := *p
+	cfg.Matrix = nil
+	cfg.Target = entry.Target
+
+	if entry.Repository != "" {
+		cfg.Repository = entry.Repository
+	}
+	if entry.Tag != "" {
+		cfg.Tag = entry.Tag
+	}
+
+	cfg.besKeywords = entry.BesKeywords
+	cfg.buildMetadata = entry.BuildMetadata
+
+	// registry settings are managed once for all entries
+	cfg.VerifyRegistry = false
+	cfg.DockerConfigOutput = ""
+	cfg.RegistryScanning = ""
+	cfg.CreationTemplate = ""
+	if cfg.Mode != modeWarm {
+		cfg.WarmImages = nil
+	}
+
+	// every entry writes its own outputs
+	outputs := []*string{
+		&cfg.EnvFile, &cfg.ManifestOutput, &cfg.KustomizeOutput, &cfg.HelmValuesOutput,
+		&cfg.ScanFindingsOutput, &cfg.ScanSarifOutput, &cfg.ListOutput, &cfg.QueryFile,
+		&cfg.CqueryFile, &cfg.BazelLog,
+	}
+	for _, path := range outputs {
+		if *path != "" {
+			*path = suffixPath(*path, entry.suffix())
+		}
+	}
+
+	return &cfg
+}
+
+// build and push the matrix entries, running up to matrix_parallelism at once
+func (p *Config) executeMatrix(ctx context.Context, o *options, env buildGetter, res *result) error {
+	failFast := p.MatrixFailFast == nil || *p.MatrixFailFast
+
+	if p.managesRegistry() {
+		svc, err := o.ecrClient(p)
+		if err != nil {
+			return err
+		}
+
+		err = p.setupRegistry(res, svc)
+		if err != nil {
+			return err
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(p.matrixParallelism())
+
+	results := make([]*result, len(p.Matrix))
+	errs := make([]error, len(p.Matrix))
+	for i, entry := range p.Matrix {
+		i, entry := i, entry
+		g.Go(func() error {
+			// entries not started yet are skipped after a failure
+			if failFast && gctx.Err() != nil {
+				return nil
+			}
+
+			results[i] = newResult()
+			cfg := p.matrixConfig(entry)
+			errs[i] = cfg.applyTags(env)
+			if errs[i] == nil {
+				errs[i] = cfg.execute(gctx, o, env, results[i])
+			}
+			if errs[i] != nil {
+				log.Printf("matrix entry %s failed: %s", entry.name(), errs[i])
+				if failFast {
+					return errs[i]
+				}
+			}
+
+			return nil
+		})
+	}
+	g.Wait()
+
+	// report the entries in the order they are listed
+	var failed []string
+	for i, entry := range p.Matrix {
+		if results[i] == nil {
+			continue
+		}
+		res.merge(entry.name(), results[i])
+
+		if errs[i] != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", entry.name(), errs[i]))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d matrix entries failed: %s", len(failed), len(p.Matrix), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// number of matrix entries built at once, one at a time by default
+func (p *Config) matrixParallelism() int {
+	if p.MatrixParallelism > 0 {
+		return p.MatrixParallelism
+	}
+
+	return 1
+}
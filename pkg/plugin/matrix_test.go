@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestMatrixDecode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  matrix
+		err   bool
+	}{
+		{value: ""},
+		{
+			value: `[{"target": "//api:push", "repository": "api", "bes_keywords": ["service=api"]}, {"name": "web", "target": "//web:push"}]`,
+			want: matrix{
+				{Target: "//api:push", Repository: "api", BesKeywords: []string{"service=api"}},
+				{Name: "web", Target: "//web:push"},
+			},
+		},
+		{value: "//:push", err: true},
+	}
+
+	for _, test := range tests {
+		var got matrix
+		err := got.Decode(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.value, err)
+		}
+
+		if !test.err && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v is not equal to %v", got, test.want)
+		}
+	}
+}
+
+func TestMatrixConfig(t *testing.T) {
+	p := Config{
+		Repository:     "default",
+		EnvFile:        "out/image.env",
+		BazelLog:       "bazel.log",
+		VerifyRegistry: true,
+		WarmImages:     []string{"docker-hub/library/alpine:3"},
+	}
+
+	tests := []struct {
+		entry matrixEntry
+		want  Config
+	}{
+		{
+			entry: matrixEntry{Target: "//api:push", Repository: "api"},
+			want:  Config{Target: "//api:push", Repository: "api", EnvFile: "out/image-api-push.env", BazelLog: "bazel-api-push.log"},
+		},
+		{
+			entry: matrixEntry{Name: "web", Target: "//web:push"},
+			want:  Config{Target: "//web:push", Repository: "default", EnvFile: "out/image-web.env", BazelLog: "bazel-web.log"},
+		},
+	}
+
+	for _, test := range tests {
+		got := p.matrixConfig(test.entry)
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("%+v is not equal to %+v", *got, test.want)
+		}
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "default",
+		Tag:        "v1",
+		Matrix: matrix{
+			{
+				Target:        "//api:push",
+				Repository:    "api",
+				BesKeywords:   []string{"service=api"},
+				BuildMetadata: []string{"SERVICE=api"},
+			},
+			{Target: "//web:push"},
+		},
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.calls) != 2 {
+		t.Fatalf("%d commands run, expected 2", len(runner.calls))
+	}
+
+	tests := []struct {
+		args       []string
+		repository string
+	}{
+		{
+			args:       []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--bes_keywords=service=api", "--build_metadata=SERVICE=api", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//api:push"},
+			repository: "api",
+		},
+		{
+			args:       []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//web:push"},
+			repository: "default",
+		},
+	}
+
+	for i, test := range tests {
+		call := runner.calls[i]
+		if !reflect.DeepEqual(test.args, call.args) {
+			t.Errorf("%v is not equal to %v", test.args, call.args)
+		}
+
+		if got, _ := lookupEnv(call.env, "DRONE_ECR_REPOSITORY"); got != test.repository {
+			t.Errorf("%v is not equal to %v", got, test.repository)
+		}
+	}
+}
+
+func TestRunMatrixFailure(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	entries := matrix{{Target: "//a:push"}, {Target: "//b:push"}, {Target: "//c:push"}}
+
+	tests := []struct {
+		failFast    *bool
+		parallelism int
+		calls       int
+		err         string
+	}{
+		{calls: 2, err: "1 of 3 matrix entries failed: //b:push: failed"},
+		{failFast: aws.Bool(false), calls: 3, err: "1 of 3 matrix entries failed: //b:push: failed"},
+		{failFast: aws.Bool(false), parallelism: 3, calls: 3, err: "1 of 3 matrix entries failed: //b:push: failed"},
+	}
+
+	for _, test := range tests {
+		cfg := Config{
+			Registry:          "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+			Repository:        "repository",
+			Matrix:            entries,
+			MatrixFailFast:    test.failFast,
+			MatrixParallelism: test.parallelism,
+		}
+		runner := &recordingRunner{err: errors.New("failed"), failTarget: "//b:push"}
+
+		err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%v is not equal to %v", err, test.err)
+		}
+
+		if len(runner.calls) != test.calls {
+			t.Errorf("%d commands run, expected %d", len(runner.calls), test.calls)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// write netrc to $HOME/.netrc for authenticated http_archive and http_file downloads,
+// returning a function restoring the previous file once the build is done
+func (p *Config) writeNetrc() (func(), error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(home, ".netrc")
+
+	previous, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	err = os.WriteFile(path, []byte(p.Netrc), 0600)
+	if err != nil {
+		return nil, err
+	}
+	// WriteFile keeps the mode of an existing file
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if existed {
+			err = os.WriteFile(path, previous, 0600)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			log.Printf("could not restore %s: %s", path, err)
+		}
+	}, nil
+}
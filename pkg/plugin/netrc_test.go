@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteNetrc(t *testing.T) {
+	tests := []struct {
+		previous string
+	}{
+		{},
+		{previous: "machine github.com login user password token\n"},
+	}
+
+	for _, test := range tests {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		path := filepath.Join(home, ".netrc")
+
+		if test.previous != "" {
+			err := os.WriteFile(path, []byte(test.previous), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		p := Config{Netrc: "machine artifactory.example.com login ci password secret\n"}
+		restore, err := p.writeNetrc()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("netrc has mode %s", info.Mode())
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != p.Netrc {
+			t.Errorf("got %q, want %q", data, p.Netrc)
+		}
+
+		restore()
+
+		data, err = os.ReadFile(path)
+		if test.previous == "" {
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("netrc was not removed: %v", err)
+			}
+		} else if string(data) != test.previous {
+			t.Errorf("got %q, want the previous %q", data, test.previous)
+		}
+	}
+}
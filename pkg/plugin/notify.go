@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// JSON payload posted to the webhook
+type webhookPayload struct {
+	Status     string  `json:"status"`
+	Registry   string  `json:"registry"`
+	Repository string  `json:"repository"`
+	Tag        string  `json:"tag"`
+	Digest     string  `json:"digest,omitempty"`
+	Duration   float64 `json:"duration"`
+	BuildLink  string  `json:"build_link,omitempty"`
+	Commit     string  `json:"commit,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// header holding the HMAC-SHA256 signature of the webhook body
+const signatureHeader = "X-Signature-256"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// send the configured notifications for the result
+func (p *Config) notify(getter buildGetter, res result) error {
+	err := p.notifyWebhook(getter, res)
+	if err != nil {
+		return err
+	}
+
+	return p.notifySlack(getter, res)
+}
+
+// whether a notification is sent when the step finishes
+func (p *Config) notifies() bool {
+	return p.WebhookURL != "" || p.SlackWebhook != ""
+}
+
+// look up the digest of the pushed image for the notifications, which do not fail the build
+// when it is not available
+func (p *Config) lookupDigest(o *options, svc ecriface.ECRAPI, res *result) {
+	if svc == nil {
+		var err error
+		svc, err = o.ecrClient(p)
+		if err != nil {
+			log.Printf("could not look up the digest for notifications: %s", err)
+			return
+		}
+	}
+
+	digest, err := p.imageDigest(svc)
+	if err != nil {
+		log.Printf("could not look up the digest for notifications: %s", err)
+		return
+	}
+
+	res.addImage(p.Registry, p.Repository, p.imageTag(), digest)
+}
+
+func (p *Config) notifyWebhook(getter buildGetter, res result) error {
+	if p.WebhookURL == "" {
+		return nil
+	}
+
+	payload := webhookPayload{
+		Status:     res.status(),
+		Registry:   p.Registry,
+		Repository: p.Repository,
+		Tag:        p.imageTag(),
+		Digest:     res.digest(),
+		Duration:   res.duration.Seconds(),
+		BuildLink:  getter.Uri(),
+		Commit:     getter.ScmRevision(),
+	}
+	if res.err != nil {
+		payload.Error = res.err.Error()
+	}
+
+	return sendWebhook(p.WebhookURL, p.WebhookSecret, payload)
+}
+
+// slack incoming webhook message
+type slackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+func (p *Config) notifySlack(getter buildGetter, res result) error {
+	if p.SlackWebhook == "" || !p.slackOn(res.status()) {
+		return nil
+	}
+
+	return sendWebhook(p.SlackWebhook, "", slackMessage{
+		Channel: p.SlackChannel,
+		Text:    slackText(p.imageName(res.digest()), getter.Uri(), res),
+	})
+}
+
+// whether slack should be notified for the status, defaults to all statuses
+func (p *Config) slackOn(status string) bool {
+	if len(p.SlackOn) == 0 {
+		return true
+	}
+
+	for _, s := range p.SlackOn {
+		if strings.TrimSpace(s) == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func slackText(image, link string, res result) string {
+	text := fmt.Sprintf(":white_check_mark: pushed `%s`", image)
+	if res.err != nil {
+		text = fmt.Sprintf(":x: failed to push `%s`", image)
+	}
+
+	if link != "" {
+		text += fmt.Sprintf(" (<%s|build>)", link)
+	}
+
+	return text
+}
+
+// image reference including the digest when it is known
+func (p *Config) imageName(digest string) string {
+	image := fmt.Sprintf("%s/%s:%s", p.Registry, p.Repository, p.imageTag())
+	if digest != "" {
+		image += "@" + digest
+	}
+
+	return image
+}
+
+// post the payload as JSON, signing the body when a secret is provided
+func sendWebhook(url, secret string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if secret != "" {
+		req.Header.Set(signatureHeader, "sha256="+sign(secret, body))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// hex encoded HMAC-SHA256 of the body
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// result with a single pushed image
+func testResult(digest string, duration time.Duration, err error) result {
+	res := result{duration: duration, err: err}
+	if digest != "" {
+		res.addImage("registry", "repository", "latest", digest)
+	}
+
+	return res
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	var got webhookPayload
+	var signature string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature = r.Header.Get(signatureHeader)
+		if signature != "sha256="+sign("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		got = webhookPayload{}
+		err = json.Unmarshal(body, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer server.Close()
+
+	p := Config{
+		Registry:      "registry",
+		Repository:    "repository",
+		Tag:           "v1",
+		WebhookURL:    server.URL,
+		WebhookSecret: "secret",
+	}
+
+	tests := []struct {
+		res  result
+		want webhookPayload
+	}{
+		{
+			res: testResult("sha256:abc", 2*time.Second, nil),
+			want: webhookPayload{
+				Status:     statusSuccess,
+				Registry:   "registry",
+				Repository: "repository",
+				Tag:        "v1",
+				Digest:     "sha256:abc",
+				Duration:   2,
+				BuildLink:  "test",
+				Commit:     "test",
+			},
+		},
+		{
+			res: testResult("", time.Second, errors.New("bazel failed")),
+			want: webhookPayload{
+				Status:     statusFailure,
+				Registry:   "registry",
+				Repository: "repository",
+				Tag:        "v1",
+				Duration:   1,
+				BuildLink:  "test",
+				Commit:     "test",
+				Error:      "bazel failed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := p.notify(newBuildMock(), test.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("%+v is not equal to %+v", test.want, got)
+		}
+	}
+
+	// rejected signatures fail the notification
+	p.WebhookSecret = "wrong"
+	err := p.notify(newBuildMock(), testResult("", time.Second, nil))
+	if err == nil {
+		t.Errorf("rejected webhook should have failed")
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	var got []slackMessage
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slackMessage
+		err := json.NewDecoder(r.Body).Decode(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		p    Config
+		res  result
+		want []slackMessage
+	}{
+		{
+			p:   Config{Registry: "registry", Repository: "repository", SlackWebhook: server.URL, SlackChannel: "#releases"},
+			res: testResult("sha256:abc", time.Second, nil),
+			want: []slackMessage{{
+				Channel: "#releases",
+				Text:    ":white_check_mark: pushed `registry/repository:latest@sha256:abc` (<test|build>)",
+			}},
+		},
+		{
+			p:   Config{Registry: "registry", Repository: "repository", Tag: "v1", SlackWebhook: server.URL},
+			res: testResult("", time.Second, errors.New("failed")),
+			want: []slackMessage{{
+				Text: ":x: failed to push `registry/repository:v1` (<test|build>)",
+			}},
+		},
+		// only notify about failures
+		{
+			p:   Config{Registry: "registry", Repository: "repository", SlackWebhook: server.URL, SlackOn: []string{statusFailure}},
+			res: testResult("sha256:abc", time.Second, nil),
+		},
+	}
+
+	for _, test := range tests {
+		got = nil
+
+		err := test.p.notify(newBuildMock(), test.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%+v is not equal to %+v", test.want, got)
+		}
+	}
+}
+
+func TestLookupDigest(t *testing.T) {
+	defer func() { testFailure = "" }()
+
+	p := Config{Registry: "registry", Repository: "repository", WebhookURL: "http://webhook"}
+	o := newOptions([]Option{WithECRClient(&mockECRClient{})})
+
+	tests := []struct {
+		failure string
+		want    string
+	}{
+		{want: testDigest},
+		// a failed lookup leaves the digest out of the notification
+		{failure: "DescribeImages"},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+
+		res := result{}
+		p.lookupDigest(o, nil, &res)
+
+		if res.digest() != test.want {
+			t.Errorf("%q is not equal to %q", res.digest(), test.want)
+		}
+	}
+}
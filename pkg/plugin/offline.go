@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"bytes"
+	"strings"
+)
+
+// bazel output of external dependencies that could not be fetched
+var fetchFailures = [][]byte{
+	[]byte("fetching repositories is disabled"),
+	[]byte("to fix, run\n\tbazel fetch"),
+	[]byte("Error downloading"),
+}
+
+// whether bazel output shows that an external dependency could not be fetched, e.g.
+// "External repository @rules_oci not found and fetching repositories is disabled"
+func fetchFailed(output []byte) bool {
+	for _, failure := range fetchFailures {
+		if bytes.Contains(output, failure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flags preventing bazel from fetching external dependencies over the network
+func (p *Config) offlineArgs() []string {
+	if !p.Offline {
+		return nil
+	}
+
+	args := []string{"--nofetch"}
+
+	for _, dir := range p.Distdir {
+		args = append(args, joinFlag("--distdir", strings.TrimSpace(dir)))
+	}
+
+	if p.RepositoryCache != "" {
+		args = append(args, joinFlag("--repository_cache", p.RepositoryCache))
+	}
+
+	return args
+}
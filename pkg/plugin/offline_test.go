@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOfflineArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Distdir: []string{"/cache/distdir"}},
+		},
+		{
+			plugin: Config{Offline: true},
+			want:   []string{"--nofetch"},
+		},
+		{
+			plugin: Config{Offline: true, Distdir: []string{"/cache/distdir", " /vendor"}, RepositoryCache: "/cache/repos"},
+			want:   []string{"--nofetch", "--distdir=/cache/distdir", "--distdir=/vendor", "--repository_cache=/cache/repos"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.offlineArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+type fetchFailedRunner struct {
+	recordingRunner
+}
+
+func (r *fetchFailedRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stderr, "ERROR: External repository @rules_oci not found and fetching repositories is disabled.")
+	return r.Run(ctx, name, args, env)
+}
+
+func TestRunOffline(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:     "//:push",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+		Offline:    true,
+	}
+
+	tests := []struct {
+		runner  Runner
+		offline bool
+	}{
+		{runner: &fetchFailedRunner{recordingRunner{err: errors.New("exit status 1")}}, offline: true},
+		// other failures are not blamed on offline mode
+		{runner: &outputRecordingRunner{recordingRunner{err: errors.New("exit status 1")}}},
+	}
+
+	for _, test := range tests {
+		err := Run(context.Background(), cfg, WithRunner(test.runner), WithECRClient(&mockECRClient{}))
+		if err == nil || strings.Contains(err.Error(), "offline mode") != test.offline {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// exit codes of a bazel run that ran out of memory, 33 is reported by bazel itself
+// and 137 by a client or server killed by the kernel OOM killer
+var oomExitCodes = []int{33, 137}
+
+// messages of a bazel server that ran out of heap
+var oomMessages = []string{
+	"java.lang.OutOfMemoryError",
+	"The Bazel server has run out of memory",
+}
+
+// whether a failed bazel run ran out of memory, its output is searched for JVM messages.
+// Cancelled runs are killed with SIGKILL and are not out of memory
+func bazelOOM(ctx context.Context, err error, output []byte) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var exitErr exitCoder
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) {
+		if status, ok := execErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+			return true
+		}
+	}
+
+	for _, code := range oomExitCodes {
+		if exitErr.ExitCode() == code {
+			return true
+		}
+	}
+
+	for _, msg := range oomMessages {
+		if bytes.Contains(output, []byte(msg)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// halve the jobs and the server heap for a retry after running out of memory,
+// false when there is nothing left to reduce
+func (p *Config) reduceParallelism() bool {
+	jobs, err := strconv.Atoi(p.Jobs)
+	if err != nil {
+		jobs = runtime.NumCPU()
+		if p.limits.cpus > 0 {
+			jobs = int(math.Ceil(p.limits.cpus))
+		}
+	}
+	if jobs <= 1 {
+		return false
+	}
+
+	reduced := jobs / 2
+	log.Printf("bazel ran out of memory, retrying with --jobs=%d instead of %d", reduced, jobs)
+	p.Jobs = strconv.Itoa(reduced)
+
+	if heap, ok := parseHeap(p.hostJvmMaxHeap()); ok {
+		log.Printf("reducing the bazel server heap from %dm to %dm", heap, heap/2)
+		p.HostJvmMaxHeap = fmt.Sprintf("%dm", heap/2)
+	}
+
+	return true
+}
+
+// parse a JVM heap size such as 4g or 512m in MiB
+func parseHeap(heap string) (int64, bool) {
+	if heap == "" {
+		return 0, false
+	}
+
+	units := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30, 't': 1 << 40}
+	unit := int64(1)
+	last := strings.ToLower(heap)[len(heap)-1]
+	if u, ok := units[last]; ok {
+		unit = u
+		heap = heap[:len(heap)-1]
+	}
+
+	n, err := strconv.ParseInt(heap, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	mib := n * unit >> 20
+	return mib, mib > 1
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestBazelOOM(t *testing.T) {
+	output := []byte("FATAL: bazel ran out of memory and crashed.\njava.lang.OutOfMemoryError: Java heap space\n")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx    context.Context
+		err    error
+		output []byte
+		oom    bool
+	}{
+		{err: errors.New("could not start")},
+		{err: exec.Command("sh", "-c", "exit 1").Run()},
+		{err: exec.Command("sh", "-c", "exit 33").Run(), oom: true},
+		{err: exec.Command("sh", "-c", "exit 137").Run(), oom: true},
+		{err: exec.Command("sh", "-c", "kill -9 $$").Run(), oom: true},
+		{err: exec.Command("sh", "-c", "exit 1").Run(), output: output, oom: true},
+		{ctx: cancelled, err: exec.Command("sh", "-c", "kill -9 $$").Run()},
+	}
+
+	for _, test := range tests {
+		if test.ctx == nil {
+			test.ctx = context.Background()
+		}
+
+		if got := bazelOOM(test.ctx, test.err, test.output); got != test.oom {
+			t.Errorf("%v: got %t, want %t", test.err, got, test.oom)
+		}
+	}
+}
+
+func TestReduceParallelism(t *testing.T) {
+	tests := []struct {
+		plugin  Config
+		reduced bool
+		jobs    string
+		heap    string
+	}{
+		{plugin: Config{Jobs: "1"}, jobs: "1"},
+		{plugin: Config{Jobs: "8"}, reduced: true, jobs: "4"},
+		{plugin: Config{Jobs: "8", HostJvmMaxHeap: "3g"}, reduced: true, jobs: "4", heap: "1536m"},
+		{plugin: Config{limits: resourceLimits{cpus: 3.5, memory: 8 << 30}}, reduced: true, jobs: "2", heap: "1024m"},
+	}
+
+	for _, test := range tests {
+		reduced := test.plugin.reduceParallelism()
+		if reduced != test.reduced || test.plugin.Jobs != test.jobs || test.plugin.HostJvmMaxHeap != test.heap {
+			t.Errorf("got %t %q %q, want %t %q %q", reduced, test.plugin.Jobs, test.plugin.HostJvmMaxHeap, test.reduced, test.jobs, test.heap)
+		}
+	}
+}
+
+func TestRunOOMRetry(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:     "//:push",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+		Jobs:       "4",
+	}
+	runner := &recordingRunner{err: exec.Command("sh", "-c", "exit 137").Run()}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(runner.calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(runner.calls))
+	}
+	if !strings.Contains(strings.Join(runner.calls[1].args, " "), "--jobs=2") {
+		t.Errorf("retry args %v do not halve the jobs", runner.calls[1].args)
+	}
+}
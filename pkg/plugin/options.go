@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"os/exec"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/inspector2"
+	"github.com/aws/aws-sdk-go/service/inspector2/inspector2iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+)
+
+// ECRClient is the ECR API used to manage repositories and images.
+type ECRClient interface {
+	ecriface.ECRAPI
+}
+
+// Runner runs external commands such as bazel.
+type Runner interface {
+	// Run runs the named command with args. A nil env inherits the environment of the current process.
+	Run(ctx context.Context, name string, args []string, env []string) error
+}
+
+// Option customizes how Run builds and pushes images.
+type Option func(*options)
+
+// WithECRClient sets the ECR client used instead of one created for the registry region.
+func WithECRClient(svc ECRClient) Option {
+	return func(o *options) {
+		o.ecr = svc
+	}
+}
+
+// WithInspectorClient sets the Amazon Inspector client used to export SBOMs for deny_licenses.
+func WithInspectorClient(svc inspector2iface.Inspector2API) Option {
+	return func(o *options) {
+		o.inspector = svc
+	}
+}
+
+// WithS3Client sets the S3 client used to read exported SBOMs.
+func WithS3Client(svc s3iface.S3API) Option {
+	return func(o *options) {
+		o.s3 = svc
+	}
+}
+
+// WithServiceQuotasClient sets the Service Quotas client used by check_quotas.
+func WithServiceQuotasClient(svc servicequotasiface.ServiceQuotasAPI) Option {
+	return func(o *options) {
+		o.quotas = svc
+	}
+}
+
+// WithRunner sets the runner used to execute commands.
+func WithRunner(runner Runner) Option {
+	return func(o *options) {
+		o.runner = runner
+	}
+}
+
+type options struct {
+	ecr       ECRClient
+	inspector inspector2iface.Inspector2API
+	s3        s3iface.S3API
+	quotas    servicequotasiface.ServiceQuotasAPI
+	runner    Runner
+	tokens    *tokenCache
+}
+
+// options constructor
+func newOptions(opts []Option) *options {
+	o := &options{runner: execRunner{}, tokens: newTokenCache()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// get the configured ECR client or create one for the registry, sharing auth tokens within the run
+func (o *options) ecrClient(p *Config) (ecriface.ECRAPI, error) {
+	if o.ecr != nil {
+		return &cachingClient{ECRAPI: o.ecr, cache: o.tokens}, nil
+	}
+
+	svc, err := p.ecrClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachingClient{ECRAPI: svc, cache: o.tokens}, nil
+}
+
+// get the configured Amazon Inspector client or create one for the registry region
+func (o *options) inspectorClient(p *Config) (inspector2iface.Inspector2API, error) {
+	if o.inspector != nil {
+		return o.inspector, nil
+	}
+
+	config, err := p.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return inspector2.New(session.New(config)), nil
+}
+
+// get the configured S3 client or create one for the registry region
+func (o *options) s3Client(p *Config) (s3iface.S3API, error) {
+	if o.s3 != nil {
+		return o.s3, nil
+	}
+
+	config, err := p.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.New(session.New(config)), nil
+}
+
+// get the configured Service Quotas client or create one for the registry region
+func (o *options) quotasClient(p *Config) (servicequotasiface.ServiceQuotasAPI, error) {
+	if o.quotas != nil {
+		return o.quotas, nil
+	}
+
+	config, err := p.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return servicequotas.New(session.New(config)), nil
+}
+
+// runs commands with os/exec, streaming their output to the console
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
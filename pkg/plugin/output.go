@@ -0,0 +1,28 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keys written to the env file, in order
+var envFileKeys = []string{"IMAGE", "DIGEST", "TAG", "REGISTRY", "REPOSITORY"}
+
+// write the image variables to a file that can be sourced by later steps
+func (p *Config) writeEnvFile(digest string) error {
+	vars := p.imageVars(digest)
+
+	var b strings.Builder
+	for _, key := range envFileKeys {
+		fmt.Fprintf(&b, "%s=%s\n", key, vars[key])
+	}
+
+	err := os.MkdirAll(filepath.Dir(p.EnvFile), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.EnvFile, []byte(b.String()), 0644)
+}
@@ -0,0 +1,36 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteEnvFile(t *testing.T) {
+	p := Config{
+		Registry:   "registry",
+		Repository: "repository",
+		Tag:        "v1",
+		EnvFile:    filepath.Join(t.TempDir(), "out", "image.env"),
+	}
+
+	err := p.writeEnvFile("sha256:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(p.EnvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `IMAGE=registry/repository@sha256:abc
+DIGEST=sha256:abc
+TAG=v1
+REGISTRY=registry
+REPOSITORY=repository
+`
+	if string(got) != want {
+		t.Errorf("%q is not equal to %q", want, string(got))
+	}
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// PutImage calls per second when retag_rate is not set, below the default ECR write TPS quota
+	defaultRetagRate = 5
+
+	// throttled calls are retried this many times with a growing interval
+	maxThrottleRetries = 8
+	maxPaceInterval    = 10 * time.Second
+)
+
+// paces API calls, backing off when they are throttled and speeding up again as they succeed
+type pacer struct {
+	base     time.Duration
+	interval time.Duration
+	last     time.Time
+}
+
+// pacer constructor
+func newPacer(rate float64) *pacer {
+	base := time.Duration(float64(time.Second) / rate)
+	return &pacer{base: base, interval: base}
+}
+
+// call fn once the interval since the previous call has passed, retrying throttled calls
+func (p *pacer) do(fn func() error) error {
+	for retries := 0; ; retries++ {
+		if wait := time.Until(p.last.Add(p.interval)); wait > 0 {
+			time.Sleep(wait)
+		}
+		p.last = time.Now()
+
+		err := fn()
+		if !request.IsErrorThrottle(err) || retries == maxThrottleRetries {
+			if err == nil && p.interval > p.base {
+				p.interval -= p.base
+			}
+			return err
+		}
+
+		p.interval *= 2
+		if p.interval > maxPaceInterval {
+			p.interval = maxPaceInterval
+		}
+		log.Printf("throttled, retrying in %s", p.interval)
+	}
+}
+
+// PutImage calls per second when adding tags
+func (p *Config) retagRate() float64 {
+	if p.RetagRate > 0 {
+		return p.RetagRate
+	}
+
+	return defaultRetagRate
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestPacer(t *testing.T) {
+	tests := []struct {
+		throttled int
+		calls     int
+		err       bool
+	}{
+		{calls: 1},
+		{throttled: 3, calls: 4},
+		// gives up after the maximum number of retries
+		{throttled: 20, calls: maxThrottleRetries + 1, err: true},
+	}
+
+	for _, test := range tests {
+		pace := newPacer(1000)
+
+		calls := 0
+		err := pace.do(func() error {
+			calls++
+			if calls <= test.throttled {
+				return awserr.New("ThrottlingException", "", errors.New("rate exceeded"))
+			}
+			return nil
+		})
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if calls != test.calls {
+			t.Errorf("%d is not equal to %d", test.calls, calls)
+		}
+	}
+
+	// other errors are not retried
+	calls := 0
+	err := newPacer(1000).do(func() error {
+		calls++
+		return errors.New("AccessDenied")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("errors other than throttling should fail without retrying")
+	}
+}
@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// architecture of the runner, replaced by tests
+var hostArch = unameArch
+
+// architectures of the machine names printed by uname -m
+var unameMachines = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"i386":    "386",
+	"i686":    "386",
+	"armv7l":  "arm",
+	"ppc64le": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// platform of the machine bazel runs on, the default of the platforms of the build when
+// neither the pipeline nor the operator set them
+const builtinPlatform = "@local_config_platform//:host"
+
+var detectedArch struct {
+	once sync.Once
+	arch string
+}
+
+// architecture of the machine from uname, which an emulated plugin binary does not
+// report, falling back to the architecture of the binary
+func unameArch() string {
+	detectedArch.once.Do(func() {
+		detectedArch.arch = runtime.GOARCH
+
+		out, err := exec.Command("uname", "-m").Output()
+		if err != nil {
+			return
+		}
+		if arch, ok := unameMachines[strings.TrimSpace(string(out))]; ok {
+			detectedArch.arch = arch
+		}
+	})
+
+	return detectedArch.arch
+}
+
+// names of the architectures in the cpu constraints of @platforms, the supported platform_arch values
+var platformCPUs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"386":     "x86_32",
+	"arm":     "armv7",
+	"ppc64le": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// architecture the platforms are selected for, the runner architecture unless platform_arch is set
+func (p *Config) platformArch() string {
+	if p.PlatformArch != "" {
+		return p.PlatformArch
+	}
+
+	return hostArch()
+}
+
+// expand {arch}, e.g. arm64, and {cpu}, e.g. aarch64, in a platform label
+func expandPlatform(label, arch string) string {
+	cpu, ok := platformCPUs[arch]
+	if !ok {
+		cpu = arch
+	}
+
+	return strings.NewReplacer("{arch}", arch, "{cpu}", cpu).Replace(label)
+}
+
+// platform flags for the architecture of the runner, so that one pipeline builds the
+// right images on amd64 and arm64 runners. The host platform is always the runner's,
+// and both default to the platforms of the fleet set by the operator, then to the
+// platform of the runner overriding any platforms of the bazelrc
+func (p *Config) platformArgs() []string {
+	platforms := p.Platforms
+	if platforms == "" {
+		platforms = p.DefaultPlatforms
+	}
+	if platforms == "" {
+		platforms = builtinPlatform
+	}
+
+	hostPlatform := p.HostPlatform
+	if hostPlatform == "" {
+		hostPlatform = p.DefaultHostPlatform
+	}
+	if hostPlatform == "" {
+		hostPlatform = builtinPlatform
+	}
+
+	return []string{
+		joinFlag("--platforms", expandPlatform(platforms, p.platformArch())),
+		joinFlag("--host_platform", expandPlatform(hostPlatform, hostArch())),
+	}
+}
@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformArgs(t *testing.T) {
+	arch := hostArch
+	hostArch = func() string { return "arm64" }
+	defer func() { hostArch = arch }()
+
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		// without settings the platform of the runner overrides any platforms of the bazelrc
+		{
+			plugin: Config{},
+			want:   []string{"--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host"},
+		},
+		{
+			plugin: Config{Platforms: "//platforms:linux_{arch}", HostPlatform: "//platforms:host_{cpu}"},
+			want:   []string{"--platforms=//platforms:linux_arm64", "--host_platform=//platforms:host_aarch64"},
+		},
+		// cross builds override the target architecture, the host stays the runner's
+		{
+			plugin: Config{Platforms: "@rules_go//go/toolchain:linux_{arch}", HostPlatform: "//platforms:linux_{arch}", PlatformArch: "amd64"},
+			want:   []string{"--platforms=@rules_go//go/toolchain:linux_amd64", "--host_platform=//platforms:linux_arm64"},
+		},
+		// the operator defaults apply unless the pipeline sets the platforms
+		{
+			plugin: Config{DefaultPlatforms: "//platforms:linux_{arch}", DefaultHostPlatform: "//platforms:host_{cpu}"},
+			want:   []string{"--platforms=//platforms:linux_arm64", "--host_platform=//platforms:host_aarch64"},
+		},
+		{
+			plugin: Config{Platforms: "//app:platform", DefaultPlatforms: "//platforms:linux_{arch}"},
+			want:   []string{"--platforms=//app:platform", "--host_platform=@local_config_platform//:host"},
+		},
+		{
+			plugin: Config{Platforms: "//platforms:{cpu}", PlatformArch: "s390x"},
+			want:   []string{"--platforms=//platforms:s390x", "--host_platform=@local_config_platform//:host"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.platformArgs()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+
+	for arch, valid := range map[string]bool{"arm64": true, "s390x": true, "aarch64": false, "x86": false} {
+		p := Config{Target: "//app:push", Platforms: "//platforms:linux_{arch}", PlatformArch: arch}
+		if err := p.validate(); (err == nil) != valid {
+			t.Errorf("%s: unexpected error: %v", arch, err)
+		}
+	}
+
+	// the builtin platform can only build for the architecture of the runner
+	for arch, valid := range map[string]bool{"arm64": true, "amd64": false} {
+		p := Config{Target: "//app:push", PlatformArch: arch}
+		if err := p.validate(); (err == nil) != valid {
+			t.Errorf("%s: unexpected error: %v", arch, err)
+		}
+	}
+
+	// only commands configuring targets take platforms
+	p := Config{Target: "//app:push", Command: "query", Platforms: "//platforms:linux_{arch}"}
+	if got := strings.Join(p.getArgs(newBuildMock()), " "); strings.Contains(got, "--platforms") {
+		t.Errorf("unexpected platforms in %s", got)
+	}
+}
+
+func TestUnameArch(t *testing.T) {
+	for machine, arch := range map[string]string{"x86_64": "amd64", "aarch64": "arm64", "arm64": "arm64", "armv7l": "arm", "s390x": "s390x"} {
+		if got := unameMachines[machine]; got != arch {
+			t.Errorf("%s: got %q, want %q", machine, got, arch)
+		}
+	}
+
+	if _, ok := platformCPUs[unameArch()]; !ok {
+		t.Errorf("unsupported runner architecture %q", unameArch())
+	}
+}
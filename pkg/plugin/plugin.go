@@ -0,0 +1,1620 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Config holds the plugin settings read from PLUGIN_* env vars.
+type Config struct {
+	Mode                 string
+	CIProvider           string `envconfig:"ci_provider"`
+	Target               string
+	Matrix               matrix
+	MatrixParallelism    int           `split_words:"true"`
+	MatrixFailFast       *bool         `split_words:"true"`
+	TargetKind           string        `split_words:"true"`
+	Registry             string        `required:"true"`
+	CreateRepository     bool          `split_words:"true"`
+	VerifyRegistry       bool          `split_words:"true"`
+	DockerConfigOutput   string        `split_words:"true"`
+	ReplicationRegions   []string      `split_words:"true"`
+	ReplicationWait      bool          `split_words:"true"`
+	ReplicationTimeout   time.Duration `split_words:"true"`
+	RegistryScanning     string        `split_words:"true"`
+	Repository           string
+	RepositoryPrefix     string   `split_words:"true"`
+	PullAccounts         []string `split_words:"true"`
+	RepositoryTags       []string `split_words:"true"`
+	TagCIMetadata        bool     `envconfig:"tag_ci_metadata"`
+	Team                 string
+	BuildNumberTag       bool   `split_words:"true"`
+	CreationTemplate     string `split_words:"true"`
+	RepositorySpec       string `split_words:"true"`
+	RepositorySpecWarn   bool   `split_words:"true"`
+	CheckQuotas          bool   `split_words:"true"`
+	Tag                  string
+	TagRules             []string      `split_words:"true"`
+	RetagRate            float64       `split_words:"true"`
+	ImageWaitTimeout     time.Duration `split_words:"true"`
+	MaxImageSize         string        `split_words:"true"`
+	MaxImageSizeWarn     bool          `split_words:"true"`
+	ScanWait             bool          `split_words:"true"`
+	ScanTimeout          time.Duration `split_words:"true"`
+	ScanFindingsOutput   string        `split_words:"true"`
+	ScanSarifOutput      string        `split_words:"true"`
+	FailOnSeverity       string        `split_words:"true"`
+	SbomPath             string        `split_words:"true"`
+	SbomScan             bool          `split_words:"true"`
+	DenyLicenses         []string      `split_words:"true"`
+	SbomExportTarget     string        `split_words:"true"`
+	SbomExportKmsKey     string        `split_words:"true"`
+	SignKey              string        `split_words:"true"`
+	Signer               string        `split_words:"true"`
+	SignPlugin           string        `split_words:"true"`
+	SignPluginConfig     []string      `split_words:"true"`
+	AccessKey            string        `split_words:"true"`
+	SecretKey            string        `split_words:"true"`
+	AssumeRole           string        `split_words:"true"`
+	ExternalID           string        `envconfig:"external_id"`
+	RateLimitBackoff     time.Duration `split_words:"true"`
+	TimeBudget           time.Duration `split_words:"true"`
+	TimeBudgetWebhook    string        `split_words:"true"`
+	Bazelrc              string
+	Command              string
+	BazelLog             string   `split_words:"true"`
+	CriticalPath         int      `split_words:"true"`
+	WorkspaceStatusCmd   string   `envconfig:"workspace_status_script"`
+	WorkspaceStatus      bool     `split_words:"true"`
+	StableStatusKeys     []string `split_words:"true"`
+	EmbedLabel           string   `split_words:"true"`
+	BuildTimestamp       string   `split_words:"true"`
+	BuildTimestampFormat string   `split_words:"true"`
+	MaxIdleSecs          string   `split_words:"true"`
+	BazelServer          string   `split_words:"true"`
+	BazelServiceUrl      string   `split_words:"true"`
+	ServeAddress         string   `split_words:"true"`
+	ServiceToken         string   `split_words:"true"`
+	ResourceDefaults     *bool    `split_words:"true"`
+	Jobs                 string   `split_words:"true"`
+	LocalRamResources    string   `split_words:"true"`
+	HostJvmMaxHeap       string   `split_words:"true"`
+	Platforms            string
+	HostPlatform         string   `split_words:"true"`
+	PlatformArch         string   `split_words:"true"`
+	OomRetry             *bool    `split_words:"true"`
+	RateLimitRetries     int      `split_words:"true"`
+	ExtraHosts           []string `split_words:"true"`
+	Netrc                string
+	GitToken             string   `split_words:"true"`
+	GitUsername          string   `split_words:"true"`
+	GitAskpass           string   `split_words:"true"`
+	SshKey               string   `split_words:"true"`
+	SshKnownHosts        string   `split_words:"true"`
+	DownloaderConfig     string   `split_words:"true"`
+	DownloaderMirror     string   `split_words:"true"`
+	DownloaderHosts      []string `split_words:"true"`
+	AllowedCommands      []string `ignored:"true"`
+	AllowHooks           bool     `ignored:"true"`
+	DefaultPlatforms     string   `ignored:"true"`
+	DefaultHostPlatform  string   `ignored:"true"`
+	CommandArgs          string   `split_words:"true"`
+	EngflowBesKeywords   bool     `split_words:"true"`
+	BuildWithoutTheBytes bool     `split_words:"true"`
+	RemoteDownloadRegex  string   `split_words:"true"`
+	TlsClientCertificate string   `split_words:"true"`
+	TlsClientKey         string   `split_words:"true"`
+	RemoteCacheAsync     *bool    `split_words:"true"`
+	RemoteCacheCompress  *bool    `envconfig:"remote_cache_compression"`
+	WorkerMaxInstances   []string `split_words:"true"`
+	WorkerQuitAfterBuild *bool    `split_words:"true"`
+	WorkerSandboxing     *bool    `split_words:"true"`
+	MultiplexSandboxing  *bool    `envconfig:"worker_multiplex_sandboxing"`
+	EnableBzlmod         *bool    `split_words:"true"`
+	LockfileMode         string   `split_words:"true"`
+	BazelRegistries      []string `split_words:"true"`
+	OverrideModules      []string `split_words:"true"`
+	Offline              bool
+	Distdir              []string
+	RepositoryCache      string   `split_words:"true"`
+	FetchTargets         []string `split_words:"true"`
+	FetchSync            bool     `split_words:"true"`
+	QueryExpression      string   `split_words:"true"`
+	QueryOutput          string   `split_words:"true"`
+	QueryFile            string   `split_words:"true"`
+	CqueryOutput         string   `split_words:"true"`
+	CqueryStarlarkExpr   string   `envconfig:"cquery_starlark_expression"`
+	CqueryFile           string   `split_words:"true"`
+	TargetArgs           string   `split_words:"true"`
+	PushRule             string   `split_words:"true"`
+	TestEnv              []string `split_words:"true"`
+	TestFilter           string   `split_words:"true"`
+	TestTagFilters       []string `split_words:"true"`
+	FlakyTestAttempts    string   `split_words:"true"`
+	TestRunsPerTarget    string   `split_words:"true"`
+	TestShardingStrategy string   `split_words:"true"`
+	TestSizeFilters      []string `split_words:"true"`
+	TestTimeout          []string `split_words:"true"`
+	PreCmds              []string `split_words:"true"`
+	PostCmds             []string `split_words:"true"`
+	Source               string
+	SourceUsername       string   `split_words:"true"`
+	SourcePassword       string   `split_words:"true"`
+	WarmImages           []string `split_words:"true"`
+	Push                 *bool
+	ImagePath            string   `split_words:"true"`
+	ChartPath            string   `split_words:"true"`
+	ChartRepository      string   `split_words:"true"`
+	UploadJobs           int      `split_words:"true"`
+	UploadChunkSize      string   `split_words:"true"`
+	DeleteTags           []string `split_words:"true"`
+	DeleteTagRegex       string   `split_words:"true"`
+	KeepLatest           int      `split_words:"true"`
+	DryRun               bool     `split_words:"true"`
+	ListOutput           string   `split_words:"true"`
+	LifecyclePolicy      string   `split_words:"true"`
+	ReconcileLifecycle   bool     `envconfig:"reconcile_lifecycle_policy"`
+	GCRepositories       []string `envconfig:"gc_repositories"`
+	GCMinAge             *int     `envconfig:"gc_min_age"`
+	GCAllowZeroAge       bool     `envconfig:"gc_allow_zero_age"`
+	GCConfirm            bool     `envconfig:"gc_confirm"`
+	Artifacts            []string
+	ArtifactsTarget      string   `split_words:"true"`
+	ArchiveTarget        string   `split_words:"true"`
+	ManifestTemplate     string   `split_words:"true"`
+	ManifestOutput       string   `split_words:"true"`
+	KustomizeOutput      string   `split_words:"true"`
+	KustomizeImage       string   `split_words:"true"`
+	HelmValuesOutput     string   `split_words:"true"`
+	HelmValuesKey        string   `split_words:"true"`
+	WebhookURL           string   `envconfig:"webhook_url"`
+	WebhookSecret        string   `split_words:"true"`
+	SlackWebhook         string   `split_words:"true"`
+	SlackChannel         string   `split_words:"true"`
+	SlackOn              []string `split_words:"true"`
+	EnvFile              string   `split_words:"true"`
+	SummaryFile          string   `split_words:"true"`
+	CollectNetworkUsage  *bool    `split_words:"true"`
+	AuditTable           string   `split_words:"true"`
+	AuditTarget          string   `split_words:"true"`
+	WhenEvents           []string `split_words:"true"`
+	WhenBranches         []string `split_words:"true"`
+
+	// directory of the files handed to bazel, the default temporary directory unless
+	// bazel runs in the bazel service
+	tempDir string
+
+	// build event protocol file written by bazel
+	buildEventFile string
+
+	// JSON trace profile written by bazel
+	profileFile string
+
+	// workspace status script generated by the plugin
+	statusScript string
+
+	// client certificate and key written for mTLS
+	tlsCertificateFile string
+	tlsKeyFile         string
+
+	// push rule of the target when push_rule is auto
+	detectedPushRule string
+
+	// limits of the container read from its cgroup
+	limits resourceLimits
+
+	// git variables of the configured credentials
+	gitEnv []string
+
+	// socket of the ssh agent holding ssh_key
+	sshEnv []string
+
+	// credentials of the role in assume_role and their variables
+	roleCredentials *credentials.Credentials
+	roleEnv         []string
+
+	// downloader config written by the plugin
+	downloaderConfigFile string
+
+	// end of the output of the last bazel run
+	outputTail *tailBuffer
+
+	// tags added to the image after it is pushed
+	additionalTags []string
+
+	// keywords and metadata added to the invocation of a matrix entry
+	besKeywords   []string
+	buildMetadata []string
+}
+
+// supported plugin modes
+const (
+	modeBazel  = "bazel"
+	modeCopy   = "copy"
+	modePush   = "push"
+	modeDelete = "delete"
+	modeList   = "list"
+	modePrune  = "prune"
+	modeWarm   = "warm"
+	modeGC     = "gc"
+	modeFetch  = "fetch"
+	modeQuery  = "query"
+	modeServe  = "serve"
+)
+
+// bazel commands allowed when BAZEL_ECR_ALLOWED_COMMANDS is not set
+var defaultAllowedCommands = []string{"run", "build", "test", "coverage", "query", "cquery"}
+
+// bazel flags running programs on the runner, which command_args cannot set
+var deniedCommandFlags = []string{"--run_under", "--script_path", "--workspace_status_command", "--credential_helper", "--experimental_credential_helper"}
+
+// env prefix of the operator settings
+const operatorPrefix = "bazel_ecr"
+
+// settings read only from the environment of the plugin image or runner, so that
+// pipelines and repository settings files cannot change them
+type operatorSettings struct {
+	AllowedCommands []string `split_words:"true"`
+	AllowHooks      bool     `split_words:"true"`
+	// platforms of the runner fleet, overridden by the platforms settings
+	Platforms    string
+	HostPlatform string `split_words:"true"`
+}
+
+// Load reads and validates the plugin settings from the environment.
+func Load() (Config, error) {
+	cfg := Config{}
+	err := cfg.setenv()
+	return cfg, err
+}
+
+// process plugin env vars
+func (p *Config) setenv() error {
+	// overrides from a mounted settings file
+	err := applySettingsFile(os.Getenv("PLUGIN_SETTINGS_FILE"))
+	if err != nil {
+		return err
+	}
+
+	// defaults from the repository config file
+	err = applyConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+
+	applyAliases(settingAliases)
+	interpolateSettings()
+
+	err = envconfig.Process("plugin", p)
+	if err != nil {
+		return err
+	}
+
+	var operator operatorSettings
+	err = envconfig.Process(operatorPrefix, &operator)
+	if err != nil {
+		return err
+	}
+	p.AllowedCommands = operator.AllowedCommands
+	p.AllowHooks = operator.AllowHooks
+	p.DefaultPlatforms = operator.Platforms
+	p.DefaultHostPlatform = operator.HostPlatform
+
+	// bazel and the hook commands inherit the environment
+	unsetSecretSettings(secretSettings, settingAliases)
+
+	// single image repositories are named after the CI repository by default
+	if p.Repository == "" {
+		env, err := newBuildEnv(p.CIProvider)
+		if err != nil {
+			return err
+		}
+
+		p.Repository = defaultRepository(env.Repo())
+	}
+
+	p.detectResources()
+
+	return p.validate()
+}
+
+// environment passed to commands run by the plugin
+func (p *Config) environ() []string {
+	var env []string
+
+	// convenience variables to be read by bazel workspace status scripts
+	if p.Registry != "" {
+		env = append(env, envWithPrefix("REGISTRY", p.Registry))
+
+		// account and region are only known for ECR registry hostnames
+		if region, err := p.region(); err == nil {
+			env = append(env, envWithPrefix("ACCOUNT_ID", p.registryID()))
+			env = append(env, envWithPrefix("REGION", region))
+		}
+	}
+	if p.Repository != "" {
+		env = append(env, envWithPrefix("REPOSITORY", p.Repository))
+	}
+	if p.Tag != "" {
+		env = append(env, envWithPrefix("TAG", p.Tag))
+	}
+	if p.Registry != "" && p.Repository != "" {
+		env = append(env, envWithPrefix("IMAGE", fmt.Sprintf("%s/%s:%s", p.Registry, p.Repository, p.imageTag())))
+	}
+
+	// setup the credentials used by the amazon-ecr-credential-helper, which are those of
+	// the assumed role when assume_role is set
+	if len(p.roleEnv) > 0 {
+		env = append(env, p.roleEnv...)
+	} else if p.AccessKey != "" && p.SecretKey != "" {
+		env = append(env, "AWS_ACCESS_KEY_ID="+p.AccessKey, "AWS_SECRET_ACCESS_KEY="+p.SecretKey)
+	}
+
+	// credentials of private git repositories
+	env = append(env, p.gitEnv...)
+	env = append(env, p.sshEnv...)
+
+	return env
+}
+
+// check that the settings required by the selected mode are present
+func (p *Config) validate() error {
+	switch p.Mode {
+	case "", modeBazel:
+		if len(p.Matrix) > 0 {
+			err := p.Matrix.validate()
+			if err != nil {
+				return err
+			}
+		} else if p.Target == "" {
+			return fmt.Errorf("must specify a target")
+		}
+	case modeCopy:
+		if p.Source == "" {
+			return fmt.Errorf("must specify a source image to copy")
+		}
+	case modePush:
+		if p.ImagePath == "" {
+			return fmt.Errorf("must specify an image path to push")
+		}
+	case modeDelete:
+		if len(p.DeleteTags) == 0 && p.DeleteTagRegex == "" {
+			return fmt.Errorf("must specify the tags to delete")
+		}
+	case modeList:
+	case modePrune:
+		if p.LifecyclePolicy == "" {
+			return fmt.Errorf("must specify a lifecycle policy")
+		}
+	case modeWarm:
+		if len(p.WarmImages) == 0 {
+			return fmt.Errorf("must specify the images to warm")
+		}
+	case modeGC:
+		if p.GCMinAge != nil && *p.GCMinAge < 0 {
+			return fmt.Errorf("gc_min_age must not be negative")
+		}
+		if p.GCMinAge != nil && *p.GCMinAge == 0 && !p.GCAllowZeroAge {
+			return fmt.Errorf("gc_min_age 0 collects images still being pushed, set gc_allow_zero_age to allow it")
+		}
+	case modeFetch:
+		if len(p.FetchTargets) == 0 && p.Target == "" && !p.FetchSync {
+			return fmt.Errorf("must specify the targets to fetch")
+		}
+	case modeQuery:
+		if p.QueryExpression == "" && p.Target == "" {
+			return fmt.Errorf("must specify a query expression or a target")
+		}
+	case modeServe:
+	default:
+		return fmt.Errorf("unsupported mode: %s", p.Mode)
+	}
+
+	if _, ok := platformCPUs[p.PlatformArch]; p.PlatformArch != "" && !ok {
+		return fmt.Errorf("unsupported platform architecture: %s", p.PlatformArch)
+	}
+
+	// the builtin platform is the runner's, which can not be another architecture
+	if p.PlatformArch != "" && p.PlatformArch != hostArch() && p.Platforms == "" && p.DefaultPlatforms == "" {
+		return fmt.Errorf("platform_arch %s requires platforms for the architecture", p.PlatformArch)
+	}
+
+	if p.TargetKind != "" && len(p.Matrix) > 0 {
+		return fmt.Errorf("target_kind and matrix are mutually exclusive")
+	}
+
+	if (p.Mode == modeServe || p.BazelServiceUrl != "") && p.ServiceToken == "" {
+		return fmt.Errorf("serve mode and bazel_service_url require a service_token")
+	}
+
+	if p.BazelServiceUrl != "" {
+		err := p.validateService()
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Command != "" && !p.commandAllowed(p.Command) {
+		return fmt.Errorf("command is not allowed: %s", p.Command)
+	}
+
+	if flag := deniedCommandFlag(p.CommandArgs); flag != "" {
+		return fmt.Errorf("command_args cannot set %s", flag)
+	}
+
+	if (len(p.PreCmds) > 0 || len(p.PostCmds) > 0) && !p.AllowHooks {
+		return fmt.Errorf("pre_cmds and post_cmds are disabled, set BAZEL_ECR_ALLOW_HOOKS in the plugin environment to enable them")
+	}
+
+	for _, override := range p.OverrideModules {
+		if !strings.Contains(override, "=") {
+			return fmt.Errorf("module override must be a module=path pair: %s", override)
+		}
+	}
+
+	for _, name := range p.TestEnv {
+		if strings.Contains(name, "=") {
+			return fmt.Errorf("test env must list variable names only: %s", name)
+		}
+	}
+
+	if p.DownloaderConfig != "" && p.DownloaderMirror != "" {
+		return fmt.Errorf("downloader_config and downloader_mirror are mutually exclusive")
+	}
+
+	if p.GitToken != "" && p.GitAskpass != "" {
+		return fmt.Errorf("git_token and git_askpass are mutually exclusive")
+	}
+
+	if (p.TlsClientCertificate == "") != (p.TlsClientKey == "") {
+		return fmt.Errorf("tls_client_certificate and tls_client_key must be set together")
+	}
+
+	if p.WorkspaceStatus && p.WorkspaceStatusCmd != "" {
+		return fmt.Errorf("workspace_status and workspace_status_script are mutually exclusive")
+	}
+
+	if p.BuildTimestamp != "" && !p.WorkspaceStatus {
+		return fmt.Errorf("build_timestamp requires workspace_status")
+	}
+
+	if err := checkBuildTimestamp(p.BuildTimestamp); err != nil {
+		return err
+	}
+
+	if p.WorkspaceStatusCmd != "" {
+		err := checkStatusScript(p.WorkspaceStatusCmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(p.Artifacts) > 0 && p.ArtifactsTarget == "" {
+		return fmt.Errorf("must specify an artifacts target")
+	}
+
+	switch p.Signer {
+	case "", signerCosign, signerNotation:
+	default:
+		return fmt.Errorf("unsupported signer: %s", p.Signer)
+	}
+
+	switch p.PushRule {
+	case "", pushRuleAuto, pushRuleOCI, pushRuleContainer, pushRuleCustom:
+	default:
+		return fmt.Errorf("unsupported push rule: %s", p.PushRule)
+	}
+
+	switch p.BazelServer {
+	case "", bazelServerWarm, bazelServerShutdown:
+	default:
+		return fmt.Errorf("unsupported bazel server option: %s", p.BazelServer)
+	}
+
+	if p.ManifestTemplate != "" && p.ManifestOutput == "" {
+		return fmt.Errorf("must specify a manifest output path")
+	}
+
+	if (p.ScanFindingsOutput != "" || p.ScanSarifOutput != "") && !p.ScanWait {
+		return fmt.Errorf("scan findings outputs require scan_wait")
+	}
+
+	if p.FailOnSeverity != "" && severityRank[strings.ToUpper(p.FailOnSeverity)] == 0 {
+		return fmt.Errorf("unsupported severity: %s", p.FailOnSeverity)
+	}
+
+	if p.FailOnSeverity != "" && !p.ScanWait && !p.SbomScan {
+		return fmt.Errorf("fail_on_severity requires scan_wait or sbom_scan")
+	}
+
+	if _, err := parseResourceTags(p.RepositoryTags); err != nil {
+		return err
+	}
+
+	if _, err := parseExtraHosts(p.ExtraHosts); err != nil {
+		return err
+	}
+
+	if p.ReconcileLifecycle && p.LifecyclePolicy == "" {
+		return fmt.Errorf("reconcile_lifecycle_policy requires lifecycle_policy")
+	}
+
+	if p.SbomScan && p.SbomPath == "" {
+		return fmt.Errorf("sbom_scan requires sbom_path")
+	}
+
+	if len(p.DenyLicenses) > 0 && (!p.ScanWait || p.SbomExportTarget == "" || p.SbomExportKmsKey == "") {
+		return fmt.Errorf("deny licenses requires scan_wait, sbom_export_target and sbom_export_kms_key")
+	}
+
+	if p.MaxImageSize != "" {
+		if _, err := parseSize(p.MaxImageSize); err != nil {
+			return err
+		}
+	}
+
+	if p.UploadChunkSize != "" {
+		err := checkUploadChunkSize(p.UploadChunkSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range p.TagRules {
+		if !strings.Contains(rule, tagRuleSeparator) {
+			return fmt.Errorf("invalid tag rule: %s", rule)
+		}
+	}
+
+	return nil
+}
+
+// whether the bazel command is in the allowlist
+func (p *Config) commandAllowed(command string) bool {
+	allowed := p.AllowedCommands
+	if len(allowed) == 0 {
+		allowed = defaultAllowedCommands
+	}
+
+	for _, c := range allowed {
+		if strings.TrimSpace(c) == command {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the first flag of args running programs on the runner
+func deniedCommandFlag(args string) string {
+	for _, arg := range strings.Fields(args) {
+		name, _, _ := strings.Cut(arg, "=")
+		for _, flag := range deniedCommandFlags {
+			if name == flag {
+				return flag
+			}
+		}
+	}
+
+	return ""
+}
+
+// whether the image should be pushed, pull requests only build by default
+func (p *Config) push(getter buildGetter) bool {
+	if p.Push != nil {
+		return *p.Push
+	}
+
+	return getter.Event() != "pull_request"
+}
+
+// options resolving the external repositories, shared by the build and the query commands
+// so that they see the same dependency graph
+func (p *Config) repositoryArgs() []string {
+	args := p.bzlmodArgs()
+	args = append(args, p.offlineArgs()...)
+
+	return append(args, p.downloaderArgs()...)
+}
+
+// bazel query with the startup options and the options resolving the external repositories
+func (p *Config) queryCommandArgs() []string {
+	args := append(p.startupArgs(), "query")
+
+	return append(args, p.repositoryArgs()...)
+}
+
+func (p *Config) getArgs(getter buildGetter) []string {
+	// append startup options
+	args := p.startupArgs()
+
+	command := "run"
+	if p.Command != "" {
+		command = p.Command
+	}
+
+	// build the push target instead of running it when not pushing
+	pushTarget := command == "run"
+	building := pushTarget && !p.push(getter)
+	if building {
+		command = "build"
+	}
+
+	args = append(args, command)
+
+	if p.buildEventFile != "" {
+		args = append(args, joinFlag("--build_event_json_file", p.buildEventFile))
+		if p.collectNetworkUsage() {
+			args = append(args, "--experimental_collect_system_network_usage")
+		}
+	}
+	if p.profileFile != "" {
+		args = append(args, joinFlag("--profile", p.profileFile))
+	}
+
+	// Include Drone CI info for EngFlow
+	if p.EngflowBesKeywords {
+		args = append(args,
+			"--bes_keywords=engflow:CiCdPipelineName="+getter.PipelineName(),
+			"--bes_keywords=engflow:CiCdJobName="+getter.JobName(),
+			"--bes_keywords=engflow:CiCdUri="+getter.Uri(),
+			"--bes_keywords=engflow:BuildScmRemote="+getter.ScmRemote(),
+			"--bes_keywords=engflow:BuildScmBranch="+getter.ScmBranch(),
+			"--bes_keywords=engflow:BuildScmRevision="+getter.ScmRevision(),
+		)
+	}
+
+	for _, keyword := range p.besKeywords {
+		args = append(args, joinFlag("--bes_keywords", keyword))
+	}
+	for _, metadata := range p.buildMetadata {
+		args = append(args, joinFlag("--build_metadata", metadata))
+	}
+
+	args = append(args, p.repositoryArgs()...)
+	args = append(args, p.remoteDownloadArgs(command)...)
+	args = append(args, p.remoteCacheArgs()...)
+	args = append(args, p.tlsArgs()...)
+
+	if isBuildCommand(command) || command == "cquery" {
+		args = append(args, p.platformArgs()...)
+	}
+
+	if isBuildCommand(command) {
+		args = append(args, p.stampArgs()...)
+		args = append(args, p.resourceArgs()...)
+		args = append(args, p.workerArgs()...)
+	}
+
+	if isTestCommand(command) {
+		args = append(args, p.testArgs()...)
+	}
+
+	if command == "cquery" {
+		args = append(args, p.cqueryArgs()...)
+	}
+
+	// the defines are also needed to analyze the push target when it is only built
+	if pushTarget {
+		args = append(args, p.pushFlags()...)
+	}
+
+	// append run and target
+	if p.CommandArgs != "" {
+		args = append(args, p.CommandArgs, p.Target)
+	} else {
+		args = append(args, p.Target)
+	}
+
+	var runArgs []string
+	if command == "run" {
+		runArgs = p.pushRunArgs()
+	}
+	if p.TargetArgs != "" && !building {
+		runArgs = append(runArgs, p.TargetArgs)
+	}
+	if len(runArgs) > 0 {
+		args = append(args, "--")
+		args = append(args, runArgs...)
+	}
+
+	return args
+}
+
+func (p *Config) createRepository(svc ecriface.ECRAPI) error {
+	// ensure a repository name was provided
+	if p.Repository == "" {
+		return fmt.Errorf("must specify a repository")
+	}
+
+	// get target registry URL from auth token
+	result, err := svc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return err
+	}
+
+	url := aws.StringValue(result.AuthorizationData[0].ProxyEndpoint)
+	targetRegistry := strings.TrimPrefix(url, "https://")
+
+	// check that the provided credentials are for the specified registry
+	if p.Registry != targetRegistry {
+		return fmt.Errorf("provided credentials are not for the specified registry: %s", p.Registry)
+	}
+
+	// create repository in the registry account, which can differ from the account of the credentials
+	input := &ecr.CreateRepositoryInput{}
+	input.SetRepositoryName(p.Repository)
+	input.SetRegistryId(p.registryID())
+	_, err = svc.CreateRepository(input)
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		// ignore repo exists error
+		if ok && aerr.Code() == ecr.ErrCodeRepositoryAlreadyExistsException {
+			return nil
+		}
+		return err
+	}
+
+	// policies of existing repositories are left untouched
+	if len(p.PullAccounts) > 0 {
+		return p.setPullPolicy(svc)
+	}
+
+	return nil
+}
+
+// Run builds and pushes the image described by the config.
+func Run(ctx context.Context, cfg Config, opts ...Option) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	cfg.applyRepositoryPrefix()
+
+	o := newOptions(opts)
+	if cfg.Mode == modeServe {
+		return cfg.serve(ctx, o.runner)
+	}
+
+	// bazel and the other commands push with the credentials of the assumed role
+	if cfg.AssumeRole != "" {
+		err = cfg.assumeRole()
+		if err != nil {
+			return err
+		}
+	}
+
+	// bazel commands run in the bazel service of the pipeline when set
+	if cfg.BazelServiceUrl != "" {
+		o.runner = newServiceRunner(cfg.BazelServiceUrl, cfg.ServiceToken, o.runner)
+
+		// the service only shares the workspace with the step
+		dir, err := workspaceTempDir()
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		cfg.tempDir = dir
+	}
+
+	return cfg.run(ctx, o)
+}
+
+// runs the bazel command
+func (p *Config) run(ctx context.Context, o *options) error {
+	env, err := newBuildEnv(p.CIProvider)
+	if err != nil {
+		return err
+	}
+
+	// builds not matching the conditions are a no-op
+	if !p.when(env) {
+		return nil
+	}
+
+	res := newResult()
+
+	res.err = p.build(ctx, o, env, res)
+	res.duration = time.Since(res.start)
+	printTimings(os.Stdout, res)
+
+	// notify about failed builds as well as successful ones
+	notifyErr := p.notify(env, *res)
+	summaryErr := p.writeSummary(res)
+	if res.err != nil {
+		return res.err
+	}
+	if notifyErr != nil {
+		return notifyErr
+	}
+
+	return summaryErr
+}
+
+// run the hook commands around building and pushing the images
+func (p *Config) build(ctx context.Context, o *options, env buildGetter, res *result) error {
+	// the server is shut down whether or not the build succeeds
+	defer p.stopServer(ctx, o, res)
+
+	if len(p.ExtraHosts) > 0 {
+		err := res.time("extra_hosts", p.writeExtraHosts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.Netrc != "" {
+		restore, err := p.writeNetrc()
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	if p.GitToken != "" || p.GitAskpass != "" {
+		script, err := p.setupGitCredentials()
+		if err != nil {
+			return err
+		}
+		if script != "" {
+			defer os.Remove(script)
+		}
+	}
+
+	if p.SshKey != "" {
+		stop, err := p.startSSHAgent(ctx, o.runner)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	if p.DownloaderConfig != "" || p.DownloaderMirror != "" {
+		path, err := p.writeDownloaderConfig()
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+	}
+
+	// the hooks see the tags of the image, matrix entries apply the tags to their own config
+	hooks := p
+	if len(p.Matrix) > 0 {
+		cfg := *p
+		hooks = &cfg
+	}
+
+	err := hooks.applyTags(env)
+	if err != nil {
+		return err
+	}
+
+	if len(p.PreCmds) > 0 {
+		err := res.time("pre_cmds", func() error {
+			return hooks.runHooks(ctx, o.runner, p.PreCmds)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.TargetKind != "" {
+		err := res.time("expand_targets", func() error {
+			return p.expandTargets(ctx, o.runner)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(p.Matrix) > 0 {
+		err = p.executeMatrix(ctx, o, env, res)
+	} else {
+		err = p.execute(ctx, o, env, res)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(p.PostCmds) > 0 {
+		return res.time("post_cmds", func() error {
+			return hooks.runHooks(ctx, o.runner, p.PostCmds)
+		})
+	}
+
+	return nil
+}
+
+// build and push the image, recording the outcome in the result
+func (p *Config) execute(ctx context.Context, o *options, env buildGetter, res *result) error {
+	// repositories are only needed when pushing
+	push := p.push(env) && p.pushesImage()
+	createRepository := p.CreateRepository && push
+
+	var svc ecriface.ECRAPI
+	err := res.time("setup", func() error {
+		if !p.ecrNeeded(push) {
+			return nil
+		}
+
+		var err error
+		svc, err = o.ecrClient(p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.setupRegistry(res, svc)
+	if err != nil {
+		return err
+	}
+
+	if createRepository {
+		err = res.time("create_repository", func() error {
+			return p.createRepository(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.ReconcileLifecycle && push {
+		err = res.time("lifecycle_policy", func() error {
+			return p.reconcileLifecyclePolicy(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if (len(p.RepositoryTags) > 0 || p.TagCIMetadata) && push {
+		err = res.time("repository_tags", func() error {
+			tags, err := p.resourceTags(env)
+			if err != nil {
+				return err
+			}
+
+			return p.reconcileRepositoryTags(svc, tags)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.RepositorySpec != "" && push {
+		err = res.time("repository_drift", func() error {
+			return p.checkRepositoryDrift(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.CheckQuotas && push {
+		err = res.time("check_quotas", func() error {
+			quotas, err := o.quotasClient(p)
+			if err != nil {
+				return err
+			}
+
+			return p.checkQuotas(svc, quotas)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = res.time(p.phaseName(), func() error {
+		switch p.Mode {
+		case modeCopy, modePush:
+			// pull requests and push: false do not write to the registry
+			if !push {
+				log.Printf("skipping the %s of %s since the build does not push", p.Mode, p.Repository)
+				return nil
+			}
+
+			if p.Mode == modeCopy {
+				return p.copyImage(svc)
+			}
+			return p.pushImage(svc)
+		case modeDelete:
+			return p.deleteImages(svc)
+		case modeList:
+			return p.listRepository(svc, os.Stdout)
+		case modePrune:
+			return p.pruneImages(svc, os.Stdout)
+		case modeWarm:
+			return p.warmCache(svc)
+		case modeGC:
+			return p.collectGarbage(svc, os.Stdout, time.Now())
+		case modeFetch:
+			return p.fetch(ctx, o.runner)
+		case modeQuery:
+			return p.query(ctx, o.runner)
+		default:
+			return p.runBazel(ctx, o.runner, env, res)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.SbomScan {
+		err = res.time("sbom_scan", func() error {
+			return p.scanSBOM(ctx, o.runner)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !push {
+		return nil
+	}
+
+	if p.ChartPath != "" {
+		err = res.time("push_chart", func() error {
+			return p.pushChart(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = res.time("publish", func() error {
+		return p.publish(o, env, svc, res)
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.notifies() && res.digest() == "" {
+		p.lookupDigest(o, svc, res)
+	}
+
+	if p.SignKey != "" {
+		return res.time("sign", func() error {
+			return p.signImage(ctx, o.runner, svc)
+		})
+	}
+
+	return nil
+}
+
+// whether the run calls the ECR API
+func (p *Config) ecrNeeded(push bool) bool {
+	switch {
+	case !p.runsBazel():
+		return true
+	case p.managesRegistry():
+		return true
+	}
+
+	return push && (p.readsImage() || p.managesRepository())
+}
+
+// whether publishing reads the pushed image from the registry
+func (p *Config) readsImage() bool {
+	return len(p.additionalTags) > 0 || p.MaxImageSize != "" || p.ArchiveTarget != "" || p.ScanWait || p.ReplicationWait || p.SignKey != "" || p.digestOutputs()
+}
+
+// whether the push changes the repository or pushes to it besides the image
+func (p *Config) managesRepository() bool {
+	return p.CreateRepository || p.ChartPath != "" || p.RepositorySpec != "" || p.CheckQuotas || p.ReconcileLifecycle || len(p.RepositoryTags) > 0 || p.TagCIMetadata
+}
+
+// manage the registry settings, which do not depend on the image that is built
+func (p *Config) setupRegistry(res *result, svc ecriface.ECRAPI) error {
+	if p.VerifyRegistry {
+		err := res.time("verify_registry", func() error {
+			return p.verifyRegistry(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.DockerConfigOutput != "" {
+		err := res.time("docker_config", func() error {
+			return p.writeDockerConfig(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.RegistryScanning != "" {
+		err := res.time("registry_scanning", func() error {
+			return p.reconcileRegistryScanning(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.CreationTemplate != "" {
+		err := res.time("creation_template", func() error {
+			return p.applyCreationTemplate(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// warm the pull-through cache before other modes fetch base images
+	if len(p.WarmImages) > 0 && p.Mode != modeWarm {
+		err := res.time("warm_cache", func() error {
+			return p.warmCache(svc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// whether registry settings are managed whether or not the image is pushed
+func (p *Config) managesRegistry() bool {
+	return p.VerifyRegistry || p.RegistryScanning != "" || p.CreationTemplate != "" || len(p.WarmImages) > 0 || p.DockerConfigOutput != ""
+}
+
+// whether the mode pushes an image, other modes manage the images in the repository
+func (p *Config) pushesImage() bool {
+	switch p.Mode {
+	case modeDelete, modeList, modePrune, modeWarm, modeGC, modeFetch, modeQuery:
+		return false
+	}
+
+	return true
+}
+
+// whether the mode runs bazel instead of calling the ECR API
+func (p *Config) runsBazel() bool {
+	switch p.Mode {
+	case "", modeBazel, modeFetch, modeQuery:
+		return true
+	}
+
+	return false
+}
+
+// name of the phase doing the main work of the mode
+func (p *Config) phaseName() string {
+	if p.Mode == "" {
+		return modeBazel
+	}
+
+	return p.Mode
+}
+
+// exec bazel
+func (p *Config) runBazel(ctx context.Context, runner Runner, env buildGetter, res *result) error {
+	// record build events for the timings, the summary and the upload wait
+	f, err := os.CreateTemp(p.tempDir, "build-events-*.json")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	p.buildEventFile = f.Name()
+
+	// the push target needs its push rule whether it is run or only built
+	if p.PushRule == pushRuleAuto && (p.Command == "" || p.Command == "run") {
+		rule, err := p.detectPushRule(ctx, runner)
+		if err != nil {
+			return err
+		}
+
+		p.detectedPushRule = rule
+	}
+
+	if p.WorkspaceStatus {
+		script, err := p.writeStatusScript(env)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(script)
+
+		p.statusScript = script
+	}
+
+	if p.TlsClientCertificate != "" {
+		dir, err := p.writeClientCertificate()
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	// the trace profile holds the critical path of the build
+	if p.CriticalPath > 0 {
+		f, err := os.CreateTemp(p.tempDir, "profile-*.json")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+
+		p.profileFile = f.Name()
+	}
+
+	environ := append(os.Environ(), p.environ()...)
+
+	started := time.Now()
+	err = p.runArgs(ctx, runner, p.getArgs(env), environ)
+
+	// retry once with less parallelism, unless oom_retry is false
+	if err != nil && (p.OomRetry == nil || *p.OomRetry) && bazelOOM(ctx, err, p.outputTail.Bytes()) && p.reduceParallelism() {
+		err = p.runArgs(ctx, runner, p.getArgs(env), environ)
+	}
+
+	for retry := 0; err != nil && dockerHubRateLimited(p.outputTail.Bytes()); retry++ {
+		if retry == 0 {
+			log.Print(rateLimitGuidance)
+		}
+		if !p.waitRateLimit(ctx, retry) {
+			if ctx.Err() == nil {
+				err = fmt.Errorf("base image pull rate limited by Docker Hub: %w", err)
+			}
+			break
+		}
+
+		err = p.runArgs(ctx, runner, p.getArgs(env), environ)
+	}
+	exited := time.Now()
+	res.setExitCode(err)
+
+	if err == nil {
+		p.checkTimeBudget(env, exited.Sub(started), res)
+	}
+
+	// --nofetch fails the build when a dependency is not available locally
+	if err != nil && p.Offline && fetchFailed(p.outputTail.Bytes()) {
+		err = fmt.Errorf("bazel failed in offline mode, external dependencies missing from the distdir or repository cache cannot be fetched: %w", err)
+	}
+
+	if p.buildEventFile != "" {
+		events, bepErr := parseBuildEvents(p.buildEventFile)
+		if bepErr != nil {
+			log.Printf("could not parse build events: %s", bepErr)
+		}
+		if events != nil {
+			events.setUploadWait(exited)
+			if events.UploadWait > 0 {
+				log.Printf("bazel waited %.1fs for uploads after the build finished", events.UploadWait)
+			}
+			if events.Cache != nil {
+				log.Printf("remote cache hit rate %.1f%%, disk cache hit rate %.1f%%",
+					events.Cache.RemoteHitRate, events.Cache.DiskHitRate)
+			}
+			if events.Cache != nil && events.Cache.SystemNetworkReceivedBytes > 0 {
+				log.Printf("the machine received %d bytes over the network while bazel ran", events.Cache.SystemNetworkReceivedBytes)
+			}
+		}
+		res.events = events
+	}
+
+	if p.profileFile != "" {
+		actions, profileErr := parseCriticalPath(p.profileFile)
+		if profileErr != nil {
+			log.Printf("could not parse the profile: %s", profileErr)
+		} else if len(actions) > 0 {
+			printCriticalPath(os.Stdout, actions, p.CriticalPath)
+		}
+	}
+
+	return err
+}
+
+// run bazel with the given arguments, writing the output to bazel_log when set
+// and keeping the end of it to explain failures
+func (p *Config) runArgs(ctx context.Context, runner Runner, args []string, environ []string) error {
+	p.outputTail = &tailBuffer{}
+
+	if p.BazelLog != "" {
+		return p.runLogged(ctx, runner, args, environ)
+	}
+
+	if r, ok := runner.(outputRunner); ok {
+		return r.RunOutput(ctx, "bazel", args, environ, io.MultiWriter(os.Stdout, p.outputTail), io.MultiWriter(os.Stderr, p.outputTail))
+	}
+
+	return runner.Run(ctx, "bazel", args, environ)
+}
+
+// publish outputs that depend on the pushed image
+func (p *Config) publish(o *options, env buildGetter, svc ecriface.ECRAPI, res *result) error {
+	// images can take a moment to be visible after the push
+	if p.readsImage() {
+		err := p.waitForImage(svc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.MaxImageSize != "" {
+		err := p.checkImageSize(svc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.ScanWait {
+		digest, err := p.imageDigest(svc)
+		if err != nil {
+			return err
+		}
+
+		findings, err := p.waitForScan(svc, digest)
+		if err != nil {
+			return err
+		}
+
+		var counts map[string]int64
+		if findings.ImageScanFindings != nil {
+			counts = aws.Int64ValueMap(findings.ImageScanFindings.FindingSeverityCounts)
+		}
+		res.addScan(p.Repository, digest, counts)
+
+		if p.ScanFindingsOutput != "" {
+			err = p.writeScanFindings(findings)
+			if err != nil {
+				return err
+			}
+		}
+
+		if p.ScanSarifOutput != "" {
+			err = p.writeScanSARIF(findings, digest)
+			if err != nil {
+				return err
+			}
+		}
+
+		// fail after the findings are written so that they can be reviewed
+		err = p.checkScanSeverity(findings, digest)
+		if err != nil {
+			return err
+		}
+
+		if len(p.DenyLicenses) > 0 {
+			inspector, err := o.inspectorClient(p)
+			if err != nil {
+				return err
+			}
+
+			s3svc, err := o.s3Client(p)
+			if err != nil {
+				return err
+			}
+
+			err = p.checkLicenses(inspector, s3svc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(p.additionalTags) > 0 {
+		err := p.tagImage(svc, p.additionalTags)
+		if err != nil {
+			return err
+		}
+
+		err = p.verifyTags(svc, append([]string{p.imageTag()}, p.additionalTags...))
+		if err != nil {
+			return err
+		}
+	}
+
+	// downstream regional deploys start once the step completes
+	if p.ReplicationWait {
+		digest, err := p.imageDigest(svc)
+		if err != nil {
+			return err
+		}
+
+		err = p.waitForReplication(svc, digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.ArchiveTarget != "" {
+		digest, err := p.imageDigest(svc)
+		if err != nil {
+			return err
+		}
+
+		uploader, err := p.s3Uploader()
+		if err != nil {
+			return err
+		}
+
+		err = p.archiveImage(svc, uploader, digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	// artifacts are published alongside images built by bazel
+	if len(p.Artifacts) > 0 && (p.Mode == "" || p.Mode == modeBazel) {
+		uploader, err := p.s3Uploader()
+		if err != nil {
+			return err
+		}
+
+		err = p.uploadArtifacts(uploader, bazelBin)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !p.digestOutputs() {
+		return nil
+	}
+
+	digest, err := p.imageDigest(svc)
+	if err != nil {
+		return err
+	}
+	res.addImage(p.Registry, p.Repository, p.imageTag(), digest)
+
+	if p.ManifestTemplate != "" {
+		err = p.renderManifest(digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.KustomizeOutput != "" {
+		err = p.writeKustomizeImages(digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.HelmValuesOutput != "" {
+		err = p.writeHelmValues(digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.EnvFile != "" {
+		err = p.writeEnvFile(digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.AuditTable != "" || p.AuditTarget != "" {
+		err = p.audit(env, digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// whether any output referencing the pushed digest is configured
+func (p *Config) digestOutputs() bool {
+	return p.ManifestTemplate != "" || p.KustomizeOutput != "" || p.HelmValuesOutput != "" ||
+		p.EnvFile != "" || p.SummaryFile != "" || p.AuditTable != "" || p.AuditTarget != ""
+}
+
+// parse AWS region from registry URL
+func (p *Config) region() (string, error) {
+	splitRegistry := strings.Split(p.Registry, ".")
+
+	// avoid index out of bounds
+	if len(splitRegistry) < 4 {
+		return "", fmt.Errorf("could not parse region from registry: %s", p.Registry)
+	}
+
+	return splitRegistry[3], nil
+}
+
+// account ID of the registry, parsed from the registry hostname
+func (p *Config) registryID() string {
+	return strings.Split(p.Registry, ".")[0]
+}
+
+// aws config for the registry region
+func (p *Config) awsConfig() (*aws.Config, error) {
+	region, err := p.region()
+	if err != nil {
+		return nil, err
+	}
+
+	config := aws.NewConfig().WithRegion(region)
+
+	// use the plugin credentials instead of the default credential chain
+	if p.AccessKey != "" && p.SecretKey != "" {
+		config = config.WithCredentials(credentials.NewStaticCredentials(p.AccessKey, p.SecretKey, ""))
+	}
+
+	// manage the registry of another account with a role in that account, the role is
+	// assumed once and shared by every client
+	if p.AssumeRole != "" {
+		if p.roleCredentials == nil {
+			p.roleCredentials = stscreds.NewCredentials(session.New(config), p.AssumeRole, func(r *stscreds.AssumeRoleProvider) {
+				// the credentials passed to bazel are not refreshed
+				r.Duration = time.Hour
+				if p.ExternalID != "" {
+					r.ExternalID = aws.String(p.ExternalID)
+				}
+			})
+		}
+		config = config.WithCredentials(p.roleCredentials)
+	}
+
+	return config, nil
+}
+
+// resolve the credentials of the assumed role for the commands run by the plugin
+func (p *Config) assumeRole() error {
+	config, err := p.awsConfig()
+	if err != nil {
+		return err
+	}
+
+	creds, err := config.Credentials.Get()
+	if err != nil {
+		return fmt.Errorf("could not assume role %s: %w", p.AssumeRole, err)
+	}
+
+	p.roleEnv = []string{
+		"AWS_ACCESS_KEY_ID=" + creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + creds.SessionToken,
+	}
+
+	return nil
+}
+
+// get an ecr service client
+func (p *Config) ecrClient() (*ecr.ECR, error) {
+	config, err := p.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return ecr.New(session.New(), config), nil
+}
+
+func envWithPrefix(key, val string) string {
+	return fmt.Sprintf("%s_%s=%s", "DRONE_ECR", key, val)
+}
+
+func joinFlag(flag, value string) string {
+	return fmt.Sprintf("%s=%s", flag, value)
+}
+
+// --flag or --noflag for a boolean bazel flag
+func boolFlag(flag string, value bool) string {
+	if value {
+		return "--" + flag
+	}
+
+	return "--no" + flag
+}
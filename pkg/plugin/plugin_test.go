@@ -0,0 +1,831 @@
+package plugin
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+type buildMock struct {
+	event string
+}
+
+func newBuildMock() *buildMock {
+	return &buildMock{event: "push"}
+}
+
+func (b *buildMock) PipelineName() string {
+	return "test"
+}
+
+func (b *buildMock) JobName() string {
+	return "test"
+}
+
+func (b *buildMock) Uri() string {
+	return "test"
+}
+
+func (s *buildMock) ScmRemote() string {
+	return "test"
+}
+
+func (s *buildMock) ScmBranch() string {
+	return "test"
+}
+
+func (s *buildMock) ScmRevision() string {
+	return "test"
+}
+
+func (s *buildMock) Event() string {
+	return s.event
+}
+
+func (s *buildMock) Repo() string {
+	return "test"
+}
+
+func (s *buildMock) BuildNumber() string {
+	return "test"
+}
+
+func (s *buildMock) Author() string {
+	return "test"
+}
+
+type mockECRClient struct {
+	ecriface.ECRAPI
+}
+
+var testFailure string
+
+const testDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+func (m *mockECRClient) GetAuthorizationToken(input *ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	if testFailure == "GetAuthorizationToken" {
+		return nil, errors.New("GetAuthorizationToken")
+	}
+
+	data := &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []*ecr.AuthorizationData{
+			{
+				ProxyEndpoint: aws.String("https://0123456789.dkr.ecr.us-east-1.amazonaws.com"),
+				// base64 encoded "AWS:password"
+				AuthorizationToken: aws.String("QVdTOnBhc3N3b3Jk"),
+			},
+		},
+	}
+
+	return data, nil
+}
+
+func (m *mockECRClient) CreateRepository(input *ecr.CreateRepositoryInput) (*ecr.CreateRepositoryOutput, error) {
+	if testFailure == "CreateRepository" {
+		return nil, errors.New("CreateRepository")
+	}
+
+	if testFailure == "CreateRepositoryRepoExists" {
+		return nil, awserr.New(ecr.ErrCodeRepositoryAlreadyExistsException, "", errors.New("CreateRepositoryRepoExists"))
+	}
+
+	return &ecr.CreateRepositoryOutput{}, nil
+}
+
+func (m *mockECRClient) DescribeImages(input *ecr.DescribeImagesInput) (*ecr.DescribeImagesOutput, error) {
+	if testFailure == "DescribeImages" {
+		return nil, errors.New("DescribeImages")
+	}
+
+	return &ecr.DescribeImagesOutput{
+		ImageDetails: []*ecr.ImageDetail{
+			{
+				RepositoryName: input.RepositoryName,
+				ImageDigest:    aws.String(testDigest),
+				ImageTags:      []*string{input.ImageIds[0].ImageTag},
+			},
+		},
+	}, nil
+}
+
+func TestGetArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "test"},
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", Bazelrc: ".bazelrc.custom"},
+			want:   []string{"--bazelrc=.bazelrc.custom", "run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", Bazelrc: ".bazelrc.custom", Command: "test"},
+			want:   []string{"--bazelrc=.bazelrc.custom", "test", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", Bazelrc: ".bazelrc.custom", CommandArgs: "--config=test"},
+			want:   []string{"--bazelrc=.bazelrc.custom", "run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--config=test", "test"},
+		},
+		{
+			plugin: Config{Target: "test", Bazelrc: ".bazelrc.custom", TargetArgs: "--var"},
+			want:   []string{"--bazelrc=.bazelrc.custom", "run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test", "--", "--var"},
+		},
+		{
+			plugin: Config{Target: "test", EngflowBesKeywords: false},
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", EngflowBesKeywords: true},
+			want: []string{"run",
+				"--bes_keywords=engflow:CiCdPipelineName=test",
+				"--bes_keywords=engflow:CiCdJobName=test",
+				"--bes_keywords=engflow:CiCdUri=test",
+				"--bes_keywords=engflow:BuildScmRemote=test",
+				"--bes_keywords=engflow:BuildScmBranch=test",
+				"--bes_keywords=engflow:BuildScmRevision=test",
+				"--platforms=@local_config_platform//:host",
+				"--host_platform=@local_config_platform//:host",
+				"test"},
+		},
+		{
+			plugin: Config{Target: "test", buildEventFile: "/tmp/events.json"},
+			want:   []string{"run", "--build_event_json_file=/tmp/events.json", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", buildEventFile: "/tmp/events.json", CollectNetworkUsage: aws.Bool(false)},
+			want:   []string{"run", "--build_event_json_file=/tmp/events.json", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", profileFile: "/tmp/profile.json"},
+			want:   []string{"run", "--profile=/tmp/profile.json", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		{
+			plugin: Config{Target: "test", Command: "test", TestEnv: []string{"DRONE_COMMIT", " API_URL"}},
+			want:   []string{"test", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--test_env=DRONE_COMMIT", "--test_env=API_URL", "test"},
+		},
+		// test env is only passed to tests
+		{
+			plugin: Config{Target: "test", TestEnv: []string{"DRONE_COMMIT"}},
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.getArgs(newBuildMock())
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestGetArgsBuildOnly(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		event  string
+		want   []string
+	}{
+		// pull requests only build the target
+		{
+			plugin: Config{Target: "test", TargetArgs: "--var"},
+			event:  "pull_request",
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		// explicitly disable pushing
+		{
+			plugin: Config{Target: "test", Push: aws.Bool(false)},
+			event:  "push",
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		// container_push targets need their make variables to be analyzed
+		{
+			plugin: Config{Target: "test", Registry: "registry", Repository: "app", Tag: "v1", PushRule: pushRuleContainer},
+			event:  "pull_request",
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--define=registry=registry", "--define=repository=app", "--define=tag=v1", "test"},
+		},
+		// explicitly enable pushing for pull requests
+		{
+			plugin: Config{Target: "test", Push: aws.Bool(true)},
+			event:  "pull_request",
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+		// custom commands are not changed
+		{
+			plugin: Config{Target: "test", Command: "test", Push: aws.Bool(false)},
+			event:  "push",
+			want:   []string{"test", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "test"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.getArgs(&buildMock{event: test.event})
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestSetenv(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want Config
+		fail bool
+	}{
+		// test setting struct fields
+		{
+			env: map[string]string{
+				"PLUGIN_TAG":               "tag",
+				"PLUGIN_TARGET":            "target",
+				"PLUGIN_REGISTRY":          "registry",
+				"PLUGIN_REPOSITORY":        "repository",
+				"PLUGIN_ACCESS_KEY":        "access",
+				"PLUGIN_SECRET_KEY":        "secret",
+				"PLUGIN_BAZELRC":           ".bazelrc.custom",
+				"PLUGIN_CREATE_REPOSITORY": "true",
+				"PLUGIN_ARTIFACTS":         "charts/*.tgz,api/openapi.json",
+				"PLUGIN_ARTIFACTS_TARGET":  "s3://bucket/prefix",
+			},
+			want: Config{
+				Tag:              "tag",
+				Target:           "target",
+				Registry:         "registry",
+				Repository:       "repository",
+				AccessKey:        "access",
+				SecretKey:        "secret",
+				Bazelrc:          ".bazelrc.custom",
+				CreateRepository: true,
+				Artifacts:        []string{"charts/*.tgz", "api/openapi.json"},
+				ArtifactsTarget:  "s3://bucket/prefix",
+			},
+			fail: false,
+		},
+		// the command allowlist is only read from the operator environment
+		{
+			env: map[string]string{
+				"PLUGIN_TARGET":              "target",
+				"PLUGIN_REGISTRY":            "registry",
+				"PLUGIN_REPOSITORY":          "repository",
+				"PLUGIN_ALLOWED_COMMANDS":    "clean",
+				"BAZEL_ECR_ALLOWED_COMMANDS": "run,build",
+			},
+			want: Config{
+				Target:          "target",
+				Registry:        "registry",
+				Repository:      "repository",
+				AllowedCommands: []string{"run", "build"},
+			},
+		},
+		// renamed settings are read under their old names
+		{
+			env: map[string]string{
+				"PLUGIN_TARGET":               "target",
+				"PLUGIN_REGISTRY":             "registry",
+				"PLUGIN_REPOSITORY":           "repository",
+				"PLUGIN_CQUERY_STARLARK_EXPR": "target.label",
+			},
+			want: Config{
+				Target:             "target",
+				Registry:           "registry",
+				Repository:         "repository",
+				CqueryStarlarkExpr: "target.label",
+			},
+		},
+		// test empty environment
+		{
+			env:  map[string]string{},
+			want: Config{},
+			fail: true,
+		},
+	}
+
+	// the limits of the test runner do not apply
+	cgroupRoot = t.TempDir()
+	defer func() { cgroupRoot = "/sys/fs/cgroup" }()
+
+	for _, test := range tests {
+		setEnvMap(test.env)
+
+		got := Config{}
+		err := got.setenv()
+		if err != nil && !test.fail {
+			t.Errorf(err.Error())
+		}
+
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+
+		for _, secret := range []string{"PLUGIN_ACCESS_KEY", "PLUGIN_SECRET_KEY"} {
+			if _, ok := os.LookupEnv(secret); ok && !test.fail {
+				t.Errorf("%s was not removed from the environment", secret)
+			}
+		}
+
+		unsetEnvMap(test.env)
+		// set from the old names
+		os.Unsetenv("PLUGIN_CQUERY_STARLARK_EXPRESSION")
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	tests := []struct {
+		p    Config
+		want []string
+	}{
+		{
+			p: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", Tag: "v1", AccessKey: "access", SecretKey: "secret"},
+			want: []string{
+				"DRONE_ECR_REGISTRY=0123456789.dkr.ecr.us-east-1.amazonaws.com",
+				"DRONE_ECR_ACCOUNT_ID=0123456789",
+				"DRONE_ECR_REGION=us-east-1",
+				"DRONE_ECR_REPOSITORY=repository",
+				"DRONE_ECR_TAG=v1",
+				"DRONE_ECR_IMAGE=0123456789.dkr.ecr.us-east-1.amazonaws.com/repository:v1",
+				"AWS_ACCESS_KEY_ID=access",
+				"AWS_SECRET_ACCESS_KEY=secret",
+			},
+		},
+		// account and region are not exported for other registries
+		{
+			p: Config{Registry: "registry", Repository: "repository"},
+			want: []string{
+				"DRONE_ECR_REGISTRY=registry",
+				"DRONE_ECR_REPOSITORY=repository",
+				"DRONE_ECR_IMAGE=registry/repository:latest",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.p.environ()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		p    Config
+		fail bool
+	}{
+		{
+			p: Config{Target: "test"},
+		},
+		{
+			p:    Config{},
+			fail: true,
+		},
+		{
+			p: Config{Mode: modeCopy, Source: "nginx:latest"},
+		},
+		{
+			p:    Config{Mode: modeCopy, Target: "test"},
+			fail: true,
+		},
+		{
+			p: Config{Mode: modePush, ImagePath: "image.tar"},
+		},
+		{
+			p:    Config{Mode: modePush},
+			fail: true,
+		},
+		{
+			p:    Config{Mode: "unknown", Target: "test"},
+			fail: true,
+		},
+		{
+			p: Config{Mode: modeDelete, DeleteTags: []string{"feature-*"}},
+		},
+		{
+			p:    Config{Mode: modeDelete},
+			fail: true,
+		},
+		{
+			p: Config{Mode: modeGC},
+		},
+		{
+			p:    Config{Mode: modeGC, GCMinAge: aws.Int(0)},
+			fail: true,
+		},
+		{
+			p: Config{Mode: modeGC, GCMinAge: aws.Int(0), GCAllowZeroAge: true},
+		},
+		{
+			p:    Config{Mode: modeGC, GCMinAge: aws.Int(-1), GCAllowZeroAge: true},
+			fail: true,
+		},
+		{
+			p: Config{Target: "test", Command: "coverage"},
+		},
+		{
+			p:    Config{Target: "test", Command: "clean"},
+			fail: true,
+		},
+		{
+			p: Config{Target: "test", Command: "clean", AllowedCommands: []string{"run", "clean"}},
+		},
+		{
+			p:    Config{Target: "test", Command: "test", AllowedCommands: []string{"run"}},
+			fail: true,
+		},
+		{
+			p: Config{Target: "test", CommandArgs: "--config=ci"},
+		},
+		{
+			p:    Config{Target: "test", PreCmds: []string{"make"}},
+			fail: true,
+		},
+		{
+			p: Config{Target: "test", PreCmds: []string{"make"}, AllowHooks: true},
+		},
+		// the bazel service only runs the commands of steps holding its token
+		{
+			p:    Config{Mode: modeServe},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", BazelServiceUrl: "http://bazel:8080"},
+			fail: true,
+		},
+		{
+			p: Config{Target: "test", BazelServiceUrl: "http://bazel:8080", ServiceToken: "token"},
+		},
+		{
+			p:    Config{Target: "test", BazelServiceUrl: "http://bazel:8080", ServiceToken: "token", BazelServer: bazelServerShutdown},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", BazelServiceUrl: "http://bazel:8080", ServiceToken: "token", Netrc: "machine example.com"},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", BazelServiceUrl: "http://bazel:8080", ServiceToken: "token", ExtraHosts: []string{"mirror=10.0.0.1"}},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", CommandArgs: "--config=ci --run_under=/bin/sh"},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", TestEnv: []string{"API_URL=http://localhost"}},
+			fail: true,
+		},
+		{
+			p:    Config{Target: "test", OverrideModules: []string{"rules_oci"}},
+			fail: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.p.validate()
+		if (err != nil) != test.fail {
+			t.Errorf("%+v: unexpected validation result: %v", test.p, err)
+		}
+	}
+}
+
+// records the registry repositories are created in and their policy
+type mockCreateClient struct {
+	mockECRClient
+
+	registryID string
+	policy     string
+}
+
+func (m *mockCreateClient) CreateRepository(input *ecr.CreateRepositoryInput) (*ecr.CreateRepositoryOutput, error) {
+	m.registryID = aws.StringValue(input.RegistryId)
+	return &ecr.CreateRepositoryOutput{}, nil
+}
+
+func TestAssumeRole(t *testing.T) {
+	p := Config{
+		Registry:        "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		AccessKey:       "access",
+		SecretKey:       "secret",
+		AssumeRole:      "arn:aws:iam::0123456789:role/ecr",
+		roleCredentials: credentials.NewStaticCredentials("role-access", "role-secret", "role-token"),
+	}
+
+	err := p.assumeRole()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := p.environ()
+	want := map[string]string{
+		"AWS_ACCESS_KEY_ID":     "role-access",
+		"AWS_SECRET_ACCESS_KEY": "role-secret",
+		"AWS_SESSION_TOKEN":     "role-token",
+	}
+	for key, val := range want {
+		if got, _ := lookupEnv(env, key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, got, val)
+		}
+	}
+
+	// the plugin credentials are not passed along
+	for _, kv := range env {
+		if strings.Contains(kv, "=access") {
+			t.Errorf("unexpected plugin credentials in %v", kv)
+		}
+	}
+}
+
+func TestCreateRepositoryRegistryID(t *testing.T) {
+	testFailure = ""
+
+	svc := &mockCreateClient{}
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", AssumeRole: "arn:aws:iam::0123456789:role/ecr"}
+
+	err := p.createRepository(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.registryID != "0123456789" {
+		t.Errorf("%v is not equal to %v", "0123456789", svc.registryID)
+	}
+}
+
+func TestRegion(t *testing.T) {
+	tests := []struct {
+		p    Config
+		want string
+		fail bool
+	}{
+		{
+			p:    Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com"},
+			want: "us-east-1",
+			fail: false,
+		},
+		{
+			p:    Config{},
+			fail: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.p.region()
+
+		// check for invalid registries
+		if err != nil && !test.fail {
+			t.Errorf("invalid registry should have failed")
+		}
+
+		// check if regions match
+		if test.want != got {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", Tag: "test"}
+
+	testFailure = ""
+	got, err := p.imageDigest(&mockECRClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != testDigest {
+		t.Errorf("%v is not equal to %v", testDigest, got)
+	}
+
+	testFailure = "DescribeImages"
+	_, err = p.imageDigest(&mockECRClient{})
+	if err == nil {
+		t.Errorf("describe images failure should have failed")
+	}
+
+	testFailure = ""
+}
+
+// reports the image as missing for the first lag calls
+type mockLaggingClient struct {
+	mockECRClient
+
+	lag   int
+	calls int
+}
+
+func (m *mockLaggingClient) DescribeImages(input *ecr.DescribeImagesInput) (*ecr.DescribeImagesOutput, error) {
+	m.calls++
+	if m.calls <= m.lag {
+		return nil, awserr.New(ecr.ErrCodeImageNotFoundException, "", errors.New("ImageNotFound"))
+	}
+
+	return m.mockECRClient.DescribeImages(input)
+}
+
+func TestWaitForImage(t *testing.T) {
+	testFailure = ""
+	imagePollInterval = time.Millisecond
+	defer func() { imagePollInterval = 2 * time.Second }()
+
+	tests := []struct {
+		lag     int
+		timeout time.Duration
+		err     bool
+	}{
+		{lag: 0},
+		{lag: 3, timeout: time.Second},
+		{lag: 1000, timeout: 10 * time.Millisecond, err: true},
+	}
+
+	for _, test := range tests {
+		svc := &mockLaggingClient{lag: test.lag}
+		p := Config{Repository: "repository", ImageWaitTimeout: test.timeout}
+
+		err := p.waitForImage(svc)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for lag %d: %v", test.lag, err)
+		}
+
+		if !test.err && svc.calls != test.lag+1 {
+			t.Errorf("%d calls, expected %d", svc.calls, test.lag+1)
+		}
+	}
+
+	testFailure = "DescribeImages"
+	p := Config{Repository: "repository"}
+	err := p.waitForImage(&mockLaggingClient{})
+	if err == nil {
+		t.Errorf("describe images failure should have failed")
+	}
+	testFailure = ""
+}
+
+// artifact with a fixed digest
+type digestArtifact struct {
+	artifact
+	digest string
+}
+
+func (a digestArtifact) Digest() (v1.Hash, error) {
+	return v1.NewHash(a.digest)
+}
+
+func TestIgnoreRepush(t *testing.T) {
+	testFailure = ""
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"}
+	pushErr := errors.New("tag invalid: the image tag already exists and cannot be overwritten")
+
+	tag, err := name.NewTag(p.Registry + "/repository:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := name.NewDigest(p.Registry + "/repository@" + testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherDigest := "sha256:" + strings.Repeat("0", 64)
+
+	tests := []struct {
+		dst    name.Reference
+		digest string
+		err    error
+		want   error
+	}{
+		{dst: tag, digest: testDigest},
+		// the tag already points at the pushed digest
+		{dst: tag, digest: testDigest, err: pushErr},
+		{dst: tag, digest: otherDigest, err: pushErr, want: pushErr},
+		{dst: digest, digest: testDigest, err: pushErr, want: pushErr},
+	}
+
+	for _, test := range tests {
+		got := p.ignoreRepush(&mockECRClient{}, test.dst, digestArtifact{digest: test.digest}, test.err)
+		if got != test.want {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestDefaultRepository(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{repo: "org/service", want: "org/service"},
+		{repo: "Org/My_Service", want: "org/my_service"},
+		{repo: "org/service name", want: "org/service-name"},
+		{repo: "org/-service-", want: "org/service"},
+		// ECR rejects runs of separators
+		{repo: "org/a_-b", want: "org/a-b"},
+		{repo: "org/my  service", want: "org/my-service"},
+		{repo: "org/a__b..c", want: "org/a-b-c"},
+		{repo: "org/" + strings.Repeat("a", 300), want: "org/" + strings.Repeat("a", 252)},
+		{repo: "", want: ""},
+	}
+
+	for _, test := range tests {
+		got := defaultRepository(test.repo)
+		if got != test.want {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestApplyRepositoryPrefix(t *testing.T) {
+	p := Config{
+		Repository:       "service",
+		RepositoryPrefix: "team-payments/",
+		Matrix: matrix{
+			{Target: "//api:push", Repository: "api"},
+			{Target: "//web:push", Repository: "team-payments/web"},
+			{Target: "//worker:push"},
+			{Target: "//legacy:push", Repository: "team-payments-legacy/api"},
+		},
+	}
+
+	p.applyRepositoryPrefix()
+
+	if p.Repository != "team-payments/service" {
+		t.Errorf("%v is not equal to %v", "team-payments/service", p.Repository)
+	}
+
+	want := []string{"team-payments/api", "team-payments/web", "", "team-payments/team-payments-legacy/api"}
+	for i, entry := range p.Matrix {
+		if entry.Repository != want[i] {
+			t.Errorf("%v is not equal to %v", want[i], entry.Repository)
+		}
+	}
+}
+
+func TestCreateRepository(t *testing.T) {
+	tests := []struct {
+		p       Config
+		failure string
+	}{
+		// test successful create
+		{
+			p: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"},
+		},
+		// test that repo exists error is ignored
+		{
+			p:       Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"},
+			failure: "CreateRepositoryRepoExists",
+		},
+		// test empty repository failure
+		{
+			p:       Config{},
+			failure: "must specify a repository",
+		},
+		// test auth token failure
+		{
+			p:       Config{Repository: "repository"},
+			failure: "GetAuthorizationToken",
+		},
+		// test for mismatched registry failure
+		{
+			p:       Config{Registry: "thisdoesnotmatch.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"},
+			failure: "provided credentials are not for the specified registry",
+		},
+		// test for generic repo creation failure
+		{
+			p:       Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"},
+			failure: "CreateRepository",
+		},
+	}
+
+	for _, test := range tests {
+		// set global failure variable to inform mocks
+		testFailure = test.failure
+
+		err := test.p.createRepository(&mockECRClient{})
+		if err != nil && !strings.HasPrefix(err.Error(), testFailure) {
+			t.Errorf(err.Error())
+		}
+	}
+}
+
+// set environment
+func setEnvMap(env map[string]string) {
+	for key, val := range env {
+		os.Setenv(key, val)
+	}
+}
+
+// unset environment
+func unsetEnvMap(env map[string]string) {
+	for key := range env {
+		os.Unsetenv(key)
+	}
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// repository policy document
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid       string              `json:"Sid"`
+	Effect    string              `json:"Effect"`
+	Principal map[string][]string `json:"Principal"`
+	Action    []string            `json:"Action"`
+}
+
+// repository policy allowing the pull accounts to pull images
+func (p *Config) pullPolicy() (string, error) {
+	var principals []string
+	for _, account := range p.PullAccounts {
+		account = strings.TrimSpace(account)
+
+		// accept role and user ARNs as well as account IDs
+		if !strings.HasPrefix(account, "arn:") {
+			account = fmt.Sprintf("arn:aws:iam::%s:root", account)
+		}
+		principals = append(principals, account)
+	}
+
+	policy := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{
+			{
+				Sid:       "AllowPull",
+				Effect:    "Allow",
+				Principal: map[string][]string{"AWS": principals},
+				Action:    []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"},
+			},
+		},
+	}
+
+	out, err := json.Marshal(policy)
+	return string(out), err
+}
+
+// grant the pull accounts access to the repository
+func (p *Config) setPullPolicy(svc ecriface.ECRAPI) error {
+	policy, err := p.pullPolicy()
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.SetRepositoryPolicy(&ecr.SetRepositoryPolicyInput{
+		RegistryId:     aws.String(p.registryID()),
+		RepositoryName: aws.String(p.Repository),
+		PolicyText:     aws.String(policy),
+	})
+
+	return err
+}
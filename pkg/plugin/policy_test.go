@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func (m *mockCreateClient) SetRepositoryPolicy(input *ecr.SetRepositoryPolicyInput) (*ecr.SetRepositoryPolicyOutput, error) {
+	m.policy = aws.StringValue(input.PolicyText)
+	return &ecr.SetRepositoryPolicyOutput{}, nil
+}
+
+func TestPullPolicy(t *testing.T) {
+	p := Config{PullAccounts: []string{"111111111111", " arn:aws:iam::222222222222:role/deploy"}}
+
+	got, err := p.pullPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"Version":"2012-10-17","Statement":[{"Sid":"AllowPull","Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111111111111:root","arn:aws:iam::222222222222:role/deploy"]},"Action":["ecr:BatchGetImage","ecr:GetDownloadUrlForLayer"]}]}`
+	if got != want {
+		t.Errorf("%v is not equal to %v", want, got)
+	}
+}
+
+func TestCreateRepositoryPullAccounts(t *testing.T) {
+	testFailure = ""
+
+	tests := []struct {
+		p      Config
+		policy bool
+	}{
+		{
+			p:      Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", PullAccounts: []string{"111111111111"}},
+			policy: true,
+		},
+		{
+			p: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"},
+		},
+	}
+
+	for _, test := range tests {
+		svc := &mockCreateClient{}
+
+		err := test.p.createRepository(svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (svc.policy != "") != test.policy {
+			t.Errorf("%v is not equal to %v", svc.policy != "", test.policy)
+		}
+	}
+}
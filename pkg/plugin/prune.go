@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// preview which images the lifecycle policy expires and apply it unless this is a dry run
+func (p *Config) pruneImages(svc ecriface.ECRAPI, w io.Writer) error {
+	policy, err := p.lifecyclePolicy()
+	if err != nil {
+		return err
+	}
+
+	results, err := p.previewLifecyclePolicy(svc, policy)
+	if err != nil {
+		return err
+	}
+
+	err = printPreview(w, results)
+	if err != nil {
+		return err
+	}
+
+	if p.DryRun {
+		return nil
+	}
+
+	_, err = svc.PutLifecyclePolicy(&ecr.PutLifecyclePolicyInput{
+		RepositoryName:      aws.String(p.Repository),
+		LifecyclePolicyText: aws.String(policy),
+	})
+
+	return err
+}
+
+// lifecycle policy JSON given inline or as a file path
+func (p *Config) lifecyclePolicy() (string, error) {
+	return readJSONSetting(p.LifecyclePolicy)
+}
+
+// JSON setting passed inline or as the path of a JSON file
+func readJSONSetting(value string) (string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return value, nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// images the policy would expire, from a lifecycle policy preview
+func (p *Config) previewLifecyclePolicy(svc ecriface.ECRAPI, policy string) ([]*ecr.LifecyclePolicyPreviewResult, error) {
+	_, err := svc.StartLifecyclePolicyPreview(&ecr.StartLifecyclePolicyPreviewInput{
+		RepositoryName:      aws.String(p.Repository),
+		LifecyclePolicyText: aws.String(policy),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	input := &ecr.GetLifecyclePolicyPreviewInput{RepositoryName: aws.String(p.Repository)}
+	err = svc.WaitUntilLifecyclePolicyPreviewComplete(input)
+	if err != nil {
+		return nil, fmt.Errorf("lifecycle policy preview did not complete: %w", err)
+	}
+
+	var results []*ecr.LifecyclePolicyPreviewResult
+	err = svc.GetLifecyclePolicyPreviewPages(input, func(page *ecr.GetLifecyclePolicyPreviewOutput, last bool) bool {
+		results = append(results, page.PreviewResults...)
+		return true
+	})
+
+	return results, err
+}
+
+// print the images expired by the policy as a table
+func printPreview(w io.Writer, results []*ecr.LifecyclePolicyPreviewResult) error {
+	fmt.Fprintf(w, "lifecycle policy expires %d images\n", len(results))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAGS\tDIGEST\tPUSHED AT\tRULE")
+
+	for _, result := range results {
+		tags := strings.Join(aws.StringValueSlice(result.ImageTags), ",")
+		if tags == "" {
+			tags = "<untagged>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", tags, aws.StringValue(result.ImageDigest),
+			aws.TimeValue(result.ImagePushedAt).Format(time.RFC3339), aws.Int64Value(result.AppliedRulePriority))
+	}
+
+	return tw.Flush()
+}
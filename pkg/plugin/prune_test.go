@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+const testLifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "imageCountMoreThan", "countNumber": 1}, "action": {"type": "expire"}}]}`
+
+// returns a completed lifecycle policy preview and records applied policies
+type mockLifecycleClient struct {
+	mockECRClient
+
+	previewed string
+	applied   string
+}
+
+func (m *mockLifecycleClient) StartLifecyclePolicyPreview(input *ecr.StartLifecyclePolicyPreviewInput) (*ecr.StartLifecyclePolicyPreviewOutput, error) {
+	m.previewed = aws.StringValue(input.LifecyclePolicyText)
+	return &ecr.StartLifecyclePolicyPreviewOutput{Status: aws.String(ecr.LifecyclePolicyPreviewStatusInProgress)}, nil
+}
+
+func (m *mockLifecycleClient) WaitUntilLifecyclePolicyPreviewComplete(input *ecr.GetLifecyclePolicyPreviewInput) error {
+	return nil
+}
+
+func (m *mockLifecycleClient) GetLifecyclePolicyPreviewPages(input *ecr.GetLifecyclePolicyPreviewInput, fn func(*ecr.GetLifecyclePolicyPreviewOutput, bool) bool) error {
+	image := testImage("sha256:c", 5)
+	fn(&ecr.GetLifecyclePolicyPreviewOutput{
+		Status: aws.String(ecr.LifecyclePolicyPreviewStatusComplete),
+		PreviewResults: []*ecr.LifecyclePolicyPreviewResult{
+			{
+				ImageDigest:         image.ImageDigest,
+				ImagePushedAt:       image.ImagePushedAt,
+				AppliedRulePriority: aws.Int64(1),
+			},
+		},
+	}, true)
+
+	return nil
+}
+
+func (m *mockLifecycleClient) PutLifecyclePolicy(input *ecr.PutLifecyclePolicyInput) (*ecr.PutLifecyclePolicyOutput, error) {
+	m.applied = aws.StringValue(input.LifecyclePolicyText)
+	return &ecr.PutLifecyclePolicyOutput{}, nil
+}
+
+func TestPruneImages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"policy.json": testLifecyclePolicy})
+
+	tests := []struct {
+		plugin  Config
+		applied string
+	}{
+		{
+			plugin: Config{LifecyclePolicy: testLifecyclePolicy, DryRun: true},
+		},
+		{
+			plugin:  Config{LifecyclePolicy: testLifecyclePolicy},
+			applied: testLifecyclePolicy,
+		},
+		{
+			plugin:  Config{LifecyclePolicy: filepath.Join(dir, "policy.json")},
+			applied: testLifecyclePolicy,
+		},
+	}
+
+	for _, test := range tests {
+		svc := &mockLifecycleClient{}
+		test.plugin.Repository = "test"
+
+		var buf bytes.Buffer
+		err := test.plugin.pruneImages(svc, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if svc.previewed != testLifecyclePolicy {
+			t.Errorf("%v is not equal to %v", testLifecyclePolicy, svc.previewed)
+		}
+
+		if svc.applied != test.applied {
+			t.Errorf("%v is not equal to %v", test.applied, svc.applied)
+		}
+
+		if !strings.Contains(buf.String(), "<untagged>  sha256:c  2023-01-26T00:00:00Z  1") {
+			t.Errorf("unexpected preview output: %s", buf.String())
+		}
+	}
+}
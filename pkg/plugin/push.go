@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// pushes a pre-built OCI layout or docker-save tarball to the target repository
+func (p *Config) pushImage(svc ecriface.ECRAPI) error {
+	dst, err := p.imageRef(p.Tag)
+	if err != nil {
+		return err
+	}
+
+	auth, err := p.registryAuth(svc)
+	if err != nil {
+		return err
+	}
+
+	image, err := loadImage(p.ImagePath)
+	if err != nil {
+		return err
+	}
+
+	digest, err := image.Digest()
+	if err != nil {
+		return err
+	}
+
+	err = p.writeImage(svc, dst, image, auth)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("pushed %s to %s@%s", p.ImagePath, dst.Context(), digest)
+	return nil
+}
+
+// load an image or image index from an OCI layout directory or a tarball
+func loadImage(path string) (artifact, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return tarball.ImageFromPath(path, nil)
+	}
+
+	idx, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	// layouts holding more than one manifest are pushed as an index
+	if len(manifest.Manifests) != 1 {
+		if len(manifest.Manifests) == 0 {
+			return nil, fmt.Errorf("no images found in layout: %s", filepath.Clean(path))
+		}
+		return idx, nil
+	}
+
+	desc := manifest.Manifests[0]
+	if desc.MediaType.IsIndex() {
+		return idx.ImageIndex(desc.Digest)
+	}
+
+	return idx.Image(desc.Digest)
+}
@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestPushImage(t *testing.T) {
+	host := newTestRegistry(t)
+	dir := t.TempDir()
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// docker-save tarball
+	tarPath := filepath.Join(dir, "image.tar")
+	ref, err := name.ParseReference("example.com/image:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tarball.WriteToFile(tarPath, ref, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OCI layout
+	layoutPath := filepath.Join(dir, "layout")
+	path, err := layout.Write(layoutPath, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = path.AppendImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		p Config
+	}{
+		{
+			p: Config{Registry: host, Repository: "pushed/tarball", Tag: "1.0", ImagePath: tarPath},
+		},
+		{
+			p: Config{Registry: host, Repository: "pushed/layout", Tag: "1.0", ImagePath: layoutPath},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.p.pushImage(&mockECRClient{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, err := name.ParseReference(fmt.Sprintf("%s/%s:%s", host, test.p.Repository, test.p.Tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		desc, err := remote.Head(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if desc.Digest != want {
+			t.Errorf("%v is not equal to %v", want, desc.Digest)
+		}
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := loadImage(filepath.Join(dir, "missing.tar"))
+	if err == nil {
+		t.Errorf("missing image should have failed")
+	}
+
+	// empty layouts have nothing to push
+	_, err = layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadImage(dir)
+	if err == nil {
+		t.Errorf("empty layout should have failed")
+	}
+
+	// a single index in a layout is returned as an index
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := layout.FromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = path.AppendIndex(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadImage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := got.(v1.ImageIndex); !ok {
+		t.Errorf("%T is not an image index", got)
+	}
+}
+
+func TestRunPushPullRequest(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "pull_request")
+
+	// the image does not exist, so pushing it would fail the step
+	cfg := Config{
+		Mode:       modePush,
+		ImagePath:  filepath.Join(t.TempDir(), "missing.tar"),
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+	}
+
+	err := Run(context.Background(), cfg, WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Errorf("pull requests should not push: %v", err)
+	}
+
+	// push: false is honored as well
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+	noPush := false
+	cfg.Push = &noPush
+
+	err = Run(context.Background(), cfg, WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Errorf("push: false should not push: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// push rules whose destination the plugin can set
+const (
+	pushRuleAuto      = "auto"
+	pushRuleOCI       = "oci_push"
+	pushRuleContainer = "container_push"
+	// custom pushers read the DRONE_ECR_* variables
+	pushRuleCustom = "custom"
+)
+
+// push rule of the target, detected when push_rule is auto
+func (p *Config) pushRule() string {
+	if p.PushRule == pushRuleAuto {
+		return p.detectedPushRule
+	}
+
+	return p.PushRule
+}
+
+// flags setting the destination of container_push rules using make variables,
+// e.g. repository = "$(repository)"
+func (p *Config) pushFlags() []string {
+	if p.pushRule() != pushRuleContainer {
+		return nil
+	}
+
+	return []string{
+		joinFlag("--define", "registry="+p.Registry),
+		joinFlag("--define", "repository="+p.Repository),
+		joinFlag("--define", "tag="+p.imageTag()),
+	}
+}
+
+// arguments passed to the push target, setting the destination of the pushed image
+// so that the BUILD file does not need to hardcode it
+func (p *Config) pushRunArgs() []string {
+	switch p.pushRule() {
+	case pushRuleOCI:
+		return []string{
+			joinFlag("--repository", fmt.Sprintf("%s/%s", p.Registry, p.Repository)),
+			joinFlag("--tag", p.imageTag()),
+		}
+	}
+
+	return nil
+}
+
+// push rule of a rule kind, rules are often wrapped in macros adding a suffix to the kind
+func pushRuleOfKind(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, pushRuleOCI):
+		return pushRuleOCI
+	case strings.HasPrefix(kind, pushRuleContainer):
+		return pushRuleContainer
+	}
+
+	return pushRuleCustom
+}
+
+// query the kind of the target to detect its push rule
+func (p *Config) detectPushRule(ctx context.Context, runner Runner) (string, error) {
+	r, ok := runner.(outputRunner)
+	if !ok {
+		return pushRuleCustom, nil
+	}
+
+	args := append(p.queryCommandArgs(), "--output=label_kind", p.Target)
+
+	var stdout bytes.Buffer
+	err := r.RunOutput(ctx, "bazel", args, append(os.Environ(), p.environ()...), &stdout, os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("could not detect the push rule of %s: %w", p.Target, err)
+	}
+
+	// "oci_push_rule rule //app:push"
+	kind, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), " ")
+	rule := pushRuleOfKind(kind)
+	log.Printf("detected %s push rule for %s (%s)", rule, p.Target, kind)
+
+	return rule, nil
+}
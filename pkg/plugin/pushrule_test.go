@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestPushRunArgs(t *testing.T) {
+	registry := "0123456789.dkr.ecr.us-east-1.amazonaws.com"
+
+	tests := []struct {
+		plugin Config
+		event  string
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "//:push", Registry: registry, Repository: "team/app", Tag: "v1", PushRule: pushRuleOCI},
+			event:  "push",
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push", "--", "--repository=" + registry + "/team/app", "--tag=v1"},
+		},
+		{
+			plugin: Config{Target: "//:push", Registry: registry, Repository: "team/app", PushRule: pushRuleOCI, TargetArgs: "--remote_tags=tags.txt"},
+			event:  "push",
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push", "--", "--repository=" + registry + "/team/app", "--tag=latest", "--remote_tags=tags.txt"},
+		},
+		// pull requests only build the target
+		{
+			plugin: Config{Target: "//:push", Registry: registry, Repository: "team/app", PushRule: pushRuleOCI},
+			event:  "pull_request",
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.getArgs(&buildMock{event: test.event})
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+
+	p := Config{Target: "//:push", Registry: registry, PushRule: "jib"}
+	if p.validate() == nil {
+		t.Errorf("unsupported push rule should have failed")
+	}
+}
+
+// prints the kind of the queried target
+type kindRunner struct {
+	recordingRunner
+	kind string
+}
+
+func (r *kindRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintf(stdout, "%s rule //app:push\n", r.kind)
+	return r.Run(ctx, name, args, env)
+}
+
+func TestDetectPushRule(t *testing.T) {
+	testFailure = ""
+	registry := "0123456789.dkr.ecr.us-east-1.amazonaws.com"
+
+	tests := []struct {
+		kind  string
+		event string
+		want  []string
+	}{
+		{
+			kind: "oci_push_rule",
+			want: []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//app:push", "--", "--repository=" + registry + "/team/app", "--tag=v1"},
+		},
+		{
+			kind: "container_push_",
+			want: []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--define=registry=" + registry, "--define=repository=team/app", "--define=tag=v1", "//app:push"},
+		},
+		{
+			kind: "sh_binary",
+			want: []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//app:push"},
+		},
+		// pull requests build the target, which still needs the make variables
+		{
+			kind:  "container_push_",
+			event: "pull_request",
+			want:  []string{"build", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--define=registry=" + registry, "--define=repository=team/app", "--define=tag=v1", "//app:push"},
+		},
+	}
+
+	for _, test := range tests {
+		event := test.event
+		if event == "" {
+			event = "push"
+		}
+		t.Setenv("DRONE_BUILD_EVENT", event)
+
+		cfg := Config{Target: "//app:push", Registry: registry, Repository: "team/app", Tag: "v1", PushRule: pushRuleAuto}
+		runner := &kindRunner{kind: test.kind}
+
+		err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(runner.calls) != 2 {
+			t.Fatalf("expected a query and a run, got %+v", runner.calls)
+		}
+
+		query := []string{"query", "--output=label_kind", "//app:push"}
+		if !reflect.DeepEqual(query, runner.calls[0].args) {
+			t.Errorf("%v is not equal to %v", query, runner.calls[0].args)
+		}
+		if !reflect.DeepEqual(test.want, runner.calls[1].args) {
+			t.Errorf("%v is not equal to %v", test.want, runner.calls[1].args)
+		}
+	}
+}
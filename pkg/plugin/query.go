@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+)
+
+// default output format of query mode
+const defaultQueryOutput = "graph"
+
+// file extensions of the query output formats
+var queryExtensions = map[string]string{
+	"graph":              "dot",
+	"proto":              "pb",
+	"streamed_proto":     "pb",
+	"streamed_jsonproto": "json",
+	"jsonproto":          "json",
+	"xml":                "xml",
+}
+
+// query expression, the dependencies of the target by default
+func (p *Config) queryExpression() string {
+	if p.QueryExpression != "" {
+		return p.QueryExpression
+	}
+
+	return fmt.Sprintf("deps(%s)", p.Target)
+}
+
+func (p *Config) queryOutput() string {
+	if p.QueryOutput != "" {
+		return p.QueryOutput
+	}
+
+	return defaultQueryOutput
+}
+
+// file the query result is written to, named after the output format by default
+func (p *Config) queryFile() string {
+	if p.QueryFile != "" {
+		return p.QueryFile
+	}
+
+	ext, ok := queryExtensions[p.queryOutput()]
+	if !ok {
+		ext = "txt"
+	}
+
+	return "query." + ext
+}
+
+func (p *Config) queryArgs() []string {
+	return append(p.queryCommandArgs(),
+		joinFlag("--output", p.queryOutput()),
+		joinFlag("--output_file", p.queryFile()),
+		p.queryExpression(),
+	)
+}
+
+// run the query and upload its result to artifacts_target when set
+func (p *Config) query(ctx context.Context, runner Runner) error {
+	err := runner.Run(ctx, "bazel", p.queryArgs(), append(os.Environ(), p.environ()...))
+	if err != nil {
+		return err
+	}
+	log.Printf("wrote the result of %s to %s", p.queryExpression(), p.queryFile())
+
+	if p.ArtifactsTarget == "" {
+		return nil
+	}
+
+	uploader, err := p.s3Uploader()
+	if err != nil {
+		return err
+	}
+
+	return p.uploadQuery(uploader)
+}
+
+func (p *Config) uploadQuery(svc s3manageriface.UploaderAPI) error {
+	bucket, prefix, err := parseS3URL(p.ArtifactsTarget)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(prefix, filepath.Base(p.queryFile()))
+	err = uploadFile(svc, bucket, key, p.queryFile())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("uploaded %s to s3://%s/%s", p.queryFile(), bucket, key)
+	return nil
+}
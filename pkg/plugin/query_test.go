@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQueryArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "//app:image"},
+			want:   []string{"query", "--output=graph", "--output_file=query.dot", "deps(//app:image)"},
+		},
+		{
+			plugin: Config{Target: "//app:image", Bazelrc: ".bazelrc.ci", QueryExpression: "rdeps(//..., //lib:api)", QueryOutput: "proto"},
+			want:   []string{"--bazelrc=.bazelrc.ci", "query", "--output=proto", "--output_file=query.pb", "rdeps(//..., //lib:api)"},
+		},
+		{
+			plugin: Config{Target: "//app:image", QueryOutput: "label", QueryFile: "out/deps.txt"},
+			want:   []string{"query", "--output=label", "--output_file=out/deps.txt", "deps(//app:image)"},
+		},
+		// external repositories are resolved like the build does
+		{
+			plugin: Config{Target: "//app:image", Offline: true, RepositoryCache: "/cache/repos", downloaderConfigFile: "/tmp/downloader.cfg"},
+			want:   []string{"query", "--nofetch", "--repository_cache=/cache/repos", "--experimental_downloader_config=/tmp/downloader.cfg", "--output=graph", "--output_file=query.dot", "deps(//app:image)"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.queryArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Mode:       modeQuery,
+		Target:     "//app:image",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+		QueryFile:  filepath.Join(t.TempDir(), "deps.dot"),
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.calls) != 1 || runner.calls[0].args[0] != "query" {
+		t.Errorf("unexpected commands: %+v", runner.calls)
+	}
+
+	cfg = Config{Mode: modeQuery, Registry: cfg.Registry, Repository: "repository"}
+	if cfg.validate() == nil {
+		t.Errorf("query without an expression or target should have failed")
+	}
+}
+
+func TestUploadQuery(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "deps.dot")
+	err := os.WriteFile(file, []byte("digraph mygraph {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Config{QueryFile: file, ArtifactsTarget: "s3://bucket/graphs/app"}
+	svc := &mockUploader{objects: map[string]string{}}
+
+	err = p.uploadQuery(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"bucket/graphs/app/deps.dot": "digraph mygraph {}\n"}
+	if !reflect.DeepEqual(want, svc.objects) {
+		t.Errorf("%v is not equal to %v", want, svc.objects)
+	}
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// service quota code and default of the number of images per repository
+	imagesQuotaCode    = "L-03A36CE1"
+	defaultImagesQuota = 10000
+
+	// maximum size of a layer accepted by ECR
+	maxLayerSize = 52000 << 20
+)
+
+// check before pushing that the repository has room for another image and that the layers
+// of a pushed image are within the ECR layer size limit
+func (p *Config) checkQuotas(svc ecriface.ECRAPI, quotas servicequotasiface.ServiceQuotasAPI) error {
+	limit := imagesQuota(quotas)
+
+	images := map[string]bool{}
+	input := &ecr.ListImagesInput{RepositoryName: aws.String(p.Repository), RegistryId: aws.String(p.registryID())}
+	err := svc.ListImagesPages(input, func(page *ecr.ListImagesOutput, last bool) bool {
+		for _, id := range page.ImageIds {
+			images[aws.StringValue(id.ImageDigest)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(images) >= limit {
+		return fmt.Errorf("repository %s holds %d images, the quota is %d images per repository: "+
+			"delete old images with the gc or prune modes or request a quota increase before pushing", p.Repository, len(images), limit)
+	}
+	log.Printf("repository %s holds %d of %d images", p.Repository, len(images), limit)
+
+	// layer sizes are only known before pushing for pre-built images
+	if p.Mode != modePush {
+		return nil
+	}
+
+	image, err := loadImage(p.ImagePath)
+	if err != nil {
+		return err
+	}
+
+	sizes, err := layerSizes(image)
+	if err != nil {
+		return err
+	}
+
+	for digest, size := range sizes {
+		if size > maxLayerSize {
+			return fmt.Errorf("layer %s of %s is %d bytes, exceeding the ECR limit of %d bytes per layer: split the layer into smaller layers", digest, p.ImagePath, size, int64(maxLayerSize))
+		}
+	}
+
+	return nil
+}
+
+// quota of images per repository, the default quota when it can not be looked up
+func imagesQuota(quotas servicequotasiface.ServiceQuotasAPI) int {
+	result, err := quotas.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String("ecr"),
+		QuotaCode:   aws.String(imagesQuotaCode),
+	})
+	if err != nil || result.Quota == nil || result.Quota.Value == nil {
+		if err != nil {
+			log.Printf("could not look up the images per repository quota, assuming %d: %s", defaultImagesQuota, err)
+		}
+		return defaultImagesQuota
+	}
+
+	return int(aws.Float64Value(result.Quota.Value))
+}
+
+// compressed sizes of the layers of an image or of every image of an index, by digest
+func layerSizes(image artifact) (map[string]int64, error) {
+	sizes := map[string]int64{}
+
+	switch image := image.(type) {
+	case v1.ImageIndex:
+		manifest, err := image.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, desc := range manifest.Manifests {
+			var child artifact
+			if desc.MediaType.IsIndex() {
+				child, err = image.ImageIndex(desc.Digest)
+			} else {
+				child, err = image.Image(desc.Digest)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			childSizes, err := layerSizes(child)
+			if err != nil {
+				return nil, err
+			}
+			for digest, size := range childSizes {
+				sizes[digest] = size
+			}
+		}
+	case v1.Image:
+		manifest, err := image.Manifest()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, layer := range manifest.Layers {
+			sizes[layer.Digest.String()] = layer.Size
+		}
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// returns the quota, or fails when it is zero
+type mockQuotasClient struct {
+	servicequotasiface.ServiceQuotasAPI
+
+	quota float64
+}
+
+func (m *mockQuotasClient) GetServiceQuota(input *servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error) {
+	if m.quota == 0 {
+		return nil, errors.New("AccessDenied")
+	}
+
+	return &servicequotas.GetServiceQuotaOutput{Quota: &servicequotas.ServiceQuota{Value: aws.Float64(m.quota)}}, nil
+}
+
+// lists the given number of images
+type mockCountClient struct {
+	mockECRClient
+
+	images int
+}
+
+func (m *mockCountClient) ListImagesPages(input *ecr.ListImagesInput, fn func(*ecr.ListImagesOutput, bool) bool) error {
+	var ids []*ecr.ImageIdentifier
+	for i := 0; i < m.images; i++ {
+		ids = append(ids, &ecr.ImageIdentifier{ImageDigest: aws.String(fmt.Sprintf("sha256:%d", i))})
+	}
+
+	fn(&ecr.ListImagesOutput{ImageIds: ids}, true)
+	return nil
+}
+
+func TestCheckQuotas(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imagePath := filepath.Join(t.TempDir(), "image.tar")
+	err = tarball.WriteToFile(imagePath, nil, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		images int
+		quota  float64
+		mode   string
+		err    string
+	}{
+		{images: 10, quota: 20},
+		{images: 20, quota: 20, err: "repository test holds 20 images, the quota is 20 images per repository"},
+		// the default quota is assumed when it can not be looked up
+		{images: 9999},
+		{images: 10000, err: "the quota is 10000 images per repository"},
+		{images: 10, quota: 20, mode: modePush},
+	}
+
+	for _, test := range tests {
+		p := Config{Repository: "test", Mode: test.mode, ImagePath: imagePath}
+
+		err := p.checkQuotas(&mockCountClient{images: test.images}, &mockQuotasClient{quota: test.quota})
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v does not contain %q", err, test.err)
+		}
+	}
+}
+
+func TestRunCheckQuotas(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:      "//:push",
+		Registry:    "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:  "repository",
+		CheckQuotas: true,
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockCountClient{images: 2}), WithServiceQuotasClient(&mockQuotasClient{quota: 2}))
+	if err == nil || !strings.Contains(err.Error(), "the quota is 2 images") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(runner.calls) != 0 {
+		t.Errorf("bazel should not run when the repository is full")
+	}
+}
+
+func TestLayerSizes(t *testing.T) {
+	index, err := random.Index(512, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := layerSizes(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sizes) != 4 {
+		t.Errorf("4 is not equal to %d", len(sizes))
+	}
+}
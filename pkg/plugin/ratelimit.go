@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+)
+
+// bytes of bazel output kept to explain failures
+const outputTailSize = 64 << 10
+
+// backoff before the first retry of a rate limited build when rate_limit_backoff is not set
+const defaultRateLimitBackoff = 30 * time.Second
+
+// writer keeping the last bytes written to it
+type tailBuffer struct {
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > outputTailSize {
+		t.buf = t.buf[len(t.buf)-outputTailSize:]
+	}
+
+	return len(p), nil
+}
+
+func (t *tailBuffer) Bytes() []byte {
+	if t == nil {
+		return nil
+	}
+
+	return t.buf
+}
+
+// hosts of Docker Hub in image fetch errors
+var dockerHubHosts = [][]byte{
+	[]byte("docker.io"),
+	[]byte("registry-1.docker.io"),
+	[]byte("index.docker.io"),
+}
+
+// whether bazel output shows a Docker Hub pull rate limit, e.g.
+// "GET https://index.docker.io/v2/library/debian/manifests/12: toomanyrequests: You have reached your pull rate limit"
+func dockerHubRateLimited(output []byte) bool {
+	if !bytes.Contains(output, []byte("toomanyrequests")) && !bytes.Contains(output, []byte("429 Too Many Requests")) {
+		return false
+	}
+
+	for _, host := range dockerHubHosts {
+		if bytes.Contains(output, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// guidance on avoiding the Docker Hub pull rate limit, logged on the first rate limited run
+const rateLimitGuidance = `
+Docker Hub rejected a base image pull with its pull rate limit (429 toomanyrequests).
+Anonymous pulls are limited per IP address, and runners behind a NAT share the limit.
+To avoid it:
+  - create an ECR pull through cache rule for Docker Hub and pull base images through it, e.g.
+      aws ecr create-pull-through-cache-rule --ecr-repository-prefix docker-hub --upstream-registry-url registry-1.docker.io --credential-arn <secret arn>
+    and reference <account>.dkr.ecr.<region>.amazonaws.com/docker-hub/library/<image> in oci_pull
+  - or mirror the base images into a registry of your own
+  - or authenticate the pulls with a Docker Hub account in the docker config read by rules_oci
+Set rate_limit_retries to retry the build with a backoff.
+
+`
+
+// backoff before a retry of a rate limited build, doubling with each retry
+func (p *Config) rateLimitBackoff(retry int) time.Duration {
+	backoff := p.RateLimitBackoff
+	if backoff == 0 {
+		backoff = defaultRateLimitBackoff
+	}
+
+	return backoff << retry
+}
+
+// wait before retrying a rate limited build, false when no retries are left
+func (p *Config) waitRateLimit(ctx context.Context, retry int) bool {
+	if retry >= p.RateLimitRetries {
+		return false
+	}
+
+	backoff := p.rateLimitBackoff(retry)
+	log.Printf("rate limited by Docker Hub, retrying in %s (%d/%d)", backoff, retry+1, p.RateLimitRetries)
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
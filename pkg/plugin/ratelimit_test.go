@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{}
+	fmt.Fprint(tail, strings.Repeat("a", outputTailSize))
+	fmt.Fprint(tail, "end")
+
+	if got := tail.Bytes(); len(got) != outputTailSize || !bytes.HasSuffix(got, []byte("aend")) {
+		t.Errorf("got %d bytes ending in %q", len(got), got[len(got)-4:])
+	}
+
+	var empty *tailBuffer
+	if empty.Bytes() != nil {
+		t.Error("nil buffer is not empty")
+	}
+}
+
+func TestDockerHubRateLimited(t *testing.T) {
+	tests := []struct {
+		output  string
+		limited bool
+	}{
+		{output: "ERROR: build failed"},
+		{output: "GET https://index.docker.io/v2/library/debian/manifests/12: toomanyrequests: You have reached your pull rate limit.", limited: true},
+		{output: "registry-1.docker.io returned 429 Too Many Requests", limited: true},
+		// other registries have their own limits
+		{output: "GET https://ghcr.io/v2/org/image/manifests/1: toomanyrequests"},
+	}
+
+	for _, test := range tests {
+		if got := dockerHubRateLimited([]byte(test.output)); got != test.limited {
+			t.Errorf("%q: got %t, want %t", test.output, got, test.limited)
+		}
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	p := Config{}
+	if got := p.rateLimitBackoff(1); got != 2*defaultRateLimitBackoff {
+		t.Errorf("got %s, want %s", got, 2*defaultRateLimitBackoff)
+	}
+
+	p.RateLimitBackoff = time.Second
+	if got := p.rateLimitBackoff(2); got != 4*time.Second {
+		t.Errorf("got %s, want 4s", got)
+	}
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	p := Config{RateLimitRetries: 1, RateLimitBackoff: time.Hour}
+	if p.waitRateLimit(context.Background(), 1) {
+		t.Errorf("no retries should be left")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p.waitRateLimit(ctx, 0) {
+		t.Errorf("cancelled wait should not retry")
+	}
+}
+
+// fails with a Docker Hub rate limit
+type rateLimitedRunner struct {
+	recordingRunner
+}
+
+func (r *rateLimitedRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stderr, "ERROR: GET https://index.docker.io/v2/library/debian/manifests/12: toomanyrequests")
+	return r.Run(ctx, name, args, env)
+}
+
+func TestRunRateLimited(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:           "//:push",
+		Registry:         "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:       "repository",
+		RateLimitRetries: 2,
+		RateLimitBackoff: time.Millisecond,
+	}
+	runner := &rateLimitedRunner{recordingRunner{err: errors.New("exit status 1")}}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err == nil || !strings.Contains(err.Error(), "rate limited by Docker Hub") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(runner.calls) != 3 {
+		t.Errorf("got %d calls, want 3", len(runner.calls))
+	}
+}
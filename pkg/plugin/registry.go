@@ -0,0 +1,258 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// image or image index that can be written to a registry
+type artifact interface {
+	Digest() (v1.Hash, error)
+	MediaType() (types.MediaType, error)
+	RawManifest() ([]byte, error)
+}
+
+// get registry credentials for the target registry from an ECR auth token
+func (p *Config) registryAuth(svc ecriface.ECRAPI) (authn.Authenticator, error) {
+	result, err := svc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("no authorization data returned for registry: %s", p.Registry)
+	}
+
+	// the token is a base64 encoded "user:password" pair
+	token, err := base64.StdEncoding.DecodeString(aws.StringValue(result.AuthorizationData[0].AuthorizationToken))
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return nil, fmt.Errorf("could not parse authorization token for registry: %s", p.Registry)
+	}
+
+	return authn.FromConfig(authn.AuthConfig{Username: username, Password: password}), nil
+}
+
+// reference to the image in the target repository
+func (p *Config) imageRef(tag string) (name.Reference, error) {
+	if p.Repository == "" {
+		return nil, fmt.Errorf("must specify a repository")
+	}
+
+	repo := fmt.Sprintf("%s/%s", p.Registry, p.Repository)
+
+	// digests are passed through so images can be referenced without a tag
+	if strings.HasPrefix(tag, "sha256:") {
+		return name.NewDigest(fmt.Sprintf("%s@%s", repo, tag))
+	}
+
+	if tag == "" {
+		tag = "latest"
+	}
+
+	return name.NewTag(fmt.Sprintf("%s:%s", repo, tag))
+}
+
+// prepend the repository prefix to the repository and matrix entry repositories
+func (p *Config) applyRepositoryPrefix() {
+	p.Repository = p.prefixRepository(p.Repository)
+
+	entries := make(matrix, len(p.Matrix))
+	for i, entry := range p.Matrix {
+		entry.Repository = p.prefixRepository(entry.Repository)
+		entries[i] = entry
+	}
+	p.Matrix = entries
+}
+
+// prefix a repository name with the namespace of repository_prefix unless it is
+// already in the namespace, comparing whole path components
+func (p *Config) prefixRepository(repo string) string {
+	prefix := strings.Trim(p.RepositoryPrefix, "/")
+	if repo == "" || prefix == "" || strings.HasPrefix(repo, prefix+"/") {
+		return repo
+	}
+
+	return prefix + "/" + repo
+}
+
+// characters that are not allowed in ECR repository names
+var invalidRepositoryChars = regexp.MustCompile(`[^a-z0-9._/-]+`)
+
+// runs of separators, ECR only allows a single '.', '_' or '-' between letters and digits
+var repositorySeparatorRuns = regexp.MustCompile(`[._-]{2,}`)
+
+// maximum length of ECR repository names
+const maxRepositoryLength = 256
+
+// normalize a drone repository slug into an ECR repository name
+func defaultRepository(repo string) string {
+	if repo == "" {
+		return ""
+	}
+
+	name := invalidRepositoryChars.ReplaceAllString(strings.ToLower(repo), "-")
+	name = repositorySeparatorRuns.ReplaceAllString(name, "-")
+
+	// each path component must start and end with a letter or digit
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		part = strings.Trim(part, "._-")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	name = strings.Join(parts, "/")
+
+	if len(name) > maxRepositoryLength {
+		name = strings.TrimRight(name[:maxRepositoryLength], "._/-")
+	}
+
+	return name
+}
+
+// tag of the pushed image
+func (p *Config) imageTag() string {
+	if p.Tag == "" {
+		return "latest"
+	}
+
+	return p.Tag
+}
+
+// look up the digest of the pushed image in the target repository
+func (p *Config) imageDigest(svc ecriface.ECRAPI) (string, error) {
+	return p.tagDigest(svc, p.imageTag())
+}
+
+// look up the digest a tag points at in the target repository
+func (p *Config) tagDigest(svc ecriface.ECRAPI, tag string) (string, error) {
+	detail, err := p.describeImage(svc, tag)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(detail.ImageDigest), nil
+}
+
+// look up the details of a tagged image in the target repository
+func (p *Config) describeImage(svc ecriface.ECRAPI, tag string) (*ecr.ImageDetail, error) {
+	result, err := svc.DescribeImages(&ecr.DescribeImagesInput{
+		RepositoryName: aws.String(p.Repository),
+		ImageIds:       []*ecr.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.ImageDetails) == 0 {
+		return nil, fmt.Errorf("image not found: %s/%s:%s", p.Registry, p.Repository, tag)
+	}
+
+	return result.ImageDetails[0], nil
+}
+
+// how long to wait between checks for the pushed image
+var imagePollInterval = 2 * time.Second
+
+// wait until the pushed tag is visible in the repository, for up to image_wait_timeout
+func (p *Config) waitForImage(svc ecriface.ECRAPI) error {
+	timeout := p.ImageWaitTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		_, err := p.imageDigest(svc)
+		if err == nil {
+			return nil
+		}
+
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != ecr.ErrCodeImageNotFoundException {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("image %s:%s was not visible after %s", p.Repository, p.imageTag(), timeout)
+		}
+
+		time.Sleep(imagePollInterval)
+	}
+}
+
+// whether a failed push can be ignored because the tag already points at the digest,
+// which is the case when re-running a build against a repository with immutable tags
+func (p *Config) alreadyTagged(svc ecriface.ECRAPI, tag, digest string) bool {
+	existing, err := p.tagDigest(svc, tag)
+	if err != nil || existing != digest {
+		return false
+	}
+
+	log.Printf("%s:%s already points at %s", p.Repository, tag, digest)
+	return true
+}
+
+// write an image or image index to the target repository
+func (p *Config) writeImage(svc ecriface.ECRAPI, dst name.Reference, image artifact, auth authn.Authenticator) error {
+	// use the ECR layer upload API when upload tuning is configured
+	if p.UploadJobs > 0 || p.UploadChunkSize != "" {
+		var chunkSize int64
+		if p.UploadChunkSize != "" {
+			var err error
+			chunkSize, err = parseSize(p.UploadChunkSize)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := newUploader(svc, p.Repository, p.UploadJobs, chunkSize).write(dst, image)
+		return p.ignoreRepush(svc, dst, image, err)
+	}
+
+	var err error
+	switch image := image.(type) {
+	case v1.ImageIndex:
+		err = remote.WriteIndex(dst, image, remote.WithAuth(auth))
+	case v1.Image:
+		err = remote.Write(dst, image, remote.WithAuth(auth))
+	default:
+		return fmt.Errorf("unsupported image type: %T", image)
+	}
+
+	return p.ignoreRepush(svc, dst, image, err)
+}
+
+// treat a failed push of a tag that already points at the image as a no-op
+func (p *Config) ignoreRepush(svc ecriface.ECRAPI, dst name.Reference, image artifact, err error) error {
+	tag, ok := dst.(name.Tag)
+	if err == nil || !ok {
+		return err
+	}
+
+	digest, digestErr := image.Digest()
+	if digestErr == nil && p.alreadyTagged(svc, tag.TagStr(), digest.String()) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// flags limiting the outputs downloaded from the remote cache
+func (p *Config) remoteDownloadArgs(command string) []string {
+	if !p.BuildWithoutTheBytes {
+		return nil
+	}
+
+	args := []string{"--remote_download_minimal"}
+
+	// the push target reads the image layers, so they are still downloaded
+	if command == "run" {
+		args = append(args, joinFlag("--remote_download_regex", p.remoteDownloadRegex()))
+	}
+
+	return args
+}
+
+// flags overlapping remote cache uploads with the build and compressing them
+func (p *Config) remoteCacheArgs() []string {
+	var args []string
+
+	if p.RemoteCacheAsync != nil {
+		args = append(args, boolFlag("remote_cache_async", *p.RemoteCacheAsync))
+	}
+	if p.RemoteCacheCompress != nil {
+		args = append(args, boolFlag("experimental_remote_cache_compression", *p.RemoteCacheCompress))
+	}
+
+	return args
+}
+
+// whether remote cache uploads are explicitly asynchronous
+func (p *Config) remoteCacheAsync() bool {
+	return p.RemoteCacheAsync != nil && *p.RemoteCacheAsync
+}
+
+// outputs downloaded for the push target, everything in its package by default
+func (p *Config) remoteDownloadRegex() string {
+	if p.RemoteDownloadRegex != "" {
+		return p.RemoteDownloadRegex
+	}
+
+	pkg := targetPackage(p.Target)
+	if pkg == "" {
+		return ".*"
+	}
+
+	return ".*/" + regexp.QuoteMeta(pkg) + "/.*"
+}
+
+// package of a target label, e.g. app/api for @repo//app/api:push
+func targetPackage(target string) string {
+	_, label, ok := strings.Cut(target, "//")
+	if !ok {
+		label = target
+	}
+
+	pkg, _, _ := strings.Cut(label, ":")
+	return pkg
+}
+
+// name of a target label, e.g. push for //app:push and app for //app
+func targetName(target string) string {
+	if _, name, ok := strings.Cut(target, ":"); ok {
+		return name
+	}
+
+	return path.Base(targetPackage(target))
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemoteDownloadArgs(t *testing.T) {
+	tests := []struct {
+		plugin  Config
+		command string
+		want    []string
+	}{
+		{
+			plugin:  Config{Target: "//app/api:push"},
+			command: "run",
+		},
+		{
+			plugin:  Config{Target: "//app/api:push", BuildWithoutTheBytes: true},
+			command: "build",
+			want:    []string{"--remote_download_minimal"},
+		},
+		{
+			plugin:  Config{Target: "//app/api:push", BuildWithoutTheBytes: true},
+			command: "run",
+			want:    []string{"--remote_download_minimal", `--remote_download_regex=.*/app/api/.*`},
+		},
+		{
+			plugin:  Config{Target: "@images//base.image:push", BuildWithoutTheBytes: true},
+			command: "run",
+			want:    []string{"--remote_download_minimal", `--remote_download_regex=.*/base\.image/.*`},
+		},
+		{
+			plugin:  Config{Target: "//:push", BuildWithoutTheBytes: true},
+			command: "run",
+			want:    []string{"--remote_download_minimal", "--remote_download_regex=.*"},
+		},
+		{
+			plugin:  Config{Target: "//app/api:push", BuildWithoutTheBytes: true, RemoteDownloadRegex: ".*\\.tar$"},
+			command: "run",
+			want:    []string{"--remote_download_minimal", `--remote_download_regex=.*\.tar$`},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.remoteDownloadArgs(test.command)
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestRemoteCacheArgs(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{plugin: Config{}},
+		{
+			plugin: Config{RemoteCacheAsync: &yes, RemoteCacheCompress: &yes},
+			want:   []string{"--remote_cache_async", "--experimental_remote_cache_compression"},
+		},
+		{
+			plugin: Config{RemoteCacheAsync: &no},
+			want:   []string{"--noremote_cache_async"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.remoteCacheArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
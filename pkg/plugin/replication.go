@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// destination of a replicated image
+type replicationDestination struct {
+	region   string
+	registry string
+}
+
+// destination in a region and registry, which defaults to the registry of the repository
+func (p *Config) replicationDestination(region, registry *string) replicationDestination {
+	d := replicationDestination{region: aws.StringValue(region), registry: aws.StringValue(registry)}
+	if d.registry == "" {
+		d.registry = p.registryID()
+	}
+
+	return d
+}
+
+// name of the destination in logs, the registry is only named for other accounts
+func (p *Config) destinationName(d replicationDestination) string {
+	if d.registry == p.registryID() {
+		return d.region
+	}
+
+	return fmt.Sprintf("%s (%s)", d.region, d.registry)
+}
+
+// destinations of the replication rules of the registry that apply to the repository,
+// and the regions listed in replication_regions
+func (p *Config) replicationDestinations(svc ecriface.ECRAPI) (map[replicationDestination]bool, error) {
+	result, err := svc.DescribeRegistry(&ecr.DescribeRegistryInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := map[replicationDestination]bool{}
+	if result.ReplicationConfiguration != nil {
+		for _, rule := range result.ReplicationConfiguration.Rules {
+			if !replicatesRepository(rule, p.Repository) {
+				continue
+			}
+
+			for _, dst := range rule.Destinations {
+				destinations[p.replicationDestination(dst.Region, dst.RegistryId)] = true
+			}
+		}
+	}
+
+	for _, region := range p.ReplicationRegions {
+		destinations[p.replicationDestination(aws.String(strings.TrimSpace(region)), nil)] = true
+	}
+
+	return destinations, nil
+}
+
+// whether a replication rule applies to the repository, rules without filters apply to all of them
+func replicatesRepository(rule *ecr.ReplicationRule, repository string) bool {
+	if len(rule.RepositoryFilters) == 0 {
+		return true
+	}
+
+	for _, filter := range rule.RepositoryFilters {
+		if aws.StringValue(filter.FilterType) == ecr.RepositoryFilterTypePrefixMatch && strings.HasPrefix(repository, aws.StringValue(filter.Filter)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wait until the pushed image is replicated to every destination, for up to replication_timeout
+func (p *Config) waitForReplication(svc ecriface.ECRAPI, digest string) error {
+	timeout := p.ReplicationTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+
+	destinations, err := p.replicationDestinations(svc)
+	if err != nil {
+		return err
+	}
+
+	if len(destinations) == 0 {
+		log.Printf("%s@%s is not replicated", p.Repository, digest)
+		return nil
+	}
+
+	for {
+		result, err := svc.DescribeImageReplicationStatus(&ecr.DescribeImageReplicationStatusInput{
+			RepositoryName: aws.String(p.Repository),
+			RegistryId:     aws.String(p.registryID()),
+			ImageId:        &ecr.ImageIdentifier{ImageDigest: aws.String(digest)},
+		})
+		if err != nil {
+			return err
+		}
+
+		// destinations are waited for until their replication starts and completes
+		pending := map[replicationDestination]bool{}
+		for d := range destinations {
+			pending[d] = true
+		}
+
+		for _, status := range result.ReplicationStatuses {
+			d := p.replicationDestination(status.Region, status.RegistryId)
+
+			switch aws.StringValue(status.Status) {
+			case ecr.ReplicationStatusComplete:
+				delete(pending, d)
+			case ecr.ReplicationStatusFailed:
+				return fmt.Errorf("replication of %s@%s to %s failed: %s", p.Repository, digest, p.destinationName(d), aws.StringValue(status.FailureCode))
+			}
+		}
+
+		if len(pending) == 0 {
+			log.Printf("replicated %s@%s to %s", p.Repository, digest, p.destinationNames(destinations))
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("replication of %s@%s to %s did not complete after %s", p.Repository, digest, p.destinationNames(pending), timeout)
+		}
+
+		time.Sleep(imagePollInterval)
+	}
+}
+
+// sorted names of the destinations
+func (p *Config) destinationNames(destinations map[replicationDestination]bool) string {
+	var names []string
+	for d := range destinations {
+		names = append(names, p.destinationName(d))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// replicates to the destination regions, reporting the replication statuses of each poll in
+// order and repeating the last
+type mockReplicationClient struct {
+	mockECRClient
+
+	regions []string
+	filter  string
+	polls   [][]*ecr.ImageReplicationStatus
+	calls   int
+}
+
+func (m *mockReplicationClient) DescribeRegistry(input *ecr.DescribeRegistryInput) (*ecr.DescribeRegistryOutput, error) {
+	rule := &ecr.ReplicationRule{}
+	for _, region := range m.regions {
+		rule.Destinations = append(rule.Destinations, &ecr.ReplicationDestination{Region: aws.String(region)})
+	}
+	if m.filter != "" {
+		rule.RepositoryFilters = []*ecr.RepositoryFilter{{Filter: aws.String(m.filter), FilterType: aws.String(ecr.RepositoryFilterTypePrefixMatch)}}
+	}
+
+	return &ecr.DescribeRegistryOutput{ReplicationConfiguration: &ecr.ReplicationConfiguration{Rules: []*ecr.ReplicationRule{rule}}}, nil
+}
+
+func (m *mockReplicationClient) DescribeImageReplicationStatus(input *ecr.DescribeImageReplicationStatusInput) (*ecr.DescribeImageReplicationStatusOutput, error) {
+	statuses := m.polls[len(m.polls)-1]
+	if m.calls < len(m.polls) {
+		statuses = m.polls[m.calls]
+	}
+	m.calls++
+
+	return &ecr.DescribeImageReplicationStatusOutput{ReplicationStatuses: statuses}, nil
+}
+
+func replicationStatus(region, status string) *ecr.ImageReplicationStatus {
+	return &ecr.ImageReplicationStatus{Region: aws.String(region), Status: aws.String(status), FailureCode: aws.String("ACCESS_DENIED")}
+}
+
+func TestWaitForReplication(t *testing.T) {
+	imagePollInterval = time.Millisecond
+	defer func() { imagePollInterval = 2 * time.Second }()
+
+	destinations := []string{"us-west-2", "eu-west-1"}
+	complete := []*ecr.ImageReplicationStatus{
+		replicationStatus("us-west-2", ecr.ReplicationStatusComplete),
+		replicationStatus("eu-west-1", ecr.ReplicationStatusComplete),
+	}
+
+	tests := []struct {
+		destinations []string
+		filter       string
+		regions      []string
+		polls        [][]*ecr.ImageReplicationStatus
+		timeout      time.Duration
+		calls        int
+		err          bool
+	}{
+		// no replication rules
+		{polls: [][]*ecr.ImageReplicationStatus{{}}},
+		// the rule does not apply to the repository
+		{destinations: destinations, filter: "other/", polls: [][]*ecr.ImageReplicationStatus{{}}},
+		{
+			destinations: destinations,
+			filter:       "te",
+			polls: [][]*ecr.ImageReplicationStatus{
+				{replicationStatus("us-west-2", ecr.ReplicationStatusInProgress), replicationStatus("eu-west-1", ecr.ReplicationStatusComplete)},
+				complete,
+			},
+			calls: 2,
+		},
+		// destinations are waited for before their replication starts
+		{destinations: destinations, polls: [][]*ecr.ImageReplicationStatus{{}, complete}, calls: 2},
+		{regions: []string{"us-west-2", "eu-west-1"}, polls: [][]*ecr.ImageReplicationStatus{{}, complete}, calls: 2},
+		{destinations: destinations, polls: [][]*ecr.ImageReplicationStatus{{replicationStatus("us-west-2", ecr.ReplicationStatusFailed)}}, calls: 1, err: true},
+		{destinations: destinations, polls: [][]*ecr.ImageReplicationStatus{{replicationStatus("us-west-2", ecr.ReplicationStatusInProgress)}}, timeout: time.Nanosecond, calls: 1, err: true},
+	}
+
+	for i, test := range tests {
+		p := Config{Repository: "test", ReplicationRegions: test.regions, ReplicationTimeout: test.timeout}
+		svc := &mockReplicationClient{regions: test.destinations, filter: test.filter, polls: test.polls}
+
+		err := p.waitForReplication(svc, testDigest)
+		if (err != nil) != test.err {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+
+		if svc.calls != test.calls {
+			t.Errorf("%d: %d is not equal to %d", i, test.calls, svc.calls)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// root of the cgroup filesystem of the container
+var cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup v1 reports no memory limit as a page aligned maximum int64
+const unlimitedMemory = 1 << 62
+
+// CPU and memory limits of the container, zero when unlimited
+type resourceLimits struct {
+	cpus   float64
+	memory int64
+}
+
+// read the limits of cgroup v2, or of cgroup v1 when v2 is not mounted
+func readCgroupLimits(root string) resourceLimits {
+	var limits resourceLimits
+
+	if data, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		// "max 100000" or "<quota> <period>"
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			limits.cpus = cpuQuota(fields[0], fields[1])
+		}
+	} else {
+		limits.cpus = cpuQuota(readCgroupFile(root, "cpu/cpu.cfs_quota_us"), readCgroupFile(root, "cpu/cpu.cfs_period_us"))
+	}
+
+	memory := readCgroupFile(root, "memory.max")
+	if memory == "" {
+		memory = readCgroupFile(root, "memory/memory.limit_in_bytes")
+	}
+	if bytes, err := strconv.ParseInt(memory, 10, 64); err == nil && bytes > 0 && bytes < unlimitedMemory {
+		limits.memory = bytes
+	}
+
+	return limits
+}
+
+func readCgroupFile(root, name string) string {
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// CPUs allowed by a CFS quota and period, a negative quota is unlimited
+func cpuQuota(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+
+	d, err := strconv.ParseFloat(period, 64)
+	if err != nil || d <= 0 {
+		return 0
+	}
+
+	return q / d
+}
+
+// detect the container limits unless resource_defaults is false
+func (p *Config) detectResources() {
+	if p.ResourceDefaults != nil && !*p.ResourceDefaults {
+		return
+	}
+
+	p.limits = readCgroupLimits(cgroupRoot)
+	if p.limits.cpus > 0 || p.limits.memory > 0 {
+		log.Printf("deriving bazel resource defaults from cgroup limits of %.2f CPUs and %d MiB memory", p.limits.cpus, p.limits.memory>>20)
+	}
+}
+
+// bazel sees the CPUs and memory of the host instead of the container limits,
+// so the jobs and memory of local actions default to the limits unless overridden
+func (p *Config) resourceArgs() []string {
+	var args []string
+
+	jobs := p.Jobs
+	if jobs == "" && p.limits.cpus > 0 {
+		jobs = strconv.Itoa(int(math.Ceil(p.limits.cpus)))
+	}
+	if jobs != "" {
+		args = append(args, joinFlag("--jobs", jobs))
+	}
+
+	// half of the memory is left to the server and the workers
+	ram := p.LocalRamResources
+	if ram == "" && p.limits.memory > 0 {
+		ram = strconv.FormatInt(p.limits.memory>>20/2, 10)
+	}
+	if ram != "" {
+		args = append(args, joinFlag("--local_ram_resources", ram))
+	}
+
+	return args
+}
+
+// maximum heap of the bazel server, a quarter of the memory limit by default
+func (p *Config) hostJvmMaxHeap() string {
+	if p.HostJvmMaxHeap != "" {
+		return p.HostJvmMaxHeap
+	}
+
+	if p.limits.memory > 0 {
+		return fmt.Sprintf("%dm", p.limits.memory>>20/4)
+	}
+
+	return ""
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadCgroupLimits(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  resourceLimits
+	}{
+		// cgroup v2
+		{
+			files: map[string]string{"cpu.max": "200000 100000\n", "memory.max": "4294967296\n"},
+			want:  resourceLimits{cpus: 2, memory: 4 << 30},
+		},
+		{
+			files: map[string]string{"cpu.max": "max 100000\n", "memory.max": "max\n"},
+			want:  resourceLimits{},
+		},
+		// cgroup v1
+		{
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "150000\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "2147483648\n",
+			},
+			want: resourceLimits{cpus: 1.5, memory: 2 << 30},
+		},
+		{
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+			},
+			want: resourceLimits{},
+		},
+		// no cgroup filesystem
+		{
+			files: map[string]string{},
+			want:  resourceLimits{},
+		},
+	}
+
+	for _, test := range tests {
+		root := t.TempDir()
+		for name, content := range test.files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got := readCgroupLimits(root)
+		if got != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.files, got, test.want)
+		}
+	}
+}
+
+func TestResourceArgs(t *testing.T) {
+	tests := []struct {
+		plugin  Config
+		args    []string
+		startup []string
+	}{
+		{
+			plugin: Config{},
+		},
+		{
+			plugin:  Config{limits: resourceLimits{cpus: 1.5, memory: 4 << 30}},
+			args:    []string{"--jobs=2", "--local_ram_resources=2048"},
+			startup: []string{"--host_jvm_args=-Xmx1024m"},
+		},
+		// settings override the limits
+		{
+			plugin:  Config{Jobs: "8", LocalRamResources: "HOST_RAM*.5", HostJvmMaxHeap: "3g", limits: resourceLimits{cpus: 2, memory: 4 << 30}},
+			args:    []string{"--jobs=8", "--local_ram_resources=HOST_RAM*.5"},
+			startup: []string{"--host_jvm_args=-Xmx3g"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.resourceArgs()
+		if !reflect.DeepEqual(got, test.args) {
+			t.Errorf("got %v, want %v", got, test.args)
+		}
+
+		got = test.plugin.startupArgs()
+		if !reflect.DeepEqual(got, test.startup) {
+			t.Errorf("got startup %v, want %v", got, test.startup)
+		}
+	}
+}
+
+func TestDetectResources(t *testing.T) {
+	cgroupRoot = t.TempDir()
+	defer func() { cgroupRoot = "/sys/fs/cgroup" }()
+
+	err := os.WriteFile(filepath.Join(cgroupRoot, "cpu.max"), []byte("100000 100000\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Config{}
+	p.detectResources()
+	if p.limits.cpus != 1 {
+		t.Errorf("expected 1 CPU, got %v", p.limits.cpus)
+	}
+
+	disabled := false
+	p = Config{ResourceDefaults: &disabled}
+	p.detectResources()
+	if p.limits.cpus != 0 {
+		t.Errorf("expected no limits with resource defaults disabled, got %+v", p.limits)
+	}
+}
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// resource tags from key=value pairs
+func parseResourceTags(pairs []string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("resource tag must be a key=value pair: %s", pair)
+		}
+		tags[key] = value
+	}
+
+	return tags, nil
+}
+
+// tags of the repository, with the CI repository, pipeline and team when tag_ci_metadata is set
+func (p *Config) resourceTags(getter buildGetter) (map[string]string, error) {
+	tags, err := parseResourceTags(p.RepositoryTags)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.TagCIMetadata {
+		tags["drone:repo"] = getter.Repo()
+		tags["drone:pipeline"] = getter.PipelineName()
+		if p.Team != "" {
+			tags["team"] = p.Team
+		}
+	}
+
+	return tags, nil
+}
+
+// add the tags missing from the repository and update the tags with other values,
+// other tags of the repository are left untouched
+func (p *Config) reconcileRepositoryTags(svc ecriface.ECRAPI, tags map[string]string) error {
+	repos, err := svc.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RepositoryNames: aws.StringSlice([]string{p.Repository}),
+		RegistryId:      aws.String(p.registryID()),
+	})
+	if err != nil {
+		return err
+	}
+	if len(repos.Repositories) == 0 {
+		return fmt.Errorf("repository not found: %s", p.Repository)
+	}
+	arn := repos.Repositories[0].RepositoryArn
+
+	result, err := svc.ListTagsForResource(&ecr.ListTagsForResourceInput{ResourceArn: arn})
+	if err != nil {
+		return err
+	}
+
+	current := map[string]string{}
+	for _, tag := range result.Tags {
+		current[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	var keys []string
+	for key, value := range tags {
+		if got, ok := current[key]; !ok || got != value {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		log.Printf("tags of repository %s are up to date", p.Repository)
+		return nil
+	}
+
+	var update []*ecr.Tag
+	var changes []string
+	for _, key := range keys {
+		update = append(update, &ecr.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+		changes = append(changes, fmt.Sprintf("%s=%s", key, tags[key]))
+	}
+
+	_, err = svc.TagResource(&ecr.TagResourceInput{ResourceArn: arn, Tags: update})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("tagged repository %s with %s", p.Repository, strings.Join(changes, ", "))
+	return nil
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// holds the tags of a single repository
+type mockTagsClient struct {
+	mockECRClient
+
+	tags    map[string]string
+	updates int
+}
+
+func (m *mockTagsClient) DescribeRepositories(input *ecr.DescribeRepositoriesInput) (*ecr.DescribeRepositoriesOutput, error) {
+	return &ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{{
+		RepositoryName: input.RepositoryNames[0],
+		RepositoryArn:  aws.String("arn:aws:ecr:us-east-1:0123456789:repository/" + aws.StringValue(input.RepositoryNames[0])),
+	}}}, nil
+}
+
+func (m *mockTagsClient) ListTagsForResource(input *ecr.ListTagsForResourceInput) (*ecr.ListTagsForResourceOutput, error) {
+	var tags []*ecr.Tag
+	for key, value := range m.tags {
+		tags = append(tags, &ecr.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return &ecr.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (m *mockTagsClient) TagResource(input *ecr.TagResourceInput) (*ecr.TagResourceOutput, error) {
+	m.updates++
+	for _, tag := range input.Tags {
+		m.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return &ecr.TagResourceOutput{}, nil
+}
+
+func TestReconcileRepositoryTags(t *testing.T) {
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository"}
+	svc := &mockTagsClient{tags: map[string]string{"team": "payments", "cost-center": "1234", "created-by": "terraform"}}
+
+	tags, err := parseResourceTags([]string{"team=payments", " cost-center=5678", "env="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.reconcileRepositoryTags(svc, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other tags are kept
+	want := map[string]string{"team": "payments", "cost-center": "5678", "env": "", "created-by": "terraform"}
+	if !reflect.DeepEqual(want, svc.tags) {
+		t.Errorf("%v is not equal to %v", want, svc.tags)
+	}
+
+	// up to date tags are not updated again
+	err = p.reconcileRepositoryTags(svc, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.updates != 1 {
+		t.Errorf("expected a single update, got %d", svc.updates)
+	}
+
+	_, err = parseResourceTags([]string{"team"})
+	if err == nil {
+		t.Errorf("tag without a value should have failed")
+	}
+}
+
+func TestResourceTags(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   map[string]string
+	}{
+		{
+			plugin: Config{RepositoryTags: []string{"cost-center=1234"}},
+			want:   map[string]string{"cost-center": "1234"},
+		},
+		{
+			plugin: Config{RepositoryTags: []string{"cost-center=1234"}, TagCIMetadata: true, Team: "payments"},
+			want:   map[string]string{"cost-center": "1234", "drone:repo": "test", "drone:pipeline": "test", "team": "payments"},
+		},
+		// explicit team tags are kept without a team setting
+		{
+			plugin: Config{RepositoryTags: []string{"team=platform"}, TagCIMetadata: true},
+			want:   map[string]string{"team": "platform", "drone:repo": "test", "drone:pipeline": "test"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.plugin.resourceTags(newBuildMock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"errors"
+	"time"
+)
+
+// build statuses reported in notifications
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// outcome of a plugin run
+type result struct {
+	start    time.Time
+	duration time.Duration
+	err      error
+	exitCode *int
+	phases   []phaseTiming
+	images   []pushedImage
+	scans    []scanResult
+	events   *buildEvents
+	// how long bazel ran over the time budget
+	overBudget time.Duration
+}
+
+// duration of a single phase of the run
+type phaseTiming struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+}
+
+// image pushed during the run
+type pushedImage struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+}
+
+// findings per severity of the scan of a pushed image
+type scanResult struct {
+	Repository     string           `json:"repository"`
+	Digest         string           `json:"digest"`
+	SeverityCounts map[string]int64 `json:"severity_counts"`
+}
+
+// result constructor
+func newResult() *result {
+	return &result{start: time.Now()}
+}
+
+func (r result) status() string {
+	if r.err != nil {
+		return statusFailure
+	}
+
+	return statusSuccess
+}
+
+// digest of the first pushed image
+func (r result) digest() string {
+	if len(r.images) == 0 {
+		return ""
+	}
+
+	return r.images[0].Digest
+}
+
+// run fn and record how long it took
+func (r *result) time(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.phases = append(r.phases, phaseTiming{Name: name, Duration: time.Since(start).Seconds()})
+	return err
+}
+
+func (r *result) addImage(registry, repository, tag, digest string) {
+	r.images = append(r.images, pushedImage{
+		Registry:   registry,
+		Repository: repository,
+		Tag:        tag,
+		Digest:     digest,
+	})
+}
+
+func (r *result) addScan(repository, digest string, counts map[string]int64) {
+	if counts == nil {
+		counts = map[string]int64{}
+	}
+
+	r.scans = append(r.scans, scanResult{
+		Repository:     repository,
+		Digest:         digest,
+		SeverityCounts: counts,
+	})
+}
+
+// add the phases, images and scans of a matrix entry
+func (r *result) merge(name string, entry *result) {
+	for _, phase := range entry.phases {
+		r.phases = append(r.phases, phaseTiming{Name: name + "/" + phase.Name, Duration: phase.Duration})
+	}
+	r.images = append(r.images, entry.images...)
+	r.scans = append(r.scans, entry.scans...)
+
+	// keep the first failing exit code
+	if entry.exitCode != nil && (r.exitCode == nil || *r.exitCode == 0) {
+		r.exitCode = entry.exitCode
+	}
+}
+
+// error of a command that exited with a code
+type exitCoder interface {
+	ExitCode() int
+}
+
+// record the exit code of a finished command
+func (r *result) setExitCode(err error) {
+	code := 0
+
+	var exitErr exitCoder
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		// the command could not be started
+		return
+	}
+
+	r.exitCode = &code
+}
@@ -0,0 +1,209 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// command recorded by the recording runner
+type runnerCall struct {
+	name string
+	args []string
+	env  []string
+}
+
+// records commands instead of running them
+type recordingRunner struct {
+	mu    sync.Mutex
+	calls []runnerCall
+	err   error
+
+	// only commands for this target fail when set
+	failTarget string
+}
+
+func (r *recordingRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, runnerCall{name: name, args: withoutTempFiles(args), env: env})
+	if r.failTarget != "" && args[len(args)-1] != r.failTarget {
+		return nil
+	}
+	return r.err
+}
+
+// flags of temporary files written by the plugin
+var tempFileFlags = []string{"--build_event_json_file="}
+
+// arguments with the paths of temporary files removed from their flags
+func withoutTempFiles(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		for _, flag := range tempFileFlags {
+			if strings.HasPrefix(arg, flag) {
+				out[i] = strings.TrimSuffix(flag, "=")
+			}
+		}
+	}
+
+	return out
+}
+
+// look up a variable in a KEY=value environment
+func lookupEnv(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, _ := strings.Cut(env[i], "=")
+		if k == key {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+func TestRun(t *testing.T) {
+	testFailure = ""
+
+	cfg := Config{
+		Target:           "//:push",
+		Registry:         "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:       "repository",
+		Tag:              "v1",
+		Bazelrc:          ".bazelrc.ci",
+		CreateRepository: true,
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.calls) != 1 {
+		t.Fatalf("%d commands run, expected 1", len(runner.calls))
+	}
+
+	call := runner.calls[0]
+	if call.name != "bazel" {
+		t.Errorf("%v is not equal to %v", "bazel", call.name)
+	}
+
+	want := []string{"--bazelrc=.bazelrc.ci", "run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"}
+	if !reflect.DeepEqual(want, call.args) {
+		t.Errorf("%v is not equal to %v", want, call.args)
+	}
+
+	wantEnv := map[string]string{
+		"DRONE_ECR_REGISTRY":   cfg.Registry,
+		"DRONE_ECR_REPOSITORY": "repository",
+		"DRONE_ECR_TAG":        "v1",
+		"DRONE_ECR_IMAGE":      cfg.Registry + "/repository:v1",
+	}
+	for key, val := range wantEnv {
+		if got, _ := lookupEnv(call.env, key); got != val {
+			t.Errorf("%s: %v is not equal to %v", key, val, got)
+		}
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		runner  *recordingRunner
+		failure string
+		calls   int
+	}{
+		// invalid configs never run bazel
+		{
+			cfg:    Config{Registry: "registry"},
+			runner: &recordingRunner{},
+		},
+		// repository creation failures stop the build
+		{
+			cfg:     Config{Target: "//:push", Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", CreateRepository: true},
+			runner:  &recordingRunner{},
+			failure: "CreateRepository",
+		},
+		// bazel failures are returned
+		{
+			cfg:    Config{Target: "//:push", Registry: "registry"},
+			runner: &recordingRunner{err: errors.New("bazel failed")},
+			calls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+
+		err := Run(context.Background(), test.cfg, WithRunner(test.runner), WithECRClient(&mockECRClient{}))
+		if err == nil {
+			t.Errorf("%+v: run should have failed", test.cfg)
+		}
+
+		if len(test.runner.calls) != test.calls {
+			t.Errorf("%d commands run, expected %d", len(test.runner.calls), test.calls)
+		}
+	}
+
+	testFailure = ""
+}
+
+func TestRunHooks(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+	t.Setenv("DRONE_COMMIT_BRANCH", "main")
+
+	// the tag rules are applied before the hooks run
+	cfg := Config{
+		Target:     "//:push",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+		TagRules:   []string{"main=>v1"},
+		PreCmds:    []string{"echo $DRONE_ECR_TAG > VERSION"},
+		PostCmds:   []string{"./notify-indexer.sh"},
+		AllowHooks: true,
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runnerCall{
+		{name: "sh", args: []string{"-c", "echo $DRONE_ECR_TAG > VERSION"}},
+		{name: "bazel", args: []string{"run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"}},
+		{name: "sh", args: []string{"-c", "./notify-indexer.sh"}},
+	}
+	if len(runner.calls) != len(want) {
+		t.Fatalf("%d commands run, expected %d", len(runner.calls), len(want))
+	}
+
+	for i, call := range runner.calls {
+		if call.name != want[i].name || !reflect.DeepEqual(call.args, want[i].args) {
+			t.Errorf("%v %v is not equal to %v %v", call.name, call.args, want[i].name, want[i].args)
+		}
+
+		// hooks see the same environment as bazel
+		if got, _ := lookupEnv(call.env, "DRONE_ECR_TAG"); got != "v1" {
+			t.Errorf("%v is not equal to %v", got, "v1")
+		}
+	}
+
+	// bazel is not run when a pre command fails
+	runner = &recordingRunner{err: errors.New("failed")}
+	err = Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	if len(runner.calls) != 1 {
+		t.Errorf("%d commands run, expected 1", len(runner.calls))
+	}
+}
@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// minimal SARIF 2.1.0 log accepted by code scanning dashboards
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	ShortDescription sarifMessage      `json:"shortDescription"`
+	FullDescription  sarifMessage      `json:"fullDescription"`
+	HelpURI          string            `json:"helpUri,omitempty"`
+	Properties       map[string]string `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIF level and security severity score of an ECR finding severity
+func sarifSeverity(severity string) (string, string) {
+	switch severity {
+	case ecr.FindingSeverityCritical:
+		return "error", "9.0"
+	case ecr.FindingSeverityHigh:
+		return "error", "7.0"
+	case ecr.FindingSeverityMedium:
+		return "warning", "4.0"
+	default:
+		return "note", "0.1"
+	}
+}
+
+// convert basic and enhanced scan findings of the pushed image to SARIF
+func (p *Config) scanSARIF(findings *ecr.DescribeImageScanFindingsOutput, digest string) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "Amazon ECR image scanning",
+			InformationURI: "https://docs.aws.amazon.com/AmazonECR/latest/userguide/image-scanning.html",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	image := fmt.Sprintf("%s/%s@%s", p.Registry, p.Repository, digest)
+	rules := map[string]bool{}
+
+	addRule := func(id, title, description, uri, severity, score string) {
+		if rules[id] {
+			return
+		}
+		rules[id] = true
+
+		if title == "" {
+			title = id
+		}
+		if description == "" {
+			description = title
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               id,
+			ShortDescription: sarifMessage{Text: title},
+			FullDescription:  sarifMessage{Text: description},
+			HelpURI:          uri,
+			Properties:       map[string]string{"security-severity": score, "severity": severity},
+		})
+	}
+
+	addResult := func(id, severity, pkg, location string) {
+		level, _ := sarifSeverity(severity)
+		message := fmt.Sprintf("%s %s in %s", severity, id, image)
+		if pkg != "" {
+			message += ": " + pkg
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			Level:     level,
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: location}}}},
+		})
+	}
+
+	if findings.ImageScanFindings == nil {
+		return &sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}
+	}
+
+	for _, finding := range findings.ImageScanFindings.Findings {
+		id := aws.StringValue(finding.Name)
+		severity := aws.StringValue(finding.Severity)
+		_, score := sarifSeverity(severity)
+		addRule(id, id, aws.StringValue(finding.Description), aws.StringValue(finding.Uri), severity, score)
+
+		attributes := map[string]string{}
+		for _, attribute := range finding.Attributes {
+			attributes[aws.StringValue(attribute.Key)] = aws.StringValue(attribute.Value)
+		}
+		pkg := strings.TrimSpace(attributes["package_name"] + " " + attributes["package_version"])
+		addResult(id, severity, pkg, p.Repository)
+	}
+
+	for _, finding := range findings.ImageScanFindings.EnhancedFindings {
+		details := finding.PackageVulnerabilityDetails
+		if details == nil {
+			details = &ecr.PackageVulnerabilityDetails{}
+		}
+
+		id := aws.StringValue(details.VulnerabilityId)
+		if id == "" {
+			id = aws.StringValue(finding.Title)
+		}
+		severity := aws.StringValue(finding.Severity)
+		_, score := sarifSeverity(severity)
+		if finding.Score != nil {
+			score = fmt.Sprintf("%.1f", aws.Float64Value(finding.Score))
+		}
+		addRule(id, aws.StringValue(finding.Title), aws.StringValue(finding.Description), aws.StringValue(details.SourceUrl), severity, score)
+
+		for _, pkg := range details.VulnerablePackages {
+			location := aws.StringValue(pkg.FilePath)
+			if location == "" {
+				location = p.Repository
+			}
+			addResult(id, severity, strings.TrimSpace(aws.StringValue(pkg.Name)+" "+aws.StringValue(pkg.Version)), location)
+		}
+		if len(details.VulnerablePackages) == 0 {
+			addResult(id, severity, "", p.Repository)
+		}
+	}
+
+	return &sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}
+}
+
+// write the scan findings to scan_sarif_output as SARIF
+func (p *Config) writeScanSARIF(findings *ecr.DescribeImageScanFindingsOutput, digest string) error {
+	body, err := json.MarshalIndent(p.scanSARIF(findings, digest), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.ScanSarifOutput, body, 0644)
+}
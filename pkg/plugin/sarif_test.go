@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func TestWriteScanSARIF(t *testing.T) {
+	basic := testFinding("CVE-2023-0001", ecr.FindingSeverityHigh)
+	basic.Attributes = []*ecr.Attribute{
+		{Key: aws.String("package_name"), Value: aws.String("openssl")},
+		{Key: aws.String("package_version"), Value: aws.String("3.0.1")},
+	}
+
+	enhanced := &ecr.EnhancedImageScanFinding{
+		Title:    aws.String("CVE-2023-0002 - requests"),
+		Severity: aws.String(ecr.FindingSeverityMedium),
+		Score:    aws.Float64(5.3),
+		PackageVulnerabilityDetails: &ecr.PackageVulnerabilityDetails{
+			VulnerabilityId: aws.String("CVE-2023-0002"),
+			VulnerablePackages: []*ecr.VulnerablePackage{
+				{Name: aws.String("requests"), Version: aws.String("2.28.0"), FilePath: aws.String("app/requirements.txt")},
+				{Name: aws.String("requests"), Version: aws.String("2.28.0"), FilePath: aws.String("tools/requirements.txt")},
+			},
+		},
+	}
+
+	p := Config{Registry: "registry", Repository: "test", ScanSarifOutput: filepath.Join(t.TempDir(), "scan.sarif")}
+	svc := &mockScanFindingsClient{findings: []*ecr.ImageScanFinding{basic}, enhanced: []*ecr.EnhancedImageScanFinding{enhanced}}
+
+	findings, err := p.scanFindings(svc, testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.writeScanSARIF(findings, testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(p.ScanSarifOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	err = json.Unmarshal(body, &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := log.Runs[0]
+
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID+" "+rule.Properties["security-severity"])
+	}
+	if want := []string{"CVE-2023-0001 7.0", "CVE-2023-0002 5.3"}; !reflect.DeepEqual(want, rules) {
+		t.Errorf("%v is not equal to %v", want, rules)
+	}
+
+	var results []string
+	for _, result := range run.Results {
+		results = append(results, result.Level+" "+result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	if want := []string{"error test", "warning app/requirements.txt", "warning tools/requirements.txt"}; !reflect.DeepEqual(want, results) {
+		t.Errorf("%v is not equal to %v", want, results)
+	}
+
+	if want := "HIGH CVE-2023-0001 in registry/test@" + testDigest + ": openssl 3.0.1"; run.Results[0].Message.Text != want {
+		t.Errorf("%s is not equal to %s", want, run.Results[0].Message.Text)
+	}
+}
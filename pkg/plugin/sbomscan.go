@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// subset of the grype JSON report
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+// scan the SBOM generated by the build with grype and apply fail_on_severity, before the
+// registry scan of the pushed image completes
+func (p *Config) scanSBOM(ctx context.Context, runner Runner) error {
+	f, err := os.CreateTemp("", "grype-*.json")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	args := []string{"sbom:" + p.SbomPath, "--output", "json", "--file", f.Name()}
+	err = runner.Run(ctx, "grype", args, append(os.Environ(), p.environ()...))
+	if err != nil {
+		return fmt.Errorf("could not scan the SBOM %s: %w", p.SbomPath, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+
+	var report grypeReport
+	err = json.Unmarshal(data, &report)
+	if err != nil {
+		return fmt.Errorf("could not parse the SBOM scan report: %w", err)
+	}
+
+	return p.checkSBOMFindings(report)
+}
+
+// log the number of matches per severity and fail on matches at or above fail_on_severity
+func (p *Config) checkSBOMFindings(report grypeReport) error {
+	counts := map[string]int{}
+	var failing []string
+	for _, match := range report.Matches {
+		severity := strings.ToUpper(match.Vulnerability.Severity)
+		counts[severity]++
+
+		if p.failsOnSeverity(severity) {
+			failing = append(failing, fmt.Sprintf("%s %s %s (%s)", match.Vulnerability.ID, match.Artifact.Name, match.Artifact.Version, severity))
+		}
+	}
+
+	var summary []string
+	for severity, count := range counts {
+		summary = append(summary, fmt.Sprintf("%s=%d", severity, count))
+	}
+	sort.Strings(summary)
+
+	if len(summary) == 0 {
+		log.Printf("scan of the SBOM %s found no vulnerabilities", p.SbomPath)
+	} else {
+		log.Printf("scan of the SBOM %s found %s", p.SbomPath, strings.Join(summary, " "))
+	}
+
+	if len(failing) > 0 {
+		return fmt.Errorf("scan of the SBOM %s found %d vulnerabilities of severity %s or higher: %s",
+			p.SbomPath, len(failing), strings.ToUpper(p.FailOnSeverity), strings.Join(failing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testGrypeReport = `{"matches": [
+	{"vulnerability": {"id": "CVE-2023-0001", "severity": "Critical"}, "artifact": {"name": "openssl", "version": "3.0.1"}},
+	{"vulnerability": {"id": "CVE-2023-0002", "severity": "Medium"}, "artifact": {"name": "zlib", "version": "1.2.11"}},
+	{"vulnerability": {"id": "CVE-2023-0003", "severity": "Negligible"}, "artifact": {"name": "bash", "version": "5.1"}}
+]}`
+
+// writes the grype report to the --file argument
+type grypeRunner struct {
+	recordingRunner
+}
+
+func (r *grypeRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	for i, arg := range args {
+		if arg == "--file" {
+			if err := os.WriteFile(args[i+1], []byte(testGrypeReport), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.recordingRunner.Run(ctx, name, args, env)
+}
+
+func TestScanSBOM(t *testing.T) {
+	tests := []struct {
+		severity string
+		fail     bool
+	}{
+		{severity: ""},
+		{severity: "critical", fail: true},
+		{severity: "MEDIUM", fail: true},
+		{severity: "HIGH", fail: true},
+	}
+
+	for _, test := range tests {
+		p := Config{SbomPath: "bazel-bin/app/sbom.spdx.json", FailOnSeverity: test.severity}
+		runner := &grypeRunner{}
+
+		err := p.scanSBOM(context.Background(), runner)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.severity, err)
+		}
+
+		if len(runner.calls) != 1 || runner.calls[0].name != "grype" || runner.calls[0].args[0] != "sbom:bazel-bin/app/sbom.spdx.json" {
+			t.Errorf("unexpected commands: %+v", runner.calls)
+		}
+	}
+
+	// only matches at or above the severity are listed
+	p := Config{SbomPath: "sbom.json", FailOnSeverity: "HIGH"}
+	err := p.scanSBOM(context.Background(), &grypeRunner{})
+	if err == nil || !strings.Contains(err.Error(), "CVE-2023-0001") || strings.Contains(err.Error(), "CVE-2023-0002") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	p = Config{Target: "//:push", Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", FailOnSeverity: "SEVERE", ScanWait: true}
+	if p.validate() == nil {
+		t.Errorf("unsupported severity should have failed")
+	}
+
+	p = Config{Target: "//:push", Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", FailOnSeverity: "HIGH"}
+	if p.validate() == nil {
+		t.Errorf("severity without a scan should have failed")
+	}
+}
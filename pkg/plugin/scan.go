@@ -0,0 +1,339 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// wait for the scan of the pushed image to complete, for up to scan_timeout, and return all of its findings
+func (p *Config) waitForScan(svc ecriface.ECRAPI, digest string) (*ecr.DescribeImageScanFindingsOutput, error) {
+	timeout := p.ScanTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		findings, err := p.scanFindings(svc, digest)
+		// scans on push can take a moment to start
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeScanNotFoundException {
+			findings, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if findings != nil && findings.ImageScanStatus != nil {
+			switch status := aws.StringValue(findings.ImageScanStatus.Status); status {
+			// enhanced scans stay active while the image is continuously scanned
+			case ecr.ScanStatusComplete, ecr.ScanStatusActive:
+				logScanFindings(p.Repository, digest, findings)
+				return findings, nil
+			case ecr.ScanStatusInProgress, ecr.ScanStatusPending:
+			default:
+				return nil, fmt.Errorf("scan of %s@%s did not complete: %s %s", p.Repository, digest, status, aws.StringValue(findings.ImageScanStatus.Description))
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("scan of %s@%s did not complete after %s", p.Repository, digest, timeout)
+		}
+
+		time.Sleep(imagePollInterval)
+	}
+}
+
+// all findings of the image scan, merging every page into the first
+func (p *Config) scanFindings(svc ecriface.ECRAPI, digest string) (*ecr.DescribeImageScanFindingsOutput, error) {
+	var findings *ecr.DescribeImageScanFindingsOutput
+
+	input := &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(p.Repository),
+		RegistryId:     aws.String(p.registryID()),
+		ImageId:        &ecr.ImageIdentifier{ImageDigest: aws.String(digest)},
+		MaxResults:     aws.Int64(1000),
+	}
+	err := svc.DescribeImageScanFindingsPages(input, func(page *ecr.DescribeImageScanFindingsOutput, last bool) bool {
+		if findings == nil {
+			findings = page
+			return true
+		}
+
+		if page.ImageScanFindings != nil {
+			if findings.ImageScanFindings == nil {
+				findings.ImageScanFindings = &ecr.ImageScanFindings{}
+			}
+			findings.ImageScanFindings.Findings = append(findings.ImageScanFindings.Findings, page.ImageScanFindings.Findings...)
+			findings.ImageScanFindings.EnhancedFindings = append(findings.ImageScanFindings.EnhancedFindings, page.ImageScanFindings.EnhancedFindings...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if findings != nil {
+		findings.NextToken = nil
+	}
+
+	return findings, nil
+}
+
+// log the number of findings per severity
+func logScanFindings(repository, digest string, findings *ecr.DescribeImageScanFindingsOutput) {
+	if findings.ImageScanFindings == nil || len(findings.ImageScanFindings.FindingSeverityCounts) == 0 {
+		log.Printf("scan of %s@%s found no vulnerabilities", repository, digest)
+		return
+	}
+
+	var counts []string
+	for severity, count := range findings.ImageScanFindings.FindingSeverityCounts {
+		counts = append(counts, fmt.Sprintf("%s=%d", severity, aws.Int64Value(count)))
+	}
+	sort.Strings(counts)
+
+	log.Printf("scan of %s@%s found %s", repository, digest, strings.Join(counts, " "))
+}
+
+// findings written to scan_findings_output with the field names of the DescribeImageScanFindings API
+type scanReport struct {
+	RegistryID        string             `json:"registryId,omitempty"`
+	RepositoryName    string             `json:"repositoryName,omitempty"`
+	ImageID           scanImageID        `json:"imageId"`
+	ImageScanStatus   *scanStatus        `json:"imageScanStatus,omitempty"`
+	ImageScanFindings scanFindingsReport `json:"imageScanFindings"`
+}
+
+type scanImageID struct {
+	ImageDigest string `json:"imageDigest,omitempty"`
+	ImageTag    string `json:"imageTag,omitempty"`
+}
+
+type scanStatus struct {
+	Status      string `json:"status"`
+	Description string `json:"description,omitempty"`
+}
+
+// timestamps are in epoch seconds like in the API
+type scanFindingsReport struct {
+	ImageScanCompletedAt         float64           `json:"imageScanCompletedAt,omitempty"`
+	VulnerabilitySourceUpdatedAt float64           `json:"vulnerabilitySourceUpdatedAt,omitempty"`
+	FindingSeverityCounts        map[string]int64  `json:"findingSeverityCounts,omitempty"`
+	Findings                     []scanFinding     `json:"findings,omitempty"`
+	EnhancedFindings             []enhancedFinding `json:"enhancedFindings,omitempty"`
+}
+
+type scanFinding struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	URI         string          `json:"uri,omitempty"`
+	Severity    string          `json:"severity"`
+	Attributes  []scanAttribute `json:"attributes,omitempty"`
+}
+
+type scanAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+type enhancedFinding struct {
+	FindingArn                  string                `json:"findingArn"`
+	Title                       string                `json:"title,omitempty"`
+	Description                 string                `json:"description,omitempty"`
+	Severity                    string                `json:"severity"`
+	Status                      string                `json:"status,omitempty"`
+	Type                        string                `json:"type,omitempty"`
+	Score                       float64               `json:"score,omitempty"`
+	FirstObservedAt             float64               `json:"firstObservedAt,omitempty"`
+	LastObservedAt              float64               `json:"lastObservedAt,omitempty"`
+	PackageVulnerabilityDetails *packageVulnerability `json:"packageVulnerabilityDetails,omitempty"`
+	Remediation                 *scanRemediation      `json:"remediation,omitempty"`
+}
+
+type packageVulnerability struct {
+	VulnerabilityID    string              `json:"vulnerabilityId"`
+	Source             string              `json:"source,omitempty"`
+	SourceURL          string              `json:"sourceUrl,omitempty"`
+	VendorSeverity     string              `json:"vendorSeverity,omitempty"`
+	ReferenceUrls      []string            `json:"referenceUrls,omitempty"`
+	VulnerablePackages []vulnerablePackage `json:"vulnerablePackages,omitempty"`
+}
+
+type vulnerablePackage struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	Release        string `json:"release,omitempty"`
+	Arch           string `json:"arch,omitempty"`
+	PackageManager string `json:"packageManager,omitempty"`
+	FilePath       string `json:"filePath,omitempty"`
+}
+
+type scanRemediation struct {
+	Recommendation scanRecommendation `json:"recommendation"`
+}
+
+type scanRecommendation struct {
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// seconds since the epoch of an API timestamp, zero when it is not set
+func epochSeconds(t *time.Time) float64 {
+	if t == nil {
+		return 0
+	}
+
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// report of the findings in the format of the DescribeImageScanFindings API
+func newScanReport(findings *ecr.DescribeImageScanFindingsOutput) scanReport {
+	report := scanReport{
+		RegistryID:     aws.StringValue(findings.RegistryId),
+		RepositoryName: aws.StringValue(findings.RepositoryName),
+	}
+
+	if findings.ImageId != nil {
+		report.ImageID = scanImageID{
+			ImageDigest: aws.StringValue(findings.ImageId.ImageDigest),
+			ImageTag:    aws.StringValue(findings.ImageId.ImageTag),
+		}
+	}
+
+	if findings.ImageScanStatus != nil {
+		report.ImageScanStatus = &scanStatus{
+			Status:      aws.StringValue(findings.ImageScanStatus.Status),
+			Description: aws.StringValue(findings.ImageScanStatus.Description),
+		}
+	}
+
+	scan := findings.ImageScanFindings
+	if scan == nil {
+		return report
+	}
+
+	report.ImageScanFindings = scanFindingsReport{
+		ImageScanCompletedAt:         epochSeconds(scan.ImageScanCompletedAt),
+		VulnerabilitySourceUpdatedAt: epochSeconds(scan.VulnerabilitySourceUpdatedAt),
+		FindingSeverityCounts:        aws.Int64ValueMap(scan.FindingSeverityCounts),
+	}
+
+	for _, f := range scan.Findings {
+		finding := scanFinding{
+			Name:        aws.StringValue(f.Name),
+			Description: aws.StringValue(f.Description),
+			URI:         aws.StringValue(f.Uri),
+			Severity:    aws.StringValue(f.Severity),
+		}
+		for _, attr := range f.Attributes {
+			finding.Attributes = append(finding.Attributes, scanAttribute{Key: aws.StringValue(attr.Key), Value: aws.StringValue(attr.Value)})
+		}
+		report.ImageScanFindings.Findings = append(report.ImageScanFindings.Findings, finding)
+	}
+
+	for _, f := range scan.EnhancedFindings {
+		finding := enhancedFinding{
+			FindingArn:      aws.StringValue(f.FindingArn),
+			Title:           aws.StringValue(f.Title),
+			Description:     aws.StringValue(f.Description),
+			Severity:        aws.StringValue(f.Severity),
+			Status:          aws.StringValue(f.Status),
+			Type:            aws.StringValue(f.Type),
+			Score:           aws.Float64Value(f.Score),
+			FirstObservedAt: epochSeconds(f.FirstObservedAt),
+			LastObservedAt:  epochSeconds(f.LastObservedAt),
+		}
+
+		if details := f.PackageVulnerabilityDetails; details != nil {
+			vuln := &packageVulnerability{
+				VulnerabilityID: aws.StringValue(details.VulnerabilityId),
+				Source:          aws.StringValue(details.Source),
+				SourceURL:       aws.StringValue(details.SourceUrl),
+				VendorSeverity:  aws.StringValue(details.VendorSeverity),
+				ReferenceUrls:   aws.StringValueSlice(details.ReferenceUrls),
+			}
+			for _, pkg := range details.VulnerablePackages {
+				vuln.VulnerablePackages = append(vuln.VulnerablePackages, vulnerablePackage{
+					Name:           aws.StringValue(pkg.Name),
+					Version:        aws.StringValue(pkg.Version),
+					Release:        aws.StringValue(pkg.Release),
+					Arch:           aws.StringValue(pkg.Arch),
+					PackageManager: aws.StringValue(pkg.PackageManager),
+					FilePath:       aws.StringValue(pkg.FilePath),
+				})
+			}
+			finding.PackageVulnerabilityDetails = vuln
+		}
+
+		if f.Remediation != nil && f.Remediation.Recommendation != nil {
+			finding.Remediation = &scanRemediation{Recommendation: scanRecommendation{
+				Text: aws.StringValue(f.Remediation.Recommendation.Text),
+				URL:  aws.StringValue(f.Remediation.Recommendation.Url),
+			}}
+		}
+
+		report.ImageScanFindings.EnhancedFindings = append(report.ImageScanFindings.EnhancedFindings, finding)
+	}
+
+	return report
+}
+
+// write the findings to scan_findings_output in the format of the DescribeImageScanFindings API
+func (p *Config) writeScanFindings(findings *ecr.DescribeImageScanFindingsOutput) error {
+	body, err := json.MarshalIndent(newScanReport(findings), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.ScanFindingsOutput, body, 0644)
+}
+
+// order of the finding severities, unknown severities rank lowest
+var severityRank = map[string]int{
+	ecr.FindingSeverityInformational: 1,
+	"NEGLIGIBLE":                     1,
+	ecr.FindingSeverityLow:           2,
+	ecr.FindingSeverityMedium:        3,
+	ecr.FindingSeverityHigh:          4,
+	ecr.FindingSeverityCritical:      5,
+}
+
+// whether a severity is at or above fail_on_severity
+func (p *Config) failsOnSeverity(severity string) bool {
+	if p.FailOnSeverity == "" {
+		return false
+	}
+
+	rank, ok := severityRank[strings.ToUpper(severity)]
+	return ok && rank >= severityRank[strings.ToUpper(p.FailOnSeverity)]
+}
+
+// fail when the scan found vulnerabilities at or above fail_on_severity
+func (p *Config) checkScanSeverity(findings *ecr.DescribeImageScanFindingsOutput, digest string) error {
+	if findings.ImageScanFindings == nil {
+		return nil
+	}
+
+	var failing int64
+	for severity, count := range findings.ImageScanFindings.FindingSeverityCounts {
+		if p.failsOnSeverity(severity) {
+			failing += aws.Int64Value(count)
+		}
+	}
+
+	if failing > 0 {
+		return fmt.Errorf("scan of %s@%s found %d vulnerabilities of severity %s or higher", p.Repository, digest, failing, strings.ToUpper(p.FailOnSeverity))
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// reports the scan statuses in order, then serves the findings one per page
+type mockScanFindingsClient struct {
+	mockECRClient
+
+	statuses []string
+	findings []*ecr.ImageScanFinding
+	enhanced []*ecr.EnhancedImageScanFinding
+	calls    int
+}
+
+func (m *mockScanFindingsClient) DescribeImageScanFindingsPages(input *ecr.DescribeImageScanFindingsInput, fn func(*ecr.DescribeImageScanFindingsOutput, bool) bool) error {
+	status := ecr.ScanStatusComplete
+	if m.calls < len(m.statuses) {
+		status = m.statuses[m.calls]
+	}
+	m.calls++
+
+	if status == "" {
+		return awserr.New(ecr.ErrCodeScanNotFoundException, "", errors.New("ScanNotFound"))
+	}
+
+	first := &ecr.DescribeImageScanFindingsOutput{
+		RepositoryName:  input.RepositoryName,
+		ImageId:         input.ImageId,
+		ImageScanStatus: &ecr.ImageScanStatus{Status: aws.String(status)},
+	}
+	if status != ecr.ScanStatusComplete && status != ecr.ScanStatusActive {
+		fn(first, true)
+		return nil
+	}
+
+	counts := map[string]*int64{}
+	for _, finding := range m.findings {
+		severity := aws.StringValue(finding.Severity)
+		counts[severity] = aws.Int64(aws.Int64Value(counts[severity]) + 1)
+	}
+	first.ImageScanFindings = &ecr.ImageScanFindings{FindingSeverityCounts: counts}
+
+	var pages []*ecr.DescribeImageScanFindingsOutput
+	for _, finding := range m.findings {
+		pages = append(pages, &ecr.DescribeImageScanFindingsOutput{ImageScanFindings: &ecr.ImageScanFindings{Findings: []*ecr.ImageScanFinding{finding}}})
+	}
+	for _, finding := range m.enhanced {
+		pages = append(pages, &ecr.DescribeImageScanFindingsOutput{ImageScanFindings: &ecr.ImageScanFindings{EnhancedFindings: []*ecr.EnhancedImageScanFinding{finding}}})
+	}
+
+	if !fn(first, len(pages) == 0) {
+		return nil
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func testFinding(name, severity string) *ecr.ImageScanFinding {
+	return &ecr.ImageScanFinding{Name: aws.String(name), Severity: aws.String(severity)}
+}
+
+func TestWaitForScan(t *testing.T) {
+	imagePollInterval = time.Millisecond
+	defer func() { imagePollInterval = 2 * time.Second }()
+
+	findings := []*ecr.ImageScanFinding{testFinding("CVE-2023-0001", "HIGH"), testFinding("CVE-2023-0002", "LOW")}
+
+	tests := []struct {
+		statuses []string
+		timeout  time.Duration
+		err      bool
+	}{
+		{statuses: []string{"", ecr.ScanStatusPending, ecr.ScanStatusInProgress}},
+		{statuses: []string{ecr.ScanStatusActive}},
+		{statuses: []string{ecr.ScanStatusFailed}, err: true},
+		{statuses: []string{ecr.ScanStatusInProgress, ecr.ScanStatusInProgress, ecr.ScanStatusInProgress}, timeout: time.Nanosecond, err: true},
+	}
+
+	for _, test := range tests {
+		p := Config{Repository: "test", ScanTimeout: test.timeout}
+		svc := &mockScanFindingsClient{statuses: test.statuses, findings: findings}
+
+		result, err := p.waitForScan(svc, testDigest)
+		if (err != nil) != test.err {
+			t.Fatalf("%v: unexpected error: %v", test.statuses, err)
+		}
+		if test.err {
+			continue
+		}
+
+		// every page is merged
+		if len(result.ImageScanFindings.Findings) != len(findings) {
+			t.Errorf("%d is not equal to %d", len(findings), len(result.ImageScanFindings.Findings))
+		}
+	}
+}
+
+func TestWriteScanFindings(t *testing.T) {
+	p := Config{Repository: "test", ScanFindingsOutput: filepath.Join(t.TempDir(), "findings.json")}
+	svc := &mockScanFindingsClient{findings: []*ecr.ImageScanFinding{testFinding("CVE-2023-0001", "HIGH"), testFinding("CVE-2023-0002", "LOW")}}
+
+	findings, err := p.scanFindings(svc, testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.writeScanFindings(findings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(p.ScanFindingsOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the artifact uses the field names of the API
+	var report struct {
+		ImageScanFindings struct {
+			Findings []struct {
+				Name string `json:"name"`
+			} `json:"findings"`
+			FindingSeverityCounts map[string]int `json:"findingSeverityCounts"`
+		} `json:"imageScanFindings"`
+	}
+	err = json.Unmarshal(body, &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.ImageScanFindings.Findings) != 2 || report.ImageScanFindings.Findings[1].Name != "CVE-2023-0002" {
+		t.Errorf("unexpected findings: %s", body)
+	}
+
+	if report.ImageScanFindings.FindingSeverityCounts["HIGH"] != 1 {
+		t.Errorf("unexpected severity counts: %s", body)
+	}
+}
+
+func TestCheckScanSeverity(t *testing.T) {
+	findings := &ecr.DescribeImageScanFindingsOutput{
+		ImageScanFindings: &ecr.ImageScanFindings{
+			FindingSeverityCounts: map[string]*int64{
+				ecr.FindingSeverityHigh: aws.Int64(2),
+				ecr.FindingSeverityLow:  aws.Int64(5),
+			},
+		},
+	}
+
+	tests := []struct {
+		severity string
+		fail     bool
+	}{
+		{severity: ""},
+		{severity: ecr.FindingSeverityCritical},
+		{severity: ecr.FindingSeverityHigh, fail: true},
+		{severity: "low", fail: true},
+	}
+
+	for _, test := range tests {
+		p := Config{Repository: "repository", FailOnSeverity: test.severity}
+		err := p.checkScanSeverity(findings, testDigest)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.severity, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// update the registry scanning configuration when it differs from the registry_scanning spec
+func (p *Config) reconcileRegistryScanning(svc ecriface.ECRAPI) error {
+	// the spec uses the field names of the PutRegistryScanningConfiguration API
+	desired := &ecr.PutRegistryScanningConfigurationInput{}
+	err := json.Unmarshal([]byte(p.RegistryScanning), desired)
+	if err != nil {
+		return fmt.Errorf("could not parse registry scanning configuration: %w", err)
+	}
+
+	err = desired.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid registry scanning configuration: %w", err)
+	}
+
+	current, err := svc.GetRegistryScanningConfiguration(&ecr.GetRegistryScanningConfigurationInput{})
+	if err != nil {
+		return err
+	}
+
+	if current.ScanningConfiguration != nil &&
+		aws.StringValue(current.ScanningConfiguration.ScanType) == aws.StringValue(desired.ScanType) &&
+		reflect.DeepEqual(current.ScanningConfiguration.Rules, desired.Rules) {
+		log.Printf("registry scanning configuration is up to date")
+		return nil
+	}
+
+	_, err = svc.PutRegistryScanningConfiguration(desired)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("updated registry scanning configuration to %s scanning", aws.StringValue(desired.ScanType))
+	return nil
+}
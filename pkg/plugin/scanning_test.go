@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// returns a basic scan on push configuration and records updates
+type mockScanningClient struct {
+	mockECRClient
+
+	updated *ecr.PutRegistryScanningConfigurationInput
+}
+
+func (m *mockScanningClient) GetRegistryScanningConfiguration(input *ecr.GetRegistryScanningConfigurationInput) (*ecr.GetRegistryScanningConfigurationOutput, error) {
+	return &ecr.GetRegistryScanningConfigurationOutput{
+		RegistryId: aws.String("0123456789"),
+		ScanningConfiguration: &ecr.RegistryScanningConfiguration{
+			ScanType: aws.String(ecr.ScanTypeBasic),
+			Rules: []*ecr.RegistryScanningRule{
+				{
+					ScanFrequency: aws.String(ecr.ScanFrequencyScanOnPush),
+					RepositoryFilters: []*ecr.ScanningRepositoryFilter{
+						{Filter: aws.String("*"), FilterType: aws.String(ecr.ScanningRepositoryFilterTypeWildcard)},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (m *mockScanningClient) PutRegistryScanningConfiguration(input *ecr.PutRegistryScanningConfigurationInput) (*ecr.PutRegistryScanningConfigurationOutput, error) {
+	m.updated = input
+	return &ecr.PutRegistryScanningConfigurationOutput{}, nil
+}
+
+func TestReconcileRegistryScanning(t *testing.T) {
+	tests := []struct {
+		spec    string
+		updated bool
+		err     bool
+	}{
+		{
+			spec: `{"scanType": "BASIC", "rules": [{"scanFrequency": "SCAN_ON_PUSH", "repositoryFilters": [{"filter": "*", "filterType": "WILDCARD"}]}]}`,
+		},
+		{
+			spec:    `{"scanType": "ENHANCED", "rules": [{"scanFrequency": "CONTINUOUS_SCAN", "repositoryFilters": [{"filter": "prod/*", "filterType": "WILDCARD"}]}]}`,
+			updated: true,
+		},
+		{
+			spec: `{"scanType": "ENHANCED", "rules": [{"scanFrequency": "CONTINUOUS_SCAN"}]}`,
+			err:  true,
+		},
+		{
+			spec: "ENHANCED",
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		svc := &mockScanningClient{}
+		p := Config{RegistryScanning: test.spec}
+
+		err := p.reconcileRegistryScanning(svc)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.spec, err)
+		}
+
+		if (svc.updated != nil) != test.updated {
+			t.Errorf("%v is not equal to %v for %s", svc.updated != nil, test.updated, test.spec)
+		}
+	}
+}
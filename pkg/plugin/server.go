@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"log"
+	"os"
+)
+
+// bazel_server values
+const (
+	bazelServerWarm     = "warm"
+	bazelServerShutdown = "shutdown"
+)
+
+// startup options passed before the bazel command
+func (p *Config) startupArgs() []string {
+	// the bazel service sets its own startup options
+	if p.BazelServiceUrl != "" {
+		return nil
+	}
+
+	var args []string
+
+	if p.Bazelrc != "" {
+		args = append(args, joinFlag("--bazelrc", p.Bazelrc))
+	}
+
+	if heap := p.hostJvmMaxHeap(); heap != "" {
+		args = append(args, "--host_jvm_args=-Xmx"+heap)
+	}
+
+	// long-lived agents can keep the server and its analysis cache warm between builds
+	if p.MaxIdleSecs != "" {
+		args = append(args, joinFlag("--max_idle_secs", p.MaxIdleSecs))
+	}
+
+	return args
+}
+
+// shut the bazel server down to reclaim its memory once the step is done
+func (p *Config) shutdownBazel(ctx context.Context, runner Runner) error {
+	args := append(p.startupArgs(), "shutdown")
+	return runner.Run(ctx, "bazel", args, append(os.Environ(), p.environ()...))
+}
+
+// whether the bazel server is shut down after the step
+func (p *Config) shutdownServer() bool {
+	return p.BazelServer == bazelServerShutdown && p.runsBazel()
+}
+
+// shut the server down when bazel_server is shutdown, logging failures without failing the step
+func (p *Config) stopServer(ctx context.Context, o *options, res *result) {
+	if !p.shutdownServer() {
+		return
+	}
+
+	err := res.time("bazel_shutdown", func() error {
+		return p.shutdownBazel(ctx, o.runner)
+	})
+	if err != nil {
+		log.Printf("could not shut down the bazel server: %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBazelServer(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	tests := []struct {
+		server string
+		err    error
+		want   [][]string
+	}{
+		{
+			server: bazelServerWarm,
+			want:   [][]string{{"--bazelrc=.bazelrc.ci", "--max_idle_secs=60", "run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"}},
+		},
+		{
+			server: bazelServerShutdown,
+			want: [][]string{
+				{"--bazelrc=.bazelrc.ci", "--max_idle_secs=60", "run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"},
+				{"--bazelrc=.bazelrc.ci", "--max_idle_secs=60", "shutdown"},
+			},
+		},
+		// failed builds shut the server down as well
+		{
+			server: bazelServerShutdown,
+			err:    errors.New("exit status 1"),
+			want: [][]string{
+				{"--bazelrc=.bazelrc.ci", "--max_idle_secs=60", "run", "--build_event_json_file", "--experimental_collect_system_network_usage", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "//:push"},
+				{"--bazelrc=.bazelrc.ci", "--max_idle_secs=60", "shutdown"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cfg := Config{
+			Target:      "//:push",
+			Registry:    "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+			Repository:  "repository",
+			Bazelrc:     ".bazelrc.ci",
+			MaxIdleSecs: "60",
+			BazelServer: test.server,
+		}
+		runner := &recordingRunner{err: test.err}
+
+		err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+		if (err != nil) != (test.err != nil) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got [][]string
+		for _, call := range runner.calls {
+			got = append(got, call.args)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+
+	cfg := Config{Target: "//:push", Registry: "registry", BazelServer: "paused"}
+	if cfg.validate() == nil {
+		t.Errorf("unsupported bazel server option should have failed")
+	}
+}
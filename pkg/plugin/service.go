@@ -0,0 +1,458 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// address the service listens on when serve_address is not set
+const defaultServeAddress = ":8080"
+
+// how long steps wait for the service container to start listening
+var (
+	servicePollInterval = time.Second
+	serviceStartTimeout = 2 * time.Minute
+)
+
+// commands the plugin runs itself, allowed by the service along with the allowed commands
+var serviceCommands = []string{"fetch", "sync", "query"}
+
+// variables of the steps passed to the commands of the service, which otherwise run with
+// the environment of the service
+var (
+	serviceEnvPrefixes = []string{"DRONE_", "CI_", "AWS_"}
+	serviceEnvNames    = []string{"GIT_TERMINAL_PROMPT", "GIT_ASKPASS", "GIT_ASKPASS_USERNAME", "GIT_ASKPASS_PASSWORD", "SSH_AUTH_SOCK"}
+)
+
+// variables never passed to the service, changing how bazel itself is loaded or run
+var serviceDeniedEnvPrefixes = []string{"LD_", "BAZELISK_"}
+
+// flags naming variables read from the client environment, e.g. --test_env=NAME
+var serviceEnvFlags = []string{"--test_env", "--action_env", "--repo_env"}
+
+// bazel command run by the service for a build step
+type serviceRequest struct {
+	Args []string `json:"args"`
+	Env  []string `json:"env"`
+	Dir  string   `json:"dir"`
+}
+
+// line of the streamed response, output of the command until the final line with its exit code
+type serviceFrame struct {
+	Stream   string `json:"stream,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// exit code of a bazel command run by the service
+type serviceExitError struct {
+	code int
+}
+
+func (e serviceExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e serviceExitError) ExitCode() int {
+	return e.code
+}
+
+// writer sending output as frames of a stream
+type frameWriter struct {
+	mu     *sync.Mutex
+	enc    *json.Encoder
+	flush  func()
+	stream string
+}
+
+func (w frameWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.enc.Encode(serviceFrame{Stream: w.stream, Data: p})
+	if err != nil {
+		return 0, err
+	}
+	w.flush()
+
+	return len(p), nil
+}
+
+// the command of bazel arguments, following the startup options
+func bazelCommand(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+
+	return ""
+}
+
+// startup option or denied flag of the arguments of a step. startup options are set by
+// the service, and the workspace status command of the step is only run from the
+// workspace, the denied flags are otherwise rejected as in command_args
+func serviceDeniedArg(args []string, wd string) string {
+	command := false
+	for _, arg := range args {
+		if arg == "--" {
+			// arguments of the target
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			command = true
+			continue
+		}
+		if !command {
+			return arg
+		}
+
+		name, value, _ := strings.Cut(arg, "=")
+		if name == "--workspace_status_command" && inWorkspace(wd, value) {
+			continue
+		}
+		if flag := deniedCommandFlag(arg); flag != "" {
+			return flag
+		}
+	}
+
+	return ""
+}
+
+// whether the path is in the workspace directory
+func inWorkspace(wd, path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// whether the service runs the command for the steps
+func (p *Config) serviceCommandAllowed(command string) bool {
+	for _, c := range serviceCommands {
+		if c == command {
+			return true
+		}
+	}
+
+	return p.commandAllowed(command)
+}
+
+// environment of a command run by the service, its own environment and the allowed
+// variables of the step, including those named by the env flags of the command
+func serviceEnv(args []string, env []string) []string {
+	allowed := map[string]bool{}
+	for _, name := range serviceEnvNames {
+		allowed[name] = true
+	}
+	for _, arg := range args {
+		for _, flag := range serviceEnvFlags {
+			name := strings.TrimPrefix(arg, flag+"=")
+			if name != arg && !strings.Contains(name, "=") {
+				allowed[name] = true
+			}
+		}
+	}
+
+	result := os.Environ()
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if serviceEnvAllowed(name, allowed) {
+			result = append(result, kv)
+		}
+	}
+
+	return result
+}
+
+func serviceEnvAllowed(name string, allowed map[string]bool) bool {
+	for _, prefix := range serviceDeniedEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+
+	if allowed[name] {
+		return true
+	}
+
+	for _, prefix := range serviceEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handler running the bazel commands of build steps one at a time, keeping the bazel server
+// of the service and its analysis cache warm between the steps. Steps authenticate with
+// service_token
+func (p *Config) serviceHandler(runner outputRunner) http.Handler {
+	var running sync.Mutex
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if p.ServiceToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.ServiceToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req serviceRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if command := bazelCommand(req.Args); !p.serviceCommandAllowed(command) {
+			http.Error(w, fmt.Sprintf("command is not allowed: %s", command), http.StatusForbidden)
+			return
+		}
+
+		// steps and the service share the workspace at the same path
+		wd, _ := os.Getwd()
+		if req.Dir != "" && req.Dir != wd {
+			http.Error(w, fmt.Sprintf("workspace %s is not the working directory of the service %s", req.Dir, wd), http.StatusBadRequest)
+			return
+		}
+
+		if arg := serviceDeniedArg(req.Args, wd); arg != "" {
+			http.Error(w, fmt.Sprintf("flag is not allowed: %s", arg), http.StatusForbidden)
+			return
+		}
+
+		running.Lock()
+		defer running.Unlock()
+
+		// the startup options of the service keep its server from restarting
+		args := append(p.startupArgs(), req.Args...)
+		log.Printf("+ bazel %s", strings.Join(args, " "))
+
+		flush := func() {}
+		if f, ok := w.(http.Flusher); ok {
+			flush = f.Flush
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		var mu sync.Mutex
+		enc := json.NewEncoder(w)
+		stdout := frameWriter{mu: &mu, enc: enc, flush: flush, stream: "stdout"}
+		stderr := frameWriter{mu: &mu, enc: enc, flush: flush, stream: "stderr"}
+
+		// a step going away cancels its command
+		err = runner.RunOutput(r.Context(), "bazel", args, serviceEnv(req.Args, req.Env), stdout, stderr)
+
+		frame := serviceFrame{}
+		var coder exitCoder
+		if errors.As(err, &coder) {
+			code := coder.ExitCode()
+			frame.ExitCode = &code
+		} else if err != nil {
+			frame.Error = err.Error()
+		} else {
+			code := 0
+			frame.ExitCode = &code
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(frame)
+	})
+
+	return mux
+}
+
+// settings of a step changing the service container rather than the bazel command
+func (p *Config) validateService() error {
+	switch {
+	case p.BazelServer == bazelServerShutdown:
+		return fmt.Errorf("bazel_server: shutdown would stop the server of the bazel service")
+	case p.Netrc != "":
+		return fmt.Errorf("netrc is written to the step container and can not be used with bazel_service_url")
+	case len(p.ExtraHosts) > 0:
+		return fmt.Errorf("extra_hosts are added to the step container and can not be used with bazel_service_url")
+	}
+
+	return nil
+}
+
+// private directory in the workspace shared by the step and the bazel service
+func workspaceTempDir() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return os.MkdirTemp(wd, ".bazel-ecr-*")
+}
+
+// run as a service container of the pipeline until it is stopped
+func (p *Config) serve(ctx context.Context, runner Runner) error {
+	r, ok := runner.(outputRunner)
+	if !ok {
+		return fmt.Errorf("serve mode requires a runner returning the output of commands")
+	}
+
+	addr := p.ServeAddress
+	if addr == "" {
+		addr = defaultServeAddress
+	}
+
+	server := &http.Server{Addr: addr, Handler: p.serviceHandler(r)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("serving bazel on %s", addr)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// runner sending bazel commands to the bazel service, other commands are run locally
+type serviceRunner struct {
+	url    string
+	token  string
+	local  Runner
+	client *http.Client
+
+	// parallel matrix entries wait for the service together
+	mu    sync.Mutex
+	ready bool
+}
+
+// serviceRunner constructor
+func newServiceRunner(url, token string, local Runner) *serviceRunner {
+	return &serviceRunner{url: strings.TrimSuffix(url, "/"), token: token, local: local, client: &http.Client{}}
+}
+
+func (r *serviceRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	return r.RunOutput(ctx, name, args, env, os.Stdout, os.Stderr)
+}
+
+func (r *serviceRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	if name != "bazel" {
+		if local, ok := r.local.(outputRunner); ok {
+			return local.RunOutput(ctx, name, args, env, stdout, stderr)
+		}
+
+		return r.local.Run(ctx, name, args, env)
+	}
+
+	err := r.waitReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(serviceRequest{Args: args, Env: env, Dir: dir})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+"/run", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach the bazel service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("bazel service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var frame serviceFrame
+		err = dec.Decode(&frame)
+		if err != nil {
+			return fmt.Errorf("bazel service response ended without an exit code: %w", err)
+		}
+
+		switch {
+		case frame.Error != "":
+			return fmt.Errorf("bazel service: %s", frame.Error)
+		case frame.ExitCode != nil:
+			if *frame.ExitCode != 0 {
+				return serviceExitError{code: *frame.ExitCode}
+			}
+			return nil
+		case frame.Stream == "stderr":
+			stderr.Write(frame.Data)
+		default:
+			stdout.Write(frame.Data)
+		}
+	}
+}
+
+// wait for the service container to listen, it is started along with the first steps
+func (r *serviceRunner) waitReady(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ready {
+		return nil
+	}
+
+	deadline := time.Now().Add(serviceStartTimeout)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/healthz", nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := r.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				r.ready = true
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("bazel service %s did not start within %s", r.url, serviceStartTimeout)
+		}
+		time.Sleep(servicePollInterval)
+	}
+}
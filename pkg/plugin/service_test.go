@@ -0,0 +1,256 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// prints output and fails with the configured error
+type scriptedRunner struct {
+	recordingRunner
+}
+
+func (r *scriptedRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stdout, "INFO: Build completed successfully")
+	fmt.Fprintln(stderr, "WARNING: cache miss")
+	return r.Run(ctx, name, args, env)
+}
+
+func TestServiceRunner(t *testing.T) {
+	p := Config{ServiceToken: "token", MaxIdleSecs: "3600"}
+
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{},
+		{err: exec.Command("sh", "-c", "exit 137").Run(), code: 137},
+	}
+
+	for _, test := range tests {
+		service := &scriptedRunner{recordingRunner{err: test.err}}
+		server := httptest.NewServer(p.serviceHandler(service))
+
+		local := &recordingRunner{}
+		runner := newServiceRunner(server.URL+"/", "token", local)
+
+		var stdout, stderr bytes.Buffer
+		err := runner.RunOutput(context.Background(), "bazel", []string{"build", "//app:image"}, []string{"DRONE_ECR_TAG=v1", "LD_PRELOAD=/tmp/evil.so"}, &stdout, &stderr)
+		server.Close()
+
+		var coder exitCoder
+		if test.code == 0 && err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if test.code != 0 && (!errors.As(err, &coder) || coder.ExitCode() != test.code) {
+			t.Errorf("got %v, want exit code %d", err, test.code)
+		}
+
+		if stdout.String() != "INFO: Build completed successfully\n" || stderr.String() != "WARNING: cache miss\n" {
+			t.Errorf("unexpected output %q %q", stdout.String(), stderr.String())
+		}
+
+		call := service.calls[0]
+		// the service passes its own startup options
+		if !reflect.DeepEqual(call.args, []string{"--max_idle_secs=3600", "build", "//app:image"}) {
+			t.Errorf("unexpected command %v", call.args)
+		}
+		if tag, _ := lookupEnv(call.env, "DRONE_ECR_TAG"); tag != "v1" {
+			t.Errorf("DRONE_ECR_TAG was not passed to the service")
+		}
+		if _, ok := lookupEnv(call.env, "LD_PRELOAD"); ok {
+			t.Errorf("LD_PRELOAD was passed to the service")
+		}
+		if len(local.calls) != 0 {
+			t.Errorf("bazel was run locally")
+		}
+	}
+}
+
+func TestServiceRunnerLocal(t *testing.T) {
+	local := &recordingRunner{}
+	runner := newServiceRunner("http://bazel:8080", "token", local)
+
+	// other commands do not need the service
+	err := runner.Run(context.Background(), "sh", []string{"-c", "true"}, nil)
+	if err != nil || len(local.calls) != 1 {
+		t.Errorf("command was not run locally: %v", err)
+	}
+}
+
+func TestServiceHandlerRejected(t *testing.T) {
+	p := Config{ServiceToken: "token"}
+	server := httptest.NewServer(p.serviceHandler(&scriptedRunner{}))
+	defer server.Close()
+
+	tests := []struct {
+		token string
+		body  string
+		code  int
+	}{
+		{body: `{"args": ["build"]}`, code: http.StatusUnauthorized},
+		{token: "guess", body: `{"args": ["build"]}`, code: http.StatusUnauthorized},
+		// only the allowed commands and those of the plugin are run
+		{token: "token", body: `{"args": ["--bazelrc=.bazelrc", "clean", "--expunge"]}`, code: http.StatusForbidden},
+		// startup options are set by the service
+		{token: "token", body: `{"args": ["--host_jvm_args=-javaagent:/tmp/agent.jar", "build"]}`, code: http.StatusForbidden},
+		// flags running programs are denied like in command_args
+		{token: "token", body: `{"args": ["run", "--run_under=/tmp/evil.sh", "//app:push"]}`, code: http.StatusForbidden},
+		{token: "token", body: `{"args": ["build", "--workspace_status_command=/tmp/evil.sh", "//app:push"]}`, code: http.StatusForbidden},
+		{token: "token", body: `{"args": ["build", "--workspace_status_command=../evil.sh", "//app:push"]}`, code: http.StatusForbidden},
+		// a workspace at another path can not be built by the service
+		{token: "token", body: `{"args": ["build"], "dir": "/elsewhere"}`, code: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/run", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: got %s, want %d", test.body, resp.Status, test.code)
+		}
+	}
+}
+
+// records the arguments of the service with the paths of the files handed to bazel
+type rawArgsRunner struct {
+	scriptedRunner
+	args [][]string
+}
+
+func (r *rawArgsRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	r.args = append(r.args, args)
+	return r.scriptedRunner.RunOutput(ctx, name, args, env, stdout, stderr)
+}
+
+func TestRunService(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "pull_request")
+
+	p := Config{ServiceToken: "token"}
+	service := &rawArgsRunner{}
+	server := httptest.NewServer(p.serviceHandler(service))
+	defer server.Close()
+
+	cfg := Config{
+		Target:          "//app:push",
+		Registry:        "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:      "repository",
+		WorkspaceStatus: true,
+		MaxIdleSecs:     "60",
+		BazelServiceUrl: server.URL,
+		ServiceToken:    "token",
+	}
+
+	err := Run(context.Background(), cfg, WithRunner(&recordingRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	args := service.args[0]
+	if args[0] != "build" {
+		t.Errorf("startup options of the step were sent to the service: %v", args)
+	}
+
+	// files handed to bazel are written to the shared workspace
+	want := "--workspace_status_command=" + filepath.Join(wd, ".bazel-ecr-")
+	found := false
+	for _, arg := range args {
+		found = found || strings.HasPrefix(arg, want)
+	}
+	if !found {
+		t.Errorf("status script is not in the workspace: %v", args)
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(wd, ".bazel-ecr-*"))
+	if len(dirs) != 0 {
+		t.Errorf("workspace files were not removed: %v", dirs)
+	}
+}
+
+func TestServiceDeniedArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"build", "--config=ci", "//app:push"}},
+		{args: []string{"build", "--workspace_status_command=/workspace/.bazel-ecr-1/status.sh", "//app:push"}},
+		{args: []string{"build", "--workspace_status_command=tools/status.sh", "//app:push"}},
+		{args: []string{"run", "//app:push", "--", "--run_under=x"}},
+		{args: []string{"--output_base=/tmp", "build"}, want: "--output_base=/tmp"},
+		{args: []string{"build", "--workspace_status_command=/tmp/status.sh"}, want: "--workspace_status_command"},
+		{args: []string{"build", "--workspace_status_command=../status.sh"}, want: "--workspace_status_command"},
+		{args: []string{"test", "--run_under=gdb", "//..."}, want: "--run_under"},
+		{args: []string{"build", "--credential_helper=/tmp/helper"}, want: "--credential_helper"},
+	}
+
+	for _, test := range tests {
+		if got := serviceDeniedArg(test.args, "/workspace"); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestServiceRunnerParallel(t *testing.T) {
+	p := Config{ServiceToken: "token"}
+	server := httptest.NewServer(p.serviceHandler(&scriptedRunner{}))
+	defer server.Close()
+
+	runner := newServiceRunner(server.URL, "token", &recordingRunner{})
+
+	// matrix entries share the runner
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- runner.RunOutput(context.Background(), "bazel", []string{"build", "//app:image"}, nil, io.Discard, io.Discard)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestServiceEnv(t *testing.T) {
+	args := []string{"test", "--test_env=API_KEY", "--test_env=MODE=ci", "--test_env=BAZELISK_BASE_URL", "//..."}
+	env := []string{"DRONE_BRANCH=main", "AWS_REGION=us-east-1", "API_KEY=key", "HOME=/root/step", "BAZELISK_BASE_URL=http://evil", "LD_PRELOAD=/tmp/evil.so"}
+
+	got := serviceEnv(args, env)
+	for _, kv := range []string{"DRONE_BRANCH=main", "AWS_REGION=us-east-1", "API_KEY=key"} {
+		name, want, _ := strings.Cut(kv, "=")
+		if value, _ := lookupEnv(got, name); value != want {
+			t.Errorf("%s: got %q, want %q", name, value, want)
+		}
+	}
+
+	for _, kv := range []string{"HOME=/root/step", "BAZELISK_BASE_URL=http://evil", "LD_PRELOAD=/tmp/evil.so"} {
+		for _, got := range got {
+			if got == kv {
+				t.Errorf("%s was passed to the service", kv)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// prefixes of AWS KMS key URIs and AWS Signer signing profile ARNs
+const (
+	awsKMSScheme     = "awskms://"
+	awsSignerProfile = "arn:aws:signer:"
+)
+
+// tools signing the pushed image
+const (
+	signerCosign   = "cosign"
+	signerNotation = "notation"
+)
+
+// sign the pushed image by digest with sign_key
+func (p *Config) signImage(ctx context.Context, runner Runner, svc ecriface.ECRAPI) error {
+	digest, err := p.imageDigest(svc)
+	if err != nil {
+		return err
+	}
+
+	env, err := p.signEnv()
+	if err != nil {
+		return err
+	}
+
+	image := fmt.Sprintf("%s/%s@%s", p.Registry, p.Repository, digest)
+	name, args := p.signCommand(image)
+	err = runner.Run(ctx, name, args, env)
+	if err != nil {
+		return fmt.Errorf("could not sign %s: %w", image, err)
+	}
+
+	log.Printf("signed %s", image)
+	return nil
+}
+
+// command signing the image with cosign, or with notation and its signing plugin
+func (p *Config) signCommand(image string) (string, []string) {
+	if p.Signer != signerNotation {
+		return signerCosign, []string{"sign", "--key", p.SignKey, "--yes", image}
+	}
+
+	args := []string{"sign", "--id", p.SignKey}
+	if p.SignPlugin != "" {
+		args = append(args, "--plugin", p.SignPlugin)
+	}
+	for _, config := range p.SignPluginConfig {
+		args = append(args, "--plugin-config", strings.TrimSpace(config))
+	}
+
+	return signerNotation, append(args, image)
+}
+
+// environment of the signing tool, AWS KMS keys and AWS Signer profiles are used with the
+// plugin credentials, or those of the assumed role, in the region of the registry unless
+// the key ARN names another region
+func (p *Config) signEnv() ([]string, error) {
+	env := append(os.Environ(), p.environ()...)
+
+	if !strings.HasPrefix(p.SignKey, awsKMSScheme) && !strings.HasPrefix(p.SignKey, awsSignerProfile) {
+		return env, nil
+	}
+
+	region, err := p.region()
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, "AWS_REGION="+region)
+
+	return env, nil
+}
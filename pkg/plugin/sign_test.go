@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSignImage(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+	registry := "0123456789.dkr.ecr.us-east-1.amazonaws.com"
+
+	tests := []struct {
+		key    string
+		region bool
+	}{
+		{key: "awskms:///alias/image-signing", region: true},
+		{key: "cosign.key"},
+	}
+
+	for _, test := range tests {
+		cfg := Config{Target: "//:push", Registry: registry, Repository: "repository", Tag: "v1", SignKey: test.key}
+		runner := &recordingRunner{}
+
+		err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(runner.calls) != 2 {
+			t.Fatalf("expected a build and a signature, got %+v", runner.calls)
+		}
+
+		call := runner.calls[1]
+		want := []string{"sign", "--key", test.key, "--yes", registry + "/repository@" + testDigest}
+		if call.name != "cosign" || !reflect.DeepEqual(want, call.args) {
+			t.Errorf("unexpected command: %s %v", call.name, call.args)
+		}
+
+		if region, _ := lookupEnv(call.env, "AWS_REGION"); test.region && region != "us-east-1" {
+			t.Errorf("expected the registry region for KMS keys, got %q", region)
+		}
+	}
+}
+
+func TestSignCommand(t *testing.T) {
+	image := "0123456789.dkr.ecr.us-east-1.amazonaws.com/repository@" + testDigest
+	profile := "arn:aws:signer:us-east-1:0123456789:/signing-profiles/images"
+
+	tests := []struct {
+		plugin Config
+		name   string
+		args   []string
+	}{
+		{
+			plugin: Config{SignKey: "cosign.key"},
+			name:   "cosign",
+			args:   []string{"sign", "--key", "cosign.key", "--yes", image},
+		},
+		{
+			plugin: Config{Signer: signerNotation, SignKey: profile, SignPlugin: "com.amazonaws.signer.notation.plugin"},
+			name:   "notation",
+			args:   []string{"sign", "--id", profile, "--plugin", "com.amazonaws.signer.notation.plugin", image},
+		},
+		{
+			plugin: Config{Signer: signerNotation, SignKey: "release", SignPluginConfig: []string{"vault_addr=https://vault", " mount=transit"}},
+			name:   "notation",
+			args:   []string{"sign", "--id", "release", "--plugin-config", "vault_addr=https://vault", "--plugin-config", "mount=transit", image},
+		},
+	}
+
+	for _, test := range tests {
+		name, args := test.plugin.signCommand(image)
+		if name != test.name || !reflect.DeepEqual(test.args, args) {
+			t.Errorf("unexpected command: %s %v", name, args)
+		}
+	}
+
+	p := Config{Target: "//:push", Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Signer: "gpg"}
+	if p.validate() == nil {
+		t.Errorf("unsupported signer should have failed")
+	}
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// fail, or only warn with max_image_size_warn, when the pushed image exceeds max_image_size
+func (p *Config) checkImageSize(svc ecriface.ECRAPI) error {
+	budget, err := parseSize(p.MaxImageSize)
+	if err != nil {
+		return err
+	}
+
+	detail, err := p.describeImage(svc, p.imageTag())
+	if err != nil {
+		return err
+	}
+
+	size := aws.Int64Value(detail.ImageSizeInBytes)
+	if size <= budget {
+		log.Printf("image size %d bytes is within the budget of %s", size, p.MaxImageSize)
+		return nil
+	}
+
+	msg := fmt.Sprintf("image %s:%s is %d bytes, exceeding the budget of %s", p.Repository, p.imageTag(), size, p.MaxImageSize)
+	if p.MaxImageSizeWarn {
+		log.Printf("warning: %s", msg)
+		return nil
+	}
+
+	return errors.New(msg)
+}
@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// reports a 100MiB image
+type mockSizeClient struct {
+	mockECRClient
+}
+
+func (m *mockSizeClient) DescribeImages(input *ecr.DescribeImagesInput) (*ecr.DescribeImagesOutput, error) {
+	return &ecr.DescribeImagesOutput{
+		ImageDetails: []*ecr.ImageDetail{
+			{ImageDigest: aws.String(testDigest), ImageSizeInBytes: aws.Int64(100 << 20)},
+		},
+	}, nil
+}
+
+func TestCheckImageSize(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		err    bool
+	}{
+		{plugin: Config{MaxImageSize: "200MiB"}},
+		{plugin: Config{MaxImageSize: "100MiB"}},
+		{plugin: Config{MaxImageSize: "50MiB"}, err: true},
+		{plugin: Config{MaxImageSize: "50MiB", MaxImageSizeWarn: true}},
+		{plugin: Config{MaxImageSize: "large"}, err: true},
+	}
+
+	for _, test := range tests {
+		test.plugin.Repository = "repository"
+
+		err := test.plugin.checkImageSize(&mockSizeClient{})
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.plugin.MaxImageSize, err)
+		}
+	}
+}
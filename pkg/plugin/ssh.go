@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// pid printed by ssh-agent, e.g. "SSH_AGENT_PID=1234; export SSH_AGENT_PID;"
+var agentPidPattern = regexp.MustCompile(`SSH_AGENT_PID=(\d+)`)
+
+// start an ssh agent holding ssh_key for external dependencies fetched over ssh,
+// returning a function stopping the agent once the build is done
+func (p *Config) startSSHAgent(ctx context.Context, runner Runner) (func(), error) {
+	r, ok := runner.(outputRunner)
+	if !ok {
+		return nil, fmt.Errorf("ssh_key requires a runner returning the output of commands")
+	}
+
+	// bazel and the hook commands only reach the key through the agent
+	os.Unsetenv("PLUGIN_SSH_KEY")
+
+	dir, err := os.MkdirTemp(p.tempDir, "ssh-*")
+	if err != nil {
+		return nil, err
+	}
+	sock := filepath.Join(dir, "agent.sock")
+
+	var stdout bytes.Buffer
+	err = r.RunOutput(ctx, "ssh-agent", []string{"-a", sock}, os.Environ(), &stdout, os.Stderr)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("could not start the ssh agent: %w", err)
+	}
+	match := agentPidPattern.FindStringSubmatch(stdout.String())
+	if match == nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("could not read the pid of the ssh agent from %q", stdout.String())
+	}
+	agentEnv := append(os.Environ(), "SSH_AUTH_SOCK="+sock, "SSH_AGENT_PID="+match[1])
+
+	stop := func() {
+		err := runner.Run(context.Background(), "ssh-agent", []string{"-k"}, agentEnv)
+		if err != nil {
+			log.Printf("could not stop the ssh agent: %s", err)
+		}
+		os.RemoveAll(dir)
+	}
+
+	err = p.addSSHKey(ctx, runner, dir, agentEnv)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	p.sshEnv = []string{"SSH_AUTH_SOCK=" + sock}
+
+	if p.SshKnownHosts != "" {
+		knownHosts := filepath.Join(dir, "known_hosts")
+		err = os.WriteFile(knownHosts, []byte(p.SshKnownHosts), 0600)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		p.sshEnv = append(p.sshEnv, "GIT_SSH_COMMAND=ssh -o UserKnownHostsFile="+knownHosts)
+	}
+
+	return stop, nil
+}
+
+// add the key to the agent, it is only on disk while it is added
+func (p *Config) addSSHKey(ctx context.Context, runner Runner, dir string, env []string) error {
+	key := filepath.Join(dir, "key")
+	err := os.WriteFile(key, []byte(p.SshKey), 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(key)
+
+	err = runner.Run(ctx, "ssh-add", []string{key}, env)
+	if err != nil {
+		return fmt.Errorf("could not add the ssh key to the agent: %w", err)
+	}
+
+	return nil
+}
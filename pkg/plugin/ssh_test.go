@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+// prints the output of ssh-agent and records the key added by ssh-add
+type agentRunner struct {
+	recordingRunner
+	key string
+}
+
+func (r *agentRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	if name == "ssh-add" {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		r.key = string(data)
+	}
+
+	return r.recordingRunner.Run(ctx, name, args, env)
+}
+
+func (r *agentRunner) RunOutput(ctx context.Context, name string, args []string, env []string, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stdout, "SSH_AUTH_SOCK=/tmp/agent.sock; export SSH_AUTH_SOCK;\nSSH_AGENT_PID=42; export SSH_AGENT_PID;")
+	return r.Run(ctx, name, args, env)
+}
+
+func TestStartSSHAgent(t *testing.T) {
+	t.Setenv("PLUGIN_SSH_KEY", "key")
+
+	p := Config{SshKey: "key", SshKnownHosts: "github.com ssh-ed25519 AAAA"}
+	runner := &agentRunner{}
+
+	stop, err := p.startSSHAgent(context.Background(), runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runner.key != "key" {
+		t.Errorf("added key %q, want %q", runner.key, "key")
+	}
+	if _, err := os.Stat(runner.calls[1].args[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("key file was not removed: %v", err)
+	}
+	if _, ok := os.LookupEnv("PLUGIN_SSH_KEY"); ok {
+		t.Error("key was not scrubbed from the environment")
+	}
+
+	sock, _ := lookupEnv(p.environ(), "SSH_AUTH_SOCK")
+	if !strings.HasSuffix(sock, "agent.sock") {
+		t.Errorf("unexpected agent socket %q", sock)
+	}
+	if cmd, _ := lookupEnv(p.environ(), "GIT_SSH_COMMAND"); !strings.Contains(cmd, "UserKnownHostsFile=") {
+		t.Errorf("unexpected ssh command %q", cmd)
+	}
+
+	stop()
+
+	last := runner.calls[len(runner.calls)-1]
+	if pid, _ := lookupEnv(last.env, "SSH_AGENT_PID"); strings.Join(last.args, " ") != "-k" || pid != "42" {
+		t.Errorf("agent was not stopped: %v %s", last.args, pid)
+	}
+}
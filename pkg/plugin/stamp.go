@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// build_timestamp stamping the time of the build instead of a fixed epoch
+const buildTimestampNow = "now"
+
+// layouts of build_timestamp_format, any other format is used as a Go time layout
+var timestampLayouts = map[string]string{
+	"rfc3339": time.RFC3339,
+	"date":    "2006-01-02",
+}
+
+// check that the workspace status script can be run by bazel
+func checkStatusScript(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("workspace status script not found: %w", err)
+	}
+
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("workspace status script is not executable: %s", path)
+	}
+
+	return nil
+}
+
+// flags stamping the build with workspace status keys
+func (p *Config) stampArgs() []string {
+	var args []string
+
+	if p.WorkspaceStatusCmd != "" {
+		args = append(args, joinFlag("--workspace_status_command", p.WorkspaceStatusCmd))
+	} else if p.statusScript != "" {
+		args = append(args, joinFlag("--workspace_status_command", p.statusScript))
+	}
+
+	// ${DRONE_BUILD_NUMBER} and other CI variables are expanded in the label
+	if p.EmbedLabel != "" {
+		args = append(args, joinFlag("--embed_label", p.EmbedLabel))
+	}
+
+	return args
+}
+
+// check that build_timestamp is now or seconds since the epoch
+func checkBuildTimestamp(timestamp string) error {
+	if timestamp == "" || timestamp == buildTimestampNow {
+		return nil
+	}
+
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		return fmt.Errorf("build timestamp must be now or seconds since the epoch: %s", timestamp)
+	}
+
+	return nil
+}
+
+// BUILD_TIMESTAMP status value in seconds since the epoch, as bazel expects, the time of
+// the build or a fixed epoch for reproducible builds, and its build_timestamp_format formatting
+func (p *Config) buildTimestamp(now time.Time) (string, string) {
+	t := now
+	if seconds, err := strconv.ParseInt(p.BuildTimestamp, 10, 64); err == nil {
+		t = time.Unix(seconds, 0)
+	}
+	t = t.UTC()
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	switch p.BuildTimestampFormat {
+	case "", "unix":
+		return timestamp, ""
+	}
+
+	if layout, ok := timestampLayouts[p.BuildTimestampFormat]; ok {
+		return timestamp, t.Format(layout)
+	}
+
+	return timestamp, t.Format(p.BuildTimestampFormat)
+}
+
+// status keys stable by default, keys changing with every build are volatile so that
+// they do not invalidate stamped actions
+var defaultStableStatusKeys = []string{
+	"ECR_REGISTRY", "ECR_ACCOUNT_ID", "ECR_REGION", "ECR_REPOSITORY", "ECR_TAG", "ECR_IMAGE",
+	"COMMIT_SHA", "BRANCH",
+}
+
+// workspace status key and value
+type statusKey struct {
+	name  string
+	value string
+}
+
+// keys written by the generated workspace status script
+func (p *Config) statusKeys(getter buildGetter) []statusKey {
+	var keys []statusKey
+
+	for _, env := range p.environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, "DRONE_ECR_") {
+			keys = append(keys, statusKey{name: strings.TrimPrefix(name, "DRONE_"), value: value})
+		}
+	}
+
+	keys = append(keys,
+		statusKey{name: "COMMIT_SHA", value: getter.ScmRevision()},
+		statusKey{name: "BRANCH", value: getter.ScmBranch()},
+		statusKey{name: "BUILD_NUMBER", value: getter.BuildNumber()},
+		statusKey{name: "BUILD_LINK", value: getter.Uri()},
+	)
+
+	// overrides the timestamp bazel stamps itself
+	if p.BuildTimestamp != "" {
+		timestamp, formatted := p.buildTimestamp(time.Now())
+		keys = append(keys, statusKey{name: "BUILD_TIMESTAMP", value: timestamp})
+		if formatted != "" {
+			keys = append(keys, statusKey{name: "BUILD_TIMESTAMP_FORMATTED", value: formatted})
+		}
+	}
+
+	return keys
+}
+
+// workspace status output, with STABLE_ prefixed keys for the stable status keys
+func (p *Config) workspaceStatus(getter buildGetter) string {
+	stable := p.StableStatusKeys
+	if stable == nil {
+		stable = defaultStableStatusKeys
+	}
+
+	var b strings.Builder
+	for _, key := range p.statusKeys(getter) {
+		if key.value == "" {
+			continue
+		}
+
+		name := key.name
+		if matchAny(stable, name) {
+			name = "STABLE_" + name
+		}
+		fmt.Fprintf(&b, "%s %s\n", name, strings.ReplaceAll(key.value, "\n", " "))
+	}
+
+	return b.String()
+}
+
+// write a workspace status script printing the status keys, returning its path
+func (p *Config) writeStatusScript(getter buildGetter) (string, error) {
+	f, err := os.CreateTemp(p.tempDir, "workspace-status-*.sh")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "#!/bin/sh\ncat <<'EOF'\n%sEOF\n", p.workspaceStatus(getter))
+	if err != nil {
+		return "", err
+	}
+
+	return f.Name(), f.Chmod(0755)
+}
@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckStatusScript(t *testing.T) {
+	dir := t.TempDir()
+
+	script := filepath.Join(dir, "status.sh")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho STABLE_IMAGE $DRONE_ECR_IMAGE\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notExecutable := filepath.Join(dir, "status.txt")
+	err = os.WriteFile(notExecutable, []byte("echo\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		fail bool
+	}{
+		{path: script},
+		{path: notExecutable, fail: true},
+		{path: dir, fail: true},
+		{path: filepath.Join(dir, "missing.sh"), fail: true},
+	}
+
+	for _, test := range tests {
+		err := checkStatusScript(test.path)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+		}
+	}
+}
+
+func TestStampArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{Target: "//:push", WorkspaceStatusCmd: "tools/status.sh"},
+			want:   []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--workspace_status_command=tools/status.sh", "//:push"},
+		},
+		{
+			plugin: Config{Target: "//:push", Command: "build", EmbedLabel: "release-42"},
+			want:   []string{"build", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--embed_label=release-42", "//:push"},
+		},
+		// queries are not stamped
+		{
+			plugin: Config{Target: "//:push", Command: "query", WorkspaceStatusCmd: "tools/status.sh"},
+			want:   []string{"query", "//:push"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.getArgs(newBuildMock())
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
+
+func TestWorkspaceStatus(t *testing.T) {
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", Repository: "repository", Tag: "v1"}
+
+	want := `STABLE_ECR_REGISTRY 0123456789.dkr.ecr.us-east-1.amazonaws.com
+STABLE_ECR_ACCOUNT_ID 0123456789
+STABLE_ECR_REGION us-east-1
+STABLE_ECR_REPOSITORY repository
+STABLE_ECR_TAG v1
+STABLE_ECR_IMAGE 0123456789.dkr.ecr.us-east-1.amazonaws.com/repository:v1
+STABLE_COMMIT_SHA test
+STABLE_BRANCH test
+BUILD_NUMBER test
+BUILD_LINK test
+`
+	got := p.workspaceStatus(newBuildMock())
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// only the listed keys are stable
+	p.StableStatusKeys = []string{"ECR_REPOSITORY", "BUILD_*"}
+	want = `ECR_REGISTRY 0123456789.dkr.ecr.us-east-1.amazonaws.com
+ECR_ACCOUNT_ID 0123456789
+ECR_REGION us-east-1
+STABLE_ECR_REPOSITORY repository
+ECR_TAG v1
+ECR_IMAGE 0123456789.dkr.ecr.us-east-1.amazonaws.com/repository:v1
+COMMIT_SHA test
+BRANCH test
+STABLE_BUILD_NUMBER test
+STABLE_BUILD_LINK test
+`
+	got = p.workspaceStatus(newBuildMock())
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteStatusScript(t *testing.T) {
+	p := Config{Repository: "repository"}
+
+	script, err := p.writeStatusScript(newBuildMock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(script)
+
+	err = checkStatusScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(script).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != p.workspaceStatus(newBuildMock()) {
+		t.Errorf("unexpected status output: %s", out)
+	}
+
+	p.statusScript = script
+	p.Target = "//:push"
+	want := []string{"run", "--platforms=@local_config_platform//:host", "--host_platform=@local_config_platform//:host", "--workspace_status_command=" + script, "//:push"}
+	if got := p.getArgs(newBuildMock()); !reflect.DeepEqual(want, got) {
+		t.Errorf("%v is not equal to %v", want, got)
+	}
+}
+
+func TestBuildTimestamp(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		plugin    Config
+		timestamp string
+		formatted string
+	}{
+		{plugin: Config{BuildTimestamp: "now"}, timestamp: "1714566600"},
+		{plugin: Config{BuildTimestamp: "0"}, timestamp: "0"},
+		{plugin: Config{BuildTimestamp: "now", BuildTimestampFormat: "rfc3339"}, timestamp: "1714566600", formatted: "2024-05-01T12:30:00Z"},
+		{plugin: Config{BuildTimestamp: "315532800", BuildTimestampFormat: "date"}, timestamp: "315532800", formatted: "1980-01-01"},
+		{plugin: Config{BuildTimestamp: "now", BuildTimestampFormat: "20060102.1504"}, timestamp: "1714566600", formatted: "20240501.1230"},
+	}
+
+	for _, test := range tests {
+		timestamp, formatted := test.plugin.buildTimestamp(now)
+		if timestamp != test.timestamp || formatted != test.formatted {
+			t.Errorf("got %q %q, want %q %q", timestamp, formatted, test.timestamp, test.formatted)
+		}
+	}
+
+	for timestamp, valid := range map[string]bool{"": true, "now": true, "0": true, "yesterday": false} {
+		if err := checkBuildTimestamp(timestamp); (err == nil) != valid {
+			t.Errorf("%q: unexpected error: %v", timestamp, err)
+		}
+	}
+
+	p := Config{BuildTimestamp: "0"}
+	if got := p.workspaceStatus(newBuildMock()); !strings.HasSuffix(got, "BUILD_TIMESTAMP 0\n") {
+		t.Errorf("timestamp not in status:\n%s", got)
+	}
+
+	p = Config{BuildTimestamp: "0", BuildTimestampFormat: "date"}
+	if got := p.workspaceStatus(newBuildMock()); !strings.HasSuffix(got, "BUILD_TIMESTAMP 0\nBUILD_TIMESTAMP_FORMATTED 1970-01-01\n") {
+		t.Errorf("formatted timestamp not in status:\n%s", got)
+	}
+}
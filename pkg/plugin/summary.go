@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// machine-readable summary of a plugin run
+type summary struct {
+	Status        string        `json:"status"`
+	Mode          string        `json:"mode"`
+	Duration      float64       `json:"duration"`
+	Phases        []phaseTiming `json:"phases"`
+	BazelExitCode *int          `json:"bazel_exit_code,omitempty"`
+	BuildEvents   *buildEvents  `json:"build_events,omitempty"`
+	OverBudget    float64       `json:"over_budget,omitempty"`
+	Images        []pushedImage `json:"images"`
+	Scans         []scanResult  `json:"scans,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// write the run summary as JSON
+func (p *Config) writeSummary(res *result) error {
+	if p.SummaryFile == "" {
+		return nil
+	}
+
+	s := summary{
+		Status:        res.status(),
+		Mode:          p.phaseName(),
+		Duration:      res.duration.Seconds(),
+		Phases:        res.phases,
+		BazelExitCode: res.exitCode,
+		BuildEvents:   res.events,
+		OverBudget:    res.overBudget.Seconds(),
+		Images:        res.images,
+		Scans:         res.scans,
+	}
+	if res.err != nil {
+		s.Error = res.err.Error()
+	}
+
+	// keep arrays in the output even when nothing was recorded
+	if s.Phases == nil {
+		s.Phases = []phaseTiming{}
+	}
+	if s.Images == nil {
+		s.Images = []pushedImage{}
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.SummaryFile), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.SummaryFile, out, 0644)
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteSummary(t *testing.T) {
+	p := Config{SummaryFile: filepath.Join(t.TempDir(), "summary.json")}
+
+	res := newResult()
+	err := res.time("bazel", func() error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.setExitCode(nil)
+	res.addImage("registry", "repository", "v1", "sha256:abc")
+	res.addScan("repository", "sha256:abc", map[string]int64{"HIGH": 2})
+	res.duration = 3 * time.Second
+
+	err = p.writeSummary(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(p.SummaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got summary
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Status != statusSuccess || got.Mode != modeBazel || got.Duration != 3 {
+		t.Errorf("unexpected summary: %+v", got)
+	}
+
+	if len(got.Phases) != 1 || got.Phases[0].Name != "bazel" {
+		t.Errorf("unexpected phases: %+v", got.Phases)
+	}
+
+	if got.BazelExitCode == nil || *got.BazelExitCode != 0 {
+		t.Errorf("unexpected exit code: %v", got.BazelExitCode)
+	}
+
+	want := []pushedImage{{Registry: "registry", Repository: "repository", Tag: "v1", Digest: "sha256:abc"}}
+	if !reflect.DeepEqual(want, got.Images) {
+		t.Errorf("%+v is not equal to %+v", want, got.Images)
+	}
+
+	scans := []scanResult{{Repository: "repository", Digest: "sha256:abc", SeverityCounts: map[string]int64{"HIGH": 2}}}
+	if !reflect.DeepEqual(scans, got.Scans) {
+		t.Errorf("%+v is not equal to %+v", scans, got.Scans)
+	}
+}
+
+func TestSetExitCode(t *testing.T) {
+	res := newResult()
+	res.setExitCode(exec.Command("sh", "-c", "exit 3").Run())
+	if res.exitCode == nil || *res.exitCode != 3 {
+		t.Errorf("unexpected exit code: %v", res.exitCode)
+	}
+
+	// commands that never started have no exit code
+	res = newResult()
+	res.setExitCode(errors.New("executable file not found"))
+	if res.exitCode != nil {
+		t.Errorf("unexpected exit code: %v", *res.exitCode)
+	}
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// separates the branch pattern from the tags of a tag rule
+const tagRuleSeparator = "=>"
+
+// pick the tags of the first rule whose branch pattern matches the branch
+func matchTagRules(rules []string, branch string) ([]string, error) {
+	for _, rule := range rules {
+		pattern, tags, ok := strings.Cut(rule, tagRuleSeparator)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag rule: %s", rule)
+		}
+
+		pattern = strings.TrimSpace(pattern)
+		matched, err := path.Match(pattern, branch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag rule pattern: %s", pattern)
+		}
+		if !matched {
+			continue
+		}
+
+		fields := strings.Fields(tags)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("tag rule has no tags: %s", rule)
+		}
+
+		return fields, nil
+	}
+
+	return nil, nil
+}
+
+// set the tag from the tag rules, the first tag is pushed and the others are added after the push
+func (p *Config) applyTagRules(getter buildGetter) error {
+	if len(p.TagRules) == 0 || p.Tag != "" {
+		return nil
+	}
+
+	tags, err := matchTagRules(p.TagRules, getter.ScmBranch())
+	if err != nil {
+		return err
+	}
+	// pushing with the default tag would overwrite the tags the rules protect
+	if tags == nil {
+		if p.push(getter) && p.pushesImage() {
+			return fmt.Errorf("no tag rule matches branch %s, set a rule for it or push: false", getter.ScmBranch())
+		}
+
+		log.Printf("no tag rule matches branch %s", getter.ScmBranch())
+		return nil
+	}
+
+	p.Tag = tags[0]
+	p.additionalTags = tags[1:]
+
+	return nil
+}
+
+// compute the tags of the image before it is built
+func (p *Config) applyTags(getter buildGetter) error {
+	err := p.applyTagRules(getter)
+	if err != nil {
+		return err
+	}
+
+	err = p.applySourceTag()
+	if err != nil {
+		return err
+	}
+
+	// tag the image with the build that pushed it, build numbers are only unique per CI repository
+	if p.BuildNumberTag && getter.BuildNumber() != "" {
+		p.additionalTags = append(p.additionalTags, buildNumberTag(getter.Repo(), getter.BuildNumber()))
+	}
+
+	return nil
+}
+
+// characters of CI repository names not allowed in image tags
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// tag linking the image to its build, e.g. build-octocat-hello-world-42
+func buildNumberTag(repo, number string) string {
+	repo = strings.Trim(invalidTagChars.ReplaceAllString(repo, "-"), "-.")
+	if repo == "" {
+		return "build-" + number
+	}
+
+	return "build-" + repo + "-" + number
+}
+
+// add the additional tags to the pushed image without pulling or pushing it again
+func (p *Config) tagImage(svc ecriface.ECRAPI, tags []string) error {
+	result, err := svc.BatchGetImage(&ecr.BatchGetImageInput{
+		RepositoryName: aws.String(p.Repository),
+		ImageIds:       []*ecr.ImageIdentifier{{ImageTag: aws.String(p.imageTag())}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(result.Images) == 0 {
+		return fmt.Errorf("could not find image %s:%s", p.Repository, p.imageTag())
+	}
+	image := result.Images[0]
+
+	// calls are paced to stay under the ECR write TPS quota
+	pace := newPacer(p.retagRate())
+	for _, tag := range tags {
+		err = pace.do(func() error {
+			_, err := svc.PutImage(&ecr.PutImageInput{
+				RepositoryName:         aws.String(p.Repository),
+				ImageManifest:          image.ImageManifest,
+				ImageManifestMediaType: image.ImageManifestMediaType,
+				ImageTag:               aws.String(tag),
+			})
+			return err
+		})
+		if err != nil {
+			aerr, ok := err.(awserr.Error)
+			// the tag already points at the image
+			if ok && aerr.Code() == ecr.ErrCodeImageAlreadyExistsException {
+				continue
+			}
+			// immutable tags can not be moved, but may point at the image from a previous run
+			if ok && aerr.Code() == ecr.ErrCodeImageTagAlreadyExistsException && p.alreadyTagged(svc, tag, aws.StringValue(image.ImageId.ImageDigest)) {
+				continue
+			}
+			return err
+		}
+
+		log.Printf("tagged %s:%s as %s", p.Repository, p.imageTag(), tag)
+	}
+
+	return nil
+}
+
+// check that every tag resolves to the same digest, catching retags racing with another pipeline
+func (p *Config) verifyTags(svc ecriface.ECRAPI, tags []string) error {
+	digests := map[string]string{}
+
+	input := &ecr.ListImagesInput{
+		RepositoryName: aws.String(p.Repository),
+		Filter:         &ecr.ListImagesFilter{TagStatus: aws.String(ecr.TagStatusTagged)},
+	}
+	err := svc.ListImagesPages(input, func(page *ecr.ListImagesOutput, last bool) bool {
+		for _, id := range page.ImageIds {
+			digests[aws.StringValue(id.ImageTag)] = aws.StringValue(id.ImageDigest)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	want := digests[tags[0]]
+	var mismatched []string
+	for _, tag := range tags {
+		digest, ok := digests[tag]
+		if !ok {
+			mismatched = append(mismatched, tag+" is missing")
+		} else if digest != want {
+			mismatched = append(mismatched, fmt.Sprintf("%s points at %s", tag, digest))
+		}
+	}
+
+	if len(mismatched) > 0 {
+		return fmt.Errorf("tags of %s do not resolve to the digest of %s: %s", p.Repository, tags[0], strings.Join(mismatched, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// records the tags put by retagging
+type mockTagClient struct {
+	mockECRClient
+
+	tags []string
+}
+
+func (m *mockTagClient) BatchGetImage(input *ecr.BatchGetImageInput) (*ecr.BatchGetImageOutput, error) {
+	if testFailure == "BatchGetImage" {
+		return nil, errors.New("BatchGetImage")
+	}
+
+	return &ecr.BatchGetImageOutput{
+		Images: []*ecr.Image{
+			{
+				ImageId:                &ecr.ImageIdentifier{ImageDigest: aws.String(testDigest), ImageTag: input.ImageIds[0].ImageTag},
+				ImageManifest:          aws.String("{}"),
+				ImageManifestMediaType: aws.String("application/vnd.oci.image.manifest.v1+json"),
+			},
+		},
+	}, nil
+}
+
+func (m *mockTagClient) PutImage(input *ecr.PutImageInput) (*ecr.PutImageOutput, error) {
+	if testFailure == "PutImageExists" {
+		return nil, awserr.New(ecr.ErrCodeImageAlreadyExistsException, "", errors.New("PutImageExists"))
+	}
+
+	if testFailure == "PutImageTagExists" || testFailure == "PutImageTagMoved" {
+		return nil, awserr.New(ecr.ErrCodeImageTagAlreadyExistsException, "", errors.New("PutImageTagExists"))
+	}
+
+	m.tags = append(m.tags, aws.StringValue(input.ImageTag))
+	return &ecr.PutImageOutput{}, nil
+}
+
+func (m *mockTagClient) DescribeImages(input *ecr.DescribeImagesInput) (*ecr.DescribeImagesOutput, error) {
+	digest := testDigest
+	if testFailure == "PutImageTagMoved" {
+		digest = "sha256:other"
+	}
+
+	return &ecr.DescribeImagesOutput{
+		ImageDetails: []*ecr.ImageDetail{{ImageDigest: aws.String(digest)}},
+	}, nil
+}
+
+func TestMatchTagRules(t *testing.T) {
+	rules := []string{"main=>latest", " release/*=>stable-1.2.0 stable", "feature/*=>"}
+
+	tests := []struct {
+		rules  []string
+		branch string
+		want   []string
+		err    bool
+	}{
+		{rules: rules, branch: "main", want: []string{"latest"}},
+		{rules: rules, branch: "release/1.2", want: []string{"stable-1.2.0", "stable"}},
+		{rules: rules, branch: "release/1.2/hotfix"},
+		{rules: rules, branch: "develop"},
+		{rules: rules, branch: "feature/a", err: true},
+		{rules: []string{"main"}, branch: "main", err: true},
+		{rules: []string{"[=>latest"}, branch: "main", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := matchTagRules(test.rules, test.branch)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.branch, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v is not equal to %v", got, test.want)
+		}
+	}
+}
+
+func TestApplyTagRules(t *testing.T) {
+	noPush := false
+
+	tests := []struct {
+		plugin Config
+		tag    string
+		extra  []string
+		err    bool
+	}{
+		{
+			plugin: Config{TagRules: []string{"test=>latest edge"}},
+			tag:    "latest",
+			extra:  []string{"edge"},
+		},
+		// pushing without a matching rule would overwrite the default tag
+		{
+			plugin: Config{TagRules: []string{"main=>latest"}},
+			err:    true,
+		},
+		{
+			plugin: Config{TagRules: []string{"main=>latest"}, Push: &noPush},
+		},
+		// an explicit tag takes precedence
+		{
+			plugin: Config{Tag: "v1", TagRules: []string{"test=>latest"}},
+			tag:    "v1",
+		},
+	}
+
+	for _, test := range tests {
+		err := test.plugin.applyTagRules(newBuildMock())
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if test.plugin.Tag != test.tag {
+			t.Errorf("%v is not equal to %v", test.plugin.Tag, test.tag)
+		}
+
+		if !reflect.DeepEqual(test.plugin.additionalTags, test.extra) {
+			t.Errorf("%v is not equal to %v", test.plugin.additionalTags, test.extra)
+		}
+	}
+}
+
+func TestBuildNumberTag(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{repo: "octocat/hello-world", want: "build-octocat-hello-world-42"},
+		{repo: "", want: "build-42"},
+	}
+
+	for _, test := range tests {
+		if got := buildNumberTag(test.repo, "42"); got != test.want {
+			t.Errorf("%v is not equal to %v", got, test.want)
+		}
+	}
+}
+
+func TestTagImage(t *testing.T) {
+	tests := []struct {
+		failure string
+		want    []string
+		err     bool
+	}{
+		{want: []string{"stable", "edge"}},
+		{failure: "PutImageExists"},
+		// immutable tag pointing at the image
+		{failure: "PutImageTagExists"},
+		// immutable tag pointing at another image
+		{failure: "PutImageTagMoved", err: true},
+		{failure: "BatchGetImage", err: true},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+		svc := &mockTagClient{}
+		p := Config{Repository: "test", Tag: "v1"}
+
+		err := p.tagImage(svc, []string{"stable", "edge"})
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.failure, err)
+		}
+
+		if !reflect.DeepEqual(svc.tags, test.want) {
+			t.Errorf("%v is not equal to %v", svc.tags, test.want)
+		}
+	}
+	testFailure = ""
+}
+
+func (m *mockTagClient) ListImagesPages(input *ecr.ListImagesInput, fn func(*ecr.ListImagesOutput, bool) bool) error {
+	ids := []*ecr.ImageIdentifier{
+		{ImageTag: aws.String("v1"), ImageDigest: aws.String(testDigest)},
+		{ImageTag: aws.String("stable"), ImageDigest: aws.String(testDigest)},
+	}
+	fn(&ecr.ListImagesOutput{ImageIds: ids[:1]}, false)
+
+	// another pipeline moved the tag
+	if testFailure == "ListImagesRaced" {
+		ids[1].ImageDigest = aws.String("sha256:other")
+	}
+	fn(&ecr.ListImagesOutput{ImageIds: ids[1:]}, true)
+
+	return nil
+}
+
+func TestVerifyTags(t *testing.T) {
+	tests := []struct {
+		failure string
+		tags    []string
+		err     bool
+	}{
+		{tags: []string{"v1", "stable"}},
+		{tags: []string{"v1", "stable"}, failure: "ListImagesRaced", err: true},
+		{tags: []string{"v1", "edge"}, err: true},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+		p := Config{Repository: "test", Tag: "v1"}
+
+		err := p.verifyTags(&mockTagClient{}, test.tags)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %v: %v", test.tags, err)
+		}
+	}
+	testFailure = ""
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// create or update the repository creation template described by creation_template
+func (p *Config) applyCreationTemplate(svc ecriface.ECRAPI) error {
+	template, err := p.creationTemplate()
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.CreateRepositoryCreationTemplate(template)
+	if err == nil {
+		log.Printf("created repository creation template for %s", aws.StringValue(template.Prefix))
+		return nil
+	}
+
+	aerr, ok := err.(awserr.Error)
+	if !ok || aerr.Code() != ecr.ErrCodeTemplateAlreadyExistsException {
+		return err
+	}
+
+	_, err = svc.UpdateRepositoryCreationTemplate(&ecr.UpdateRepositoryCreationTemplateInput{
+		AppliedFor:              template.AppliedFor,
+		CustomRoleArn:           template.CustomRoleArn,
+		Description:             template.Description,
+		EncryptionConfiguration: template.EncryptionConfiguration,
+		ImageTagMutability:      template.ImageTagMutability,
+		LifecyclePolicy:         template.LifecyclePolicy,
+		Prefix:                  template.Prefix,
+		RepositoryPolicy:        template.RepositoryPolicy,
+		ResourceTags:            template.ResourceTags,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("updated repository creation template for %s", aws.StringValue(template.Prefix))
+	return nil
+}
+
+// parse the creation template, which uses the field names of the CreateRepositoryCreationTemplate API
+func (p *Config) creationTemplate() (*ecr.CreateRepositoryCreationTemplateInput, error) {
+	template := &ecr.CreateRepositoryCreationTemplateInput{}
+	err := json.Unmarshal([]byte(p.CreationTemplate), template)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse repository creation template: %w", err)
+	}
+
+	// the repository prefix is the template prefix by default
+	if template.Prefix == nil && p.RepositoryPrefix != "" {
+		template.Prefix = aws.String(p.RepositoryPrefix)
+	}
+	if len(template.AppliedFor) == 0 {
+		template.AppliedFor = aws.StringSlice(ecr.RCTAppliedFor_Values())
+	}
+
+	err = template.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository creation template: %w", err)
+	}
+
+	return template, nil
+}
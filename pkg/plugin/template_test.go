@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// records created and updated creation templates
+type mockTemplateClient struct {
+	mockECRClient
+
+	exists  bool
+	created *ecr.CreateRepositoryCreationTemplateInput
+	updated *ecr.UpdateRepositoryCreationTemplateInput
+}
+
+func (m *mockTemplateClient) CreateRepositoryCreationTemplate(input *ecr.CreateRepositoryCreationTemplateInput) (*ecr.CreateRepositoryCreationTemplateOutput, error) {
+	if m.exists {
+		return nil, awserr.New(ecr.ErrCodeTemplateAlreadyExistsException, "", errors.New("TemplateAlreadyExists"))
+	}
+
+	m.created = input
+	return &ecr.CreateRepositoryCreationTemplateOutput{}, nil
+}
+
+func (m *mockTemplateClient) UpdateRepositoryCreationTemplate(input *ecr.UpdateRepositoryCreationTemplateInput) (*ecr.UpdateRepositoryCreationTemplateOutput, error) {
+	m.updated = input
+	return &ecr.UpdateRepositoryCreationTemplateOutput{}, nil
+}
+
+func TestApplyCreationTemplate(t *testing.T) {
+	spec := `{"imageTagMutability": "IMMUTABLE", "encryptionConfiguration": {"encryptionType": "KMS"}, "resourceTags": [{"Key": "team", "Value": "payments"}]}`
+
+	tests := []struct {
+		plugin Config
+		exists bool
+		err    bool
+	}{
+		{plugin: Config{CreationTemplate: spec, RepositoryPrefix: "team-payments"}},
+		{plugin: Config{CreationTemplate: spec, RepositoryPrefix: "team-payments"}, exists: true},
+		// a prefix is required
+		{plugin: Config{CreationTemplate: spec}, err: true},
+		{plugin: Config{CreationTemplate: "IMMUTABLE"}, err: true},
+	}
+
+	for _, test := range tests {
+		svc := &mockTemplateClient{exists: test.exists}
+
+		err := test.plugin.applyCreationTemplate(svc)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %s: %v", test.plugin.CreationTemplate, err)
+		}
+		if test.err {
+			continue
+		}
+
+		var prefix, mutability string
+		var appliedFor int
+		if test.exists {
+			prefix, mutability, appliedFor = aws.StringValue(svc.updated.Prefix), aws.StringValue(svc.updated.ImageTagMutability), len(svc.updated.AppliedFor)
+		} else {
+			prefix, mutability, appliedFor = aws.StringValue(svc.created.Prefix), aws.StringValue(svc.created.ImageTagMutability), len(svc.created.AppliedFor)
+		}
+
+		if prefix != "team-payments" || mutability != ecr.ImageTagMutabilityImmutable || appliedFor != len(ecr.RCTAppliedFor_Values()) {
+			t.Errorf("unexpected template: %s %s %d", prefix, mutability, appliedFor)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package plugin
+
+import "strings"
+
+// whether the bazel command runs tests
+func isTestCommand(command string) bool {
+	return command == "test" || command == "coverage"
+}
+
+// flags of the test and coverage commands
+func (p *Config) testArgs() []string {
+	var args []string
+
+	// forward the listed variables from the plugin environment into the test sandbox
+	for _, name := range p.TestEnv {
+		args = append(args, joinFlag("--test_env", strings.TrimSpace(name)))
+	}
+
+	if p.TestFilter != "" {
+		args = append(args, joinFlag("--test_filter", p.TestFilter))
+	}
+	if len(p.TestTagFilters) > 0 {
+		args = append(args, joinFlag("--test_tag_filters", joinList(p.TestTagFilters)))
+	}
+
+	if p.FlakyTestAttempts != "" {
+		args = append(args, joinFlag("--flaky_test_attempts", p.FlakyTestAttempts))
+	}
+	if p.TestRunsPerTarget != "" {
+		args = append(args, joinFlag("--runs_per_test", p.TestRunsPerTarget))
+	}
+
+	if p.TestShardingStrategy != "" {
+		args = append(args, joinFlag("--test_sharding_strategy", p.TestShardingStrategy))
+	}
+	if len(p.TestSizeFilters) > 0 {
+		args = append(args, joinFlag("--test_size_filters", joinList(p.TestSizeFilters)))
+	}
+	// a single timeout or one per size, e.g. 60,300,900,3600
+	if len(p.TestTimeout) > 0 {
+		args = append(args, joinFlag("--test_timeout", joinList(p.TestTimeout)))
+	}
+
+	return args
+}
+
+// join list settings that were split by envconfig back into a bazel list flag value
+func joinList(values []string) string {
+	var items []string
+	for _, val := range values {
+		items = append(items, strings.TrimSpace(val))
+	}
+
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTestArgs(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{
+			plugin: Config{},
+		},
+		{
+			plugin: Config{TestFilter: "Smoke.*"},
+			want:   []string{"--test_filter=Smoke.*"},
+		},
+		{
+			plugin: Config{TestTagFilters: []string{"smoke", " -flaky"}},
+			want:   []string{"--test_tag_filters=smoke,-flaky"},
+		},
+		{
+			plugin: Config{FlakyTestAttempts: "3", TestRunsPerTarget: "//app:.*@2"},
+			want:   []string{"--flaky_test_attempts=3", "--runs_per_test=//app:.*@2"},
+		},
+		{
+			plugin: Config{TestShardingStrategy: "explicit", TestSizeFilters: []string{"small", "medium"}, TestTimeout: []string{"60", "300", "900", "3600"}},
+			want:   []string{"--test_sharding_strategy=explicit", "--test_size_filters=small,medium", "--test_timeout=60,300,900,3600"},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.testArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
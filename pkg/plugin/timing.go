@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// print how long each phase of the run took, with the analysis and execution
+// phases of the build when build events were recorded
+func printTimings(w io.Writer, res *result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "phase\tseconds")
+
+	seconds := func(s float64) string {
+		return fmt.Sprintf("%.1f", s)
+	}
+
+	for _, phase := range res.phases {
+		fmt.Fprintf(tw, "%s\t%s\n", phase.Name, seconds(phase.Duration))
+
+		if phase.Name == modeBazel && res.events != nil && (res.events.AnalysisTime > 0 || res.events.ExecutionTime > 0) {
+			fmt.Fprintf(tw, "  analysis\t%s\n", seconds(res.events.AnalysisTime))
+			fmt.Fprintf(tw, "  execution\t%s\n", seconds(res.events.ExecutionTime))
+			if res.events.UploadWait > 0 {
+				fmt.Fprintf(tw, "  upload wait\t%s\n", seconds(res.events.UploadWait))
+			}
+		}
+	}
+
+	fmt.Fprintf(tw, "total\t%s\n", seconds(res.duration.Seconds()))
+	tw.Flush()
+}
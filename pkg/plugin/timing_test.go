@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrintTimings(t *testing.T) {
+	res := &result{
+		duration: 75 * time.Second,
+		phases: []phaseTiming{
+			{Name: "setup", Duration: 0.25},
+			{Name: "create_repository", Duration: 1.5},
+			{Name: "bazel", Duration: 70},
+			{Name: "publish", Duration: 3},
+		},
+		events: &buildEvents{AnalysisTime: 12.5, ExecutionTime: 50, UploadWait: 4},
+	}
+
+	var buf bytes.Buffer
+	printTimings(&buf, res)
+
+	want := `phase              seconds
+setup              0.2
+create_repository  1.5
+bazel              70.0
+  analysis         12.5
+  execution        50.0
+  upload wait      4.0
+publish            3.0
+total              75.0
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
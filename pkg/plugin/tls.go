@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// write the client certificate and key to a private directory for bazel to read,
+// returning the directory to remove once bazel has run
+func (p *Config) writeClientCertificate() (string, error) {
+	dir, err := os.MkdirTemp(p.tempDir, "tls-client-*")
+	if err != nil {
+		return "", err
+	}
+
+	p.tlsCertificateFile = filepath.Join(dir, "client.crt")
+	p.tlsKeyFile = filepath.Join(dir, "client.key")
+
+	err = os.WriteFile(p.tlsCertificateFile, []byte(p.TlsClientCertificate), 0600)
+	if err == nil {
+		err = os.WriteFile(p.tlsKeyFile, []byte(p.TlsClientKey), 0600)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// flags authenticating bazel to the BES and remote cache endpoints with the client certificate
+func (p *Config) tlsArgs() []string {
+	if p.tlsCertificateFile == "" {
+		return nil
+	}
+
+	return []string{
+		joinFlag("--tls_client_certificate", p.tlsCertificateFile),
+		joinFlag("--tls_client_key", p.tlsKeyFile),
+	}
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteClientCertificate(t *testing.T) {
+	p := Config{TlsClientCertificate: "certificate", TlsClientKey: "key"}
+
+	dir, err := p.writeClientCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for path, want := range map[string]string{p.tlsCertificateFile: "certificate", p.tlsKeyFile: "key"} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %s", path, info.Mode())
+		}
+
+		data, _ := os.ReadFile(path)
+		if string(data) != want {
+			t.Errorf("got %q, want %q", data, want)
+		}
+	}
+
+	args := p.tlsArgs()
+	want := []string{"--tls_client_certificate=" + filepath.Join(dir, "client.crt"), "--tls_client_key=" + filepath.Join(dir, "client.key")}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", args, want)
+	}
+}
+
+func TestRunClientCertificate(t *testing.T) {
+	testFailure = ""
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:               "//:push",
+		Registry:             "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository:           "repository",
+		TlsClientCertificate: "certificate",
+		TlsClientKey:         "key",
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key string
+	for _, arg := range runner.calls[0].args {
+		if strings.HasPrefix(arg, "--tls_client_key=") {
+			key = strings.TrimPrefix(arg, "--tls_client_key=")
+		}
+	}
+	if key == "" {
+		t.Fatalf("no client key in %v", runner.calls[0].args)
+	}
+
+	// the key does not outlive the build
+	if _, err := os.Stat(key); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("client key %s was not removed: %v", key, err)
+	}
+
+	cfg.TlsClientKey = ""
+	if err := Run(context.Background(), cfg, WithRunner(runner)); err == nil {
+		t.Error("expected error for a certificate without a key")
+	}
+}
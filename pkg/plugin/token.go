@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// auth tokens are refreshed this long before they expire
+const tokenExpiryMargin = 5 * time.Minute
+
+// auth tokens shared by the ECR clients of a run, keyed by the requested registry IDs
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*ecr.GetAuthorizationTokenOutput
+	now    func() time.Time
+}
+
+// tokenCache constructor
+func newTokenCache() *tokenCache {
+	return &tokenCache{tokens: map[string]*ecr.GetAuthorizationTokenOutput{}, now: time.Now}
+}
+
+// ECR client reusing auth tokens until they expire, so that building many targets
+// or adding many tags does not get GetAuthorizationToken throttled
+type cachingClient struct {
+	ecriface.ECRAPI
+
+	cache *tokenCache
+}
+
+// GetAuthorizationToken implements ecriface.ECRAPI
+func (c *cachingClient) GetAuthorizationToken(input *ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	key := strings.Join(aws.StringValueSlice(input.RegistryIds), ",")
+
+	// concurrent matrix entries wait for a single request
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if token, ok := c.cache.tokens[key]; ok && c.cache.valid(token) {
+		return token, nil
+	}
+
+	token, err := c.ECRAPI.GetAuthorizationToken(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cache.valid(token) {
+		c.cache.tokens[key] = token
+	}
+
+	return token, nil
+}
+
+// whether every token is valid for longer than the expiry margin, tokens without an expiry are not cached
+func (c *tokenCache) valid(token *ecr.GetAuthorizationTokenOutput) bool {
+	if len(token.AuthorizationData) == 0 {
+		return false
+	}
+
+	for _, data := range token.AuthorizationData {
+		if data.ExpiresAt == nil || !c.now().Add(tokenExpiryMargin).Before(*data.ExpiresAt) {
+			return false
+		}
+	}
+
+	return true
+}
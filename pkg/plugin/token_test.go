@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// counts auth token requests and returns tokens expiring after the validity
+type mockTokenClient struct {
+	mockECRClient
+
+	validity time.Duration
+	calls    int
+}
+
+func (m *mockTokenClient) GetAuthorizationToken(input *ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	m.calls++
+
+	token, err := m.mockECRClient.GetAuthorizationToken(input)
+	if err != nil {
+		return nil, err
+	}
+	if m.validity > 0 {
+		token.AuthorizationData[0].ExpiresAt = aws.Time(time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC).Add(m.validity))
+	}
+
+	return token, nil
+}
+
+func TestCachingClient(t *testing.T) {
+	tests := []struct {
+		validity time.Duration
+		elapsed  time.Duration
+		calls    int
+	}{
+		// reused within the validity window
+		{validity: 12 * time.Hour, elapsed: time.Hour, calls: 1},
+		// refreshed shortly before the token expires
+		{validity: 12 * time.Hour, elapsed: 12*time.Hour - time.Minute, calls: 2},
+		// tokens without an expiry are not cached
+		{calls: 2},
+	}
+
+	for _, test := range tests {
+		now := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+		cache := newTokenCache()
+		cache.now = func() time.Time { return now }
+
+		svc := &mockTokenClient{validity: test.validity}
+		client := &cachingClient{ECRAPI: svc, cache: cache}
+
+		_, err := client.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(test.elapsed)
+		_, err = client.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if svc.calls != test.calls {
+			t.Errorf("%d is not equal to %d", test.calls, svc.calls)
+		}
+	}
+
+	// failures are not cached
+	testFailure = "GetAuthorizationToken"
+	client := &cachingClient{ECRAPI: &mockTokenClient{validity: time.Hour}, cache: newTokenCache()}
+	_, err := client.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err == nil {
+		t.Errorf("GetAuthorizationToken should have failed")
+	}
+	testFailure = ""
+}
@@ -0,0 +1,371 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+// sizes of the layer parts accepted by ECR, only the last part of a layer can be smaller
+const (
+	minUploadPartSize = 5 << 20
+	maxUploadPartSize = 20 << 20
+)
+
+// blob that can be uploaded with the ECR layer upload API
+type blob struct {
+	digest v1.Hash
+	open   func() (io.ReadCloser, error)
+}
+
+// uploads images with the ECR layer upload API using concurrent blob uploads
+type uploader struct {
+	svc        ecriface.ECRAPI
+	repository string
+	jobs       int
+	chunkSize  int64
+}
+
+// uploader constructor
+func newUploader(svc ecriface.ECRAPI, repository string, jobs int, chunkSize int64) *uploader {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	return &uploader{
+		svc:        svc,
+		repository: repository,
+		jobs:       jobs,
+		chunkSize:  chunkSize,
+	}
+}
+
+// upload an image or image index and tag it with the destination reference
+func (u *uploader) write(dst name.Reference, image artifact) error {
+	switch image := image.(type) {
+	case v1.ImageIndex:
+		return u.writeIndex(dst, image)
+	case v1.Image:
+		return u.writeImage(dst, image)
+	default:
+		return fmt.Errorf("unsupported image type: %T", image)
+	}
+}
+
+func (u *uploader) writeIndex(dst name.Reference, idx v1.ImageIndex) error {
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	// child manifests must exist before the index referencing them
+	for _, desc := range manifest.Manifests {
+		child := dst.Context().Digest(desc.Digest.String())
+
+		if desc.MediaType.IsIndex() {
+			childIdx, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			err = u.writeIndex(child, childIdx)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return err
+		}
+		err = u.writeImage(child, img)
+		if err != nil {
+			return err
+		}
+	}
+
+	raw, err := idx.RawManifest()
+	if err != nil {
+		return err
+	}
+
+	mediaType, err := idx.MediaType()
+	if err != nil {
+		return err
+	}
+
+	return u.putManifest(dst, raw, string(mediaType))
+}
+
+func (u *uploader) writeImage(dst name.Reference, img v1.Image) error {
+	blobs, err := imageBlobs(img)
+	if err != nil {
+		return err
+	}
+
+	err = u.uploadBlobs(blobs)
+	if err != nil {
+		return err
+	}
+
+	raw, err := img.RawManifest()
+	if err != nil {
+		return err
+	}
+
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return err
+	}
+
+	return u.putManifest(dst, raw, string(mediaType))
+}
+
+// collect the config and layer blobs referenced by an image
+func imageBlobs(img v1.Image) ([]blob, error) {
+	config, err := img.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	configName, err := img.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := []blob{{
+		digest: configName,
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(config)), nil
+		},
+	}}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, err
+		}
+
+		blobs = append(blobs, blob{digest: digest, open: layer.Compressed})
+	}
+
+	return blobs, nil
+}
+
+// upload the blobs missing from the repository concurrently
+func (u *uploader) uploadBlobs(blobs []blob) error {
+	missing, err := u.missingBlobs(blobs)
+	if err != nil {
+		return err
+	}
+
+	g := errgroup.Group{}
+	g.SetLimit(u.jobs)
+
+	for _, b := range missing {
+		b := b
+		g.Go(func() error {
+			return u.uploadBlob(b)
+		})
+	}
+
+	return g.Wait()
+}
+
+// filter out blobs that are already available in the repository
+func (u *uploader) missingBlobs(blobs []blob) ([]blob, error) {
+	var missing []blob
+	seen := map[v1.Hash]bool{}
+
+	// the availability API accepts at most 100 digests per request
+	for start := 0; start < len(blobs); start += 100 {
+		end := start + 100
+		if end > len(blobs) {
+			end = len(blobs)
+		}
+
+		input := &ecr.BatchCheckLayerAvailabilityInput{RepositoryName: aws.String(u.repository)}
+		for _, b := range blobs[start:end] {
+			input.LayerDigests = append(input.LayerDigests, aws.String(b.digest.String()))
+		}
+
+		result, err := u.svc.BatchCheckLayerAvailability(input)
+		if err != nil {
+			return nil, err
+		}
+
+		available := map[string]bool{}
+		for _, layer := range result.Layers {
+			if aws.StringValue(layer.LayerAvailability) == ecr.LayerAvailabilityAvailable {
+				available[aws.StringValue(layer.LayerDigest)] = true
+			}
+		}
+
+		for _, b := range blobs[start:end] {
+			if available[b.digest.String()] || seen[b.digest] {
+				continue
+			}
+			seen[b.digest] = true
+			missing = append(missing, b)
+		}
+	}
+
+	return missing, nil
+}
+
+// upload a single blob in sequential parts
+func (u *uploader) uploadBlob(b blob) error {
+	upload, err := u.svc.InitiateLayerUpload(&ecr.InitiateLayerUploadInput{RepositoryName: aws.String(u.repository)})
+	if err != nil {
+		return err
+	}
+
+	// fall back to the part size recommended by ECR
+	chunkSize := u.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = aws.Int64Value(upload.PartSize)
+	}
+	if chunkSize <= 0 {
+		return fmt.Errorf("could not determine the upload part size for blob: %s", b.digest)
+	}
+
+	rc, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(rc, buf)
+		if n > 0 {
+			_, uerr := u.svc.UploadLayerPart(&ecr.UploadLayerPartInput{
+				RepositoryName: aws.String(u.repository),
+				UploadId:       upload.UploadId,
+				PartFirstByte:  aws.Int64(offset),
+				PartLastByte:   aws.Int64(offset + int64(n) - 1),
+				LayerPartBlob:  buf[:n],
+			})
+			if uerr != nil {
+				return uerr
+			}
+			offset += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = u.svc.CompleteLayerUpload(&ecr.CompleteLayerUploadInput{
+		RepositoryName: aws.String(u.repository),
+		UploadId:       upload.UploadId,
+		LayerDigests:   []*string{aws.String(b.digest.String())},
+	})
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		// another upload of the same blob finished first
+		if ok && aerr.Code() == ecr.ErrCodeLayerAlreadyExistsException {
+			return nil
+		}
+		return err
+	}
+
+	log.Printf("uploaded %s (%d bytes)", b.digest, offset)
+	return nil
+}
+
+// create the manifest in the repository, tagged or by digest
+func (u *uploader) putManifest(dst name.Reference, manifest []byte, mediaType string) error {
+	input := &ecr.PutImageInput{
+		RepositoryName:         aws.String(u.repository),
+		ImageManifest:          aws.String(string(manifest)),
+		ImageManifestMediaType: aws.String(mediaType),
+	}
+
+	switch dst := dst.(type) {
+	case name.Tag:
+		input.ImageTag = aws.String(dst.TagStr())
+	case name.Digest:
+		input.ImageDigest = aws.String(dst.DigestStr())
+	}
+
+	_, err := u.svc.PutImage(input)
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		// ignore pushing an unchanged manifest
+		if ok && aerr.Code() == ecr.ErrCodeImageAlreadyExistsException {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// check that upload_chunk_size is a part size accepted by ECR, each upload job buffers a part
+func checkUploadChunkSize(size string) error {
+	chunkSize, err := parseSize(size)
+	if err != nil {
+		return err
+	}
+
+	if chunkSize < minUploadPartSize || chunkSize > maxUploadPartSize {
+		return fmt.Errorf("upload chunk size must be between 5MiB and 20MiB: %s", size)
+	}
+
+	return nil
+}
+
+// parse a size such as "64MB" or "6GiB" into bytes
+func parseSize(size string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KIB", 1 << 10},
+		{"MIB", 1 << 20},
+		{"GIB", 1 << 30},
+		{"KB", 1000},
+		{"MB", 1000 * 1000},
+		{"GB", 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("could not parse size: %s", size)
+	}
+
+	return n * multiplier, nil
+}
@@ -0,0 +1,227 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// records layer uploads made through the ECR layer upload API
+type mockUploadClient struct {
+	mockECRClient
+
+	mu        sync.Mutex
+	existing  map[string]bool
+	uploads   map[string]*bytes.Buffer
+	completed map[string]bool
+	manifests map[string]string
+	parts     int
+}
+
+func newMockUploadClient() *mockUploadClient {
+	return &mockUploadClient{
+		existing:  map[string]bool{},
+		uploads:   map[string]*bytes.Buffer{},
+		completed: map[string]bool{},
+		manifests: map[string]string{},
+	}
+}
+
+func (m *mockUploadClient) BatchCheckLayerAvailability(input *ecr.BatchCheckLayerAvailabilityInput) (*ecr.BatchCheckLayerAvailabilityOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	output := &ecr.BatchCheckLayerAvailabilityOutput{}
+	for _, digest := range input.LayerDigests {
+		availability := ecr.LayerAvailabilityUnavailable
+		if m.existing[aws.StringValue(digest)] {
+			availability = ecr.LayerAvailabilityAvailable
+		}
+		output.Layers = append(output.Layers, &ecr.Layer{LayerDigest: digest, LayerAvailability: aws.String(availability)})
+	}
+
+	return output, nil
+}
+
+func (m *mockUploadClient) InitiateLayerUpload(input *ecr.InitiateLayerUploadInput) (*ecr.InitiateLayerUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := fmt.Sprintf("upload-%d", len(m.uploads))
+	m.uploads[id] = &bytes.Buffer{}
+
+	return &ecr.InitiateLayerUploadOutput{UploadId: aws.String(id), PartSize: aws.Int64(512)}, nil
+}
+
+func (m *mockUploadClient) UploadLayerPart(input *ecr.UploadLayerPartInput) (*ecr.UploadLayerPartOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf := m.uploads[aws.StringValue(input.UploadId)]
+	if int64(buf.Len()) != aws.Int64Value(input.PartFirstByte) {
+		return nil, fmt.Errorf("part starts at %d, expected %d", aws.Int64Value(input.PartFirstByte), buf.Len())
+	}
+
+	buf.Write(input.LayerPartBlob)
+	m.parts++
+
+	return &ecr.UploadLayerPartOutput{}, nil
+}
+
+func (m *mockUploadClient) CompleteLayerUpload(input *ecr.CompleteLayerUploadInput) (*ecr.CompleteLayerUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sum := sha256.Sum256(m.uploads[aws.StringValue(input.UploadId)].Bytes())
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if digest != aws.StringValue(input.LayerDigests[0]) {
+		return nil, fmt.Errorf("uploaded digest %s does not match %s", digest, aws.StringValue(input.LayerDigests[0]))
+	}
+
+	m.completed[digest] = true
+	return &ecr.CompleteLayerUploadOutput{}, nil
+}
+
+func (m *mockUploadClient) PutImage(input *ecr.PutImageInput) (*ecr.PutImageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ref := aws.StringValue(input.ImageTag)
+	if ref == "" {
+		ref = aws.StringValue(input.ImageDigest)
+	}
+	m.manifests[ref] = aws.StringValue(input.ImageManifest)
+
+	return &ecr.PutImageOutput{}, nil
+}
+
+func TestUploaderWriteImage(t *testing.T) {
+	img, err := random.Image(2048, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first layer is already in the repository
+	existing, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := name.NewTag("0123456789.dkr.ecr.us-east-1.amazonaws.com/repository:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chunkSize int64
+	}{
+		// recommended part size
+		{chunkSize: 0},
+		// configured part size
+		{chunkSize: 100},
+	}
+
+	for _, test := range tests {
+		svc := newMockUploadClient()
+		svc.existing[existing.String()] = true
+
+		err = newUploader(svc, "repository", 4, test.chunkSize).write(dst, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// config and the two missing layers
+		if len(svc.completed) != 3 {
+			t.Errorf("%d blobs uploaded, expected 3", len(svc.completed))
+		}
+
+		if svc.completed[existing.String()] {
+			t.Errorf("existing layer %s should not be uploaded", existing)
+		}
+
+		raw, err := img.RawManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if svc.manifests["test"] != string(raw) {
+			t.Errorf("manifest was not pushed with the tag")
+		}
+	}
+}
+
+func TestUploaderWriteIndex(t *testing.T) {
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := name.NewTag("0123456789.dkr.ecr.us-east-1.amazonaws.com/repository:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newMockUploadClient()
+	err = newUploader(svc, "repository", 2, 0).write(dst, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two child manifests and the tagged index
+	if len(svc.manifests) != 3 {
+		t.Errorf("%d manifests pushed, expected 3", len(svc.manifests))
+	}
+}
+
+func TestCheckUploadChunkSize(t *testing.T) {
+	for size, valid := range map[string]bool{"5MiB": true, "16MiB": true, "20MiB": true, "4MiB": false, "64MiB": false, "large": false} {
+		if err := checkUploadChunkSize(size); (err == nil) != valid {
+			t.Errorf("%s: unexpected error: %v", size, err)
+		}
+	}
+
+	p := Config{Target: "//app:push", UploadChunkSize: "1GiB"}
+	if p.validate() == nil {
+		t.Errorf("chunk size over the ECR limit should have failed")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+		fail bool
+	}{
+		{size: "1024", want: 1024},
+		{size: "64MB", want: 64 * 1000 * 1000},
+		{size: "64MiB", want: 64 << 20},
+		{size: "6 gib", want: 6 << 30},
+		{size: "10KB", want: 10000},
+		{size: "large", fail: true},
+		{size: "-1", fail: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseSize(test.size)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: unexpected error: %v", test.size, err)
+		}
+
+		if got != test.want {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
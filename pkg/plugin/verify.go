@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// check that the credentials are for the registry account and that it replicates to the expected regions
+func (p *Config) verifyRegistry(svc ecriface.ECRAPI) error {
+	result, err := svc.DescribeRegistry(&ecr.DescribeRegistryInput{})
+	if err != nil {
+		return err
+	}
+
+	registryID := aws.StringValue(result.RegistryId)
+	accountID := p.registryID()
+	if registryID != accountID {
+		return fmt.Errorf("credentials are for registry %s instead of the registry account %s", registryID, accountID)
+	}
+
+	regions := map[string]bool{}
+	if result.ReplicationConfiguration != nil {
+		for _, rule := range result.ReplicationConfiguration.Rules {
+			for _, dst := range rule.Destinations {
+				regions[aws.StringValue(dst.Region)] = true
+			}
+		}
+	}
+	log.Printf("registry %s replicates to %d regions", registryID, len(regions))
+
+	for _, region := range p.ReplicationRegions {
+		region = strings.TrimSpace(region)
+		if !regions[region] {
+			return fmt.Errorf("registry %s does not replicate to %s", registryID, region)
+		}
+	}
+
+	return nil
+}
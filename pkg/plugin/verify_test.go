@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func (m *mockECRClient) DescribeRegistry(input *ecr.DescribeRegistryInput) (*ecr.DescribeRegistryOutput, error) {
+	if testFailure == "DescribeRegistry" {
+		return nil, errors.New("DescribeRegistry")
+	}
+
+	return &ecr.DescribeRegistryOutput{
+		RegistryId: aws.String("0123456789"),
+		ReplicationConfiguration: &ecr.ReplicationConfiguration{
+			Rules: []*ecr.ReplicationRule{
+				{
+					Destinations: []*ecr.ReplicationDestination{
+						{Region: aws.String("us-west-2"), RegistryId: aws.String("0123456789")},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestVerifyRegistry(t *testing.T) {
+	tests := []struct {
+		plugin  Config
+		failure string
+		err     bool
+	}{
+		{
+			plugin: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com"},
+		},
+		{
+			plugin: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", ReplicationRegions: []string{"us-west-2"}},
+		},
+		{
+			plugin: Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com", ReplicationRegions: []string{"eu-west-1"}},
+			err:    true,
+		},
+		// credentials for another account
+		{
+			plugin: Config{Registry: "9876543210.dkr.ecr.us-east-1.amazonaws.com"},
+			err:    true,
+		},
+		{
+			plugin:  Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com"},
+			failure: "DescribeRegistry",
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		testFailure = test.failure
+
+		err := test.plugin.verifyRegistry(&mockECRClient{})
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %+v: %v", test.plugin, err)
+		}
+	}
+	testFailure = ""
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// fetch the manifests of the warm images so the pull-through cache holds them before the build
+func (p *Config) warmCache(svc ecriface.ECRAPI) error {
+	auth, err := p.registryAuth(svc)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range p.WarmImages {
+		ref, err := name.ParseReference(p.cacheRef(strings.TrimSpace(image)))
+		if err != nil {
+			return err
+		}
+
+		desc, err := remote.Get(ref, remote.WithAuth(auth))
+		if err != nil {
+			return fmt.Errorf("could not warm %s: %w", ref, err)
+		}
+
+		log.Printf("warmed %s@%s", ref, desc.Digest)
+	}
+
+	return nil
+}
+
+// reference to an image in the registry, relative references are resolved against it
+// e.g. docker-hub/library/nginx:1.23 for a docker-hub pull-through cache rule
+func (p *Config) cacheRef(image string) string {
+	if strings.HasPrefix(image, p.Registry+"/") {
+		return image
+	}
+
+	return p.Registry + "/" + image
+}
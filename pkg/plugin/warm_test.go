@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestWarmCache(t *testing.T) {
+	testFailure = ""
+	host := newTestRegistry(t)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(fmt.Sprintf("%s/docker-hub/library/nginx:1.23", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = remote.Write(ref, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		images []string
+		err    bool
+	}{
+		{images: []string{"docker-hub/library/nginx:1.23"}},
+		{images: []string{ref.String()}},
+		{images: []string{"docker-hub/library/nginx:missing"}, err: true},
+	}
+
+	for _, test := range tests {
+		p := Config{Registry: host, WarmImages: test.images}
+
+		err := p.warmCache(&mockECRClient{})
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %v: %v", test.images, err)
+		}
+	}
+}
+
+func TestCacheRef(t *testing.T) {
+	p := Config{Registry: "0123456789.dkr.ecr.us-east-1.amazonaws.com"}
+
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "docker-hub/library/nginx:1.23", want: "0123456789.dkr.ecr.us-east-1.amazonaws.com/docker-hub/library/nginx:1.23"},
+		{image: "0123456789.dkr.ecr.us-east-1.amazonaws.com/quay/prometheus/node-exporter", want: "0123456789.dkr.ecr.us-east-1.amazonaws.com/quay/prometheus/node-exporter"},
+	}
+
+	for _, test := range tests {
+		got := p.cacheRef(test.image)
+		if got != test.want {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"log"
+	"path"
+	"strings"
+)
+
+// whether the build matches the when_events and when_branches conditions
+func (p *Config) when(getter buildGetter) bool {
+	if len(p.WhenEvents) > 0 && !matchAny(p.WhenEvents, getter.Event()) {
+		log.Printf("skipping, event %s does not match when_events %v", getter.Event(), p.WhenEvents)
+		return false
+	}
+
+	if len(p.WhenBranches) > 0 && !matchAny(p.WhenBranches, getter.ScmBranch()) {
+		log.Printf("skipping, branch %s does not match when_branches %v", getter.ScmBranch(), p.WhenBranches)
+		return false
+	}
+
+	return true
+}
+
+// whether the value matches any of the glob patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), value); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWhen(t *testing.T) {
+	tests := []struct {
+		plugin Config
+		event  string
+		want   bool
+	}{
+		{plugin: Config{}, event: "push", want: true},
+		{plugin: Config{WhenEvents: []string{"push", "tag"}}, event: "push", want: true},
+		{plugin: Config{WhenEvents: []string{"tag"}}, event: "push", want: false},
+		{plugin: Config{WhenBranches: []string{"main", "te*"}}, event: "push", want: true},
+		{plugin: Config{WhenBranches: []string{"release/*"}}, event: "push", want: false},
+		{plugin: Config{WhenEvents: []string{"push"}, WhenBranches: []string{"main"}}, event: "push", want: false},
+	}
+
+	for _, test := range tests {
+		getter := &buildMock{event: test.event}
+
+		got := test.plugin.when(getter)
+		if got != test.want {
+			t.Errorf("%v is not equal to %v for %+v", got, test.want, test.plugin)
+		}
+	}
+}
+
+func TestRunSkipped(t *testing.T) {
+	t.Setenv("DRONE_BUILD_EVENT", "push")
+
+	cfg := Config{
+		Target:     "//:push",
+		Registry:   "0123456789.dkr.ecr.us-east-1.amazonaws.com",
+		Repository: "repository",
+		WhenEvents: []string{"tag"},
+	}
+	runner := &recordingRunner{}
+
+	err := Run(context.Background(), cfg, WithRunner(runner), WithECRClient(&mockECRClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.calls) != 0 {
+		t.Errorf("%d commands run, expected 0", len(runner.calls))
+	}
+}
@@ -0,0 +1,35 @@
+package plugin
+
+import "strings"
+
+// whether the bazel command builds targets and accepts build flags
+func isBuildCommand(command string) bool {
+	switch command {
+	case "build", "run", "test", "coverage":
+		return true
+	}
+
+	return false
+}
+
+// flags tuning persistent workers
+func (p *Config) workerArgs() []string {
+	var args []string
+
+	// a count for every mnemonic or mnemonic=count pairs, e.g. Javac=4
+	for _, instances := range p.WorkerMaxInstances {
+		args = append(args, joinFlag("--worker_max_instances", strings.TrimSpace(instances)))
+	}
+
+	if p.WorkerQuitAfterBuild != nil {
+		args = append(args, boolFlag("worker_quit_after_build", *p.WorkerQuitAfterBuild))
+	}
+	if p.WorkerSandboxing != nil {
+		args = append(args, boolFlag("worker_sandboxing", *p.WorkerSandboxing))
+	}
+	if p.MultiplexSandboxing != nil {
+		args = append(args, boolFlag("experimental_worker_multiplex_sandboxing", *p.MultiplexSandboxing))
+	}
+
+	return args
+}
@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkerArgs(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		plugin Config
+		want   []string
+	}{
+		{plugin: Config{}},
+		{
+			plugin: Config{WorkerMaxInstances: []string{"4"}},
+			want:   []string{"--worker_max_instances=4"},
+		},
+		{
+			plugin: Config{WorkerMaxInstances: []string{"Javac=4", " TypeScriptCompile=2"}, WorkerQuitAfterBuild: &yes, WorkerSandboxing: &no, MultiplexSandboxing: &yes},
+			want: []string{
+				"--worker_max_instances=Javac=4",
+				"--worker_max_instances=TypeScriptCompile=2",
+				"--worker_quit_after_build",
+				"--noworker_sandboxing",
+				"--experimental_worker_multiplex_sandboxing",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.plugin.workerArgs()
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%v is not equal to %v", test.want, got)
+		}
+	}
+}